@@ -0,0 +1,913 @@
+//go:build !test
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	Identity "github.com/jameswoolfenden/identity/src"
+)
+
+// commands maps each sub-command name to its implementation.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"effective":   effective,
+	"snapshot":    snapshot,
+	"diff":        diff,
+	"trail":       trail,
+	"unused":      unused,
+	"terraform":   terraform,
+	"plan":        plan,
+	"lint":        lint,
+	"quota":       quota,
+	"minimize":    minimize,
+	"doctor":      doctor,
+	"reader-role": readerRole,
+	"history":     history,
+	"graph":       graph,
+	"report":      report,
+	"explain":     explain,
+}
+
+func effective(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("effective", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	iamIdentity, err := Identity.GetIam(ctx)
+	if err != nil {
+		return err
+	}
+
+	matrix := Identity.Effective(iamIdentity)
+
+	switch *format {
+	case "json":
+		return writeJSON(os.Stdout, matrix)
+	case "table":
+		return writeEffective(os.Stdout, matrix)
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+}
+
+func snapshot(ctx context.Context, _ []string) error {
+	iamIdentity, err := Identity.GetIam(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(os.Stdout, Identity.NewSnapshot(iamIdentity))
+}
+
+func diff(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	failOnGain := flags.Bool("fail-on-gain", false, "exit with an error when any permission is gained or broadened")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return fmt.Errorf("usage: identity diff [flags] before [after]")
+	}
+
+	before, err := loadComparable(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var after Identity.IAM
+
+	if flags.NArg() == 2 {
+		after, err = loadComparable(flags.Arg(1))
+	} else {
+		after, err = Identity.GetIam(ctx)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	result := Identity.Diff(before, after)
+
+	switch *format {
+	case "json":
+		err = writeJSON(os.Stdout, result)
+	case "table":
+		err = writeDiff(os.Stdout, result)
+	default:
+		err = fmt.Errorf("unknown format %s", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if *failOnGain && result.Broadened() {
+		return fmt.Errorf("permissions were gained or broadened")
+	}
+
+	return nil
+}
+
+func trail(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("trail", flag.ContinueOnError)
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+	from := flags.String("from", "", "start of the window, RFC 3339")
+	to := flags.String("to", "", "end of the window, RFC 3339")
+	days := flags.Int("days", 0, "window of the last N days, used when --from is not set")
+	minimized := flags.Bool("minimize", false, "compress the generated policy's actions into safe wildcards")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: identity trail [flags] file-or-directory...")
+	}
+
+	var start, end time.Time
+	var err error
+
+	if *from != "" {
+		if start, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid --from: %w", err)
+		}
+	} else if *days > 0 {
+		start = time.Now().AddDate(0, 0, -*days)
+	}
+
+	if *to != "" {
+		if end, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+
+	iamIdentity, err := loadIdentity(ctx, *snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	events, err := Identity.ReadCloudTrail(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	report := Identity.TrailReport(events, iamIdentity, start, end)
+	if *minimized {
+		report.Policy = Identity.Minimize(report.Policy).Policy
+	}
+
+	return writeJSON(os.Stdout, report)
+}
+
+func unused(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("unused", flag.ContinueOnError)
+	days := flags.Int("days", 90, "report permissions not used in this many days")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	iamIdentity, err := Identity.GetIam(ctx)
+	if err != nil {
+		return err
+	}
+
+	accessed, err := Identity.GetServiceLastAccessed(ctx, iamIdentity)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(os.Stdout, Identity.Unused(iamIdentity, accessed, *days, time.Now()))
+}
+
+func terraform(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("terraform", flag.ContinueOnError)
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	iamIdentity, err := loadIdentity(ctx, *snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	code, err := Identity.ExportTerraform(iamIdentity)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(os.Stdout, code)
+
+	return err
+}
+
+func plan(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: identity plan [flags] plan.json")
+	}
+
+	iamIdentity, err := loadIdentity(ctx, *snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	report, err := Identity.CheckPlan(flags.Arg(0), iamIdentity)
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSON(os.Stdout, report); err != nil {
+		return err
+	}
+
+	if len(report.Missing) > 0 {
+		return fmt.Errorf("%d permissions needed by the plan are missing", len(report.Missing))
+	}
+
+	return nil
+}
+
+func lint(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table, json, sarif or junit")
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+	failOnFindings := flags.Bool("fail-on-findings", false, "exit with an error when there are any findings")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: identity lint [flags] [file-or-directory]")
+	}
+
+	var iamIdentity Identity.IAM
+	var err error
+
+	// findings of policies that are not from code are placed in the snapshot they came from
+	artifact := *snapshotFile
+
+	if flags.NArg() == 1 {
+		iamIdentity, err = loadComparable(flags.Arg(0))
+		artifact = flags.Arg(0)
+	} else {
+		iamIdentity, err = loadIdentity(ctx, *snapshotFile)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	findings := append(Identity.Lint(iamIdentity), Identity.Escalations(iamIdentity)...)
+	Identity.SortFindings(findings)
+
+	switch *format {
+	case "json":
+		err = writeJSON(os.Stdout, findings)
+	case "sarif":
+		err = Identity.WriteSARIF(os.Stdout, findings, artifact)
+	case "junit":
+		err = Identity.WriteJUnit(os.Stdout, findings)
+	case "table":
+		err = writeFindings(os.Stdout, findings)
+	default:
+		err = fmt.Errorf("unknown format %s", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if *failOnFindings && len(findings) > 0 {
+		return fmt.Errorf("%d findings", len(findings))
+	}
+
+	return nil
+}
+
+func quota(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("quota", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: identity quota [flags] [file-or-directory]")
+	}
+
+	var iamIdentity Identity.IAM
+	var err error
+
+	if flags.NArg() == 1 {
+		iamIdentity, err = loadComparable(flags.Arg(0))
+	} else {
+		iamIdentity, err = loadIdentity(ctx, *snapshotFile)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	report := Identity.CheckQuotas(iamIdentity)
+
+	switch *format {
+	case "json":
+		err = writeJSON(os.Stdout, report)
+	case "table":
+		err = writeQuotas(os.Stdout, report)
+	default:
+		err = fmt.Errorf("unknown format %s", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if report.Exceeded() {
+		return fmt.Errorf("IAM quotas exceeded")
+	}
+
+	return nil
+}
+
+func minimize(_ context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: identity minimize policy.json")
+	}
+
+	raw, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	policy, err := Identity.ParseWithOptions(string(raw), Identity.ParseOptions{Format: Identity.AutoFormat})
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+
+	return writeJSON(os.Stdout, Identity.Minimize(policy))
+}
+
+func doctor(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	checks := Identity.Doctor(ctx)
+
+	var err error
+
+	switch *format {
+	case "json":
+		err = writeJSON(os.Stdout, checks)
+	case "table":
+		err = writeDoctor(os.Stdout, checks)
+	default:
+		err = fmt.Errorf("unknown format %s", *format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if failed := Identity.DoctorFailures(checks); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+
+	return nil
+}
+
+func readerRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reader-role", flag.ContinueOnError)
+	format := flags.String("format", Identity.JSONFormat, "output format: json, terraform or cloudformation")
+	caller := flags.String("caller", "", "ARN allowed to assume the role (default: the caller of the current profile)")
+	features := flags.String("features", "", "comma-separated features to grant (default: all)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var err error
+
+	if *caller == "" {
+		if *caller, err = Identity.CallerArn(ctx); err != nil {
+			return err
+		}
+	}
+
+	var selected []Identity.Feature
+
+	if *features != "" {
+		for _, feature := range strings.Split(*features, ",") {
+			selected = append(selected, Identity.Feature(strings.TrimSpace(feature)))
+		}
+	}
+
+	role, err := Identity.NewReaderRole(Identity.GetIAMRoleName(), *caller, selected...)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := role.Render(*format)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(os.Stdout, rendered)
+
+	return err
+}
+
+func history(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	from := flags.String("from", "", "version ID, date or RFC 3339 time to diff from; lists the versions when not set")
+	to := flags.String("to", Identity.DefaultVersion, "version ID, date or RFC 3339 time to diff to")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: identity history [flags] policy-arn")
+	}
+
+	arn := flags.Arg(0)
+
+	account, err := policyAccount(ctx, arn)
+	if err != nil {
+		return err
+	}
+
+	if *from == "" {
+		versions, err := Identity.GetPolicyVersions(ctx, arn, account)
+		if err != nil {
+			return err
+		}
+
+		switch *format {
+		case "json":
+			return writeJSON(os.Stdout, versions)
+		case "table":
+			return writeVersions(os.Stdout, versions)
+		default:
+			return fmt.Errorf("unknown format %s", *format)
+		}
+	}
+
+	result, err := Identity.DiffPolicyVersions(ctx, arn, *from, *to, account)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		return writeJSON(os.Stdout, result)
+	case "table":
+		fmt.Fprintf(os.Stdout, "%s %s (%s) -> %s (%s)\n", result.Arn, result.From.VersionID, result.From.Created.Format(time.RFC3339),
+			result.To.VersionID, result.To.Created.Format(time.RFC3339))
+
+		return writeDiff(os.Stdout, result.Diff)
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+}
+
+func graph(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := flags.String("format", Identity.DOTFormat, "output format: dot, mermaid or json")
+	action := flags.String("action", "", "only show the policies that name this action")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var identities []Identity.IAM
+
+	for _, path := range flags.Args() {
+		ident, err := loadComparable(path)
+		if err != nil {
+			return err
+		}
+
+		identities = append(identities, ident)
+	}
+
+	if len(identities) == 0 {
+		ident, err := Identity.GetIam(ctx)
+		if err != nil {
+			return err
+		}
+
+		identities = append(identities, ident)
+	}
+
+	result, err := Identity.NewGraph(identities, *action)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := result.Render(*format)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(os.Stdout, rendered)
+
+	return err
+}
+
+func report(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	htmlFile := flags.String("html", "", "file to write the HTML report to, or - for standard output")
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *htmlFile == "" || flags.NArg() > 1 {
+		return fmt.Errorf("usage: identity report --html out.html [flags] [file-or-directory]")
+	}
+
+	var iamIdentity Identity.IAM
+	var err error
+
+	if flags.NArg() == 1 {
+		iamIdentity, err = loadComparable(flags.Arg(0))
+	} else {
+		iamIdentity, err = loadIdentity(ctx, *snapshotFile)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	page := Identity.NewReport(iamIdentity, time.Now())
+
+	if *htmlFile == "-" {
+		return page.WriteHTML(os.Stdout)
+	}
+
+	file, err := os.Create(*htmlFile)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+
+	if err := page.WriteHTML(file); err != nil {
+		file.Close()
+
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return file.Close()
+}
+
+func explain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	format := flags.String("format", "table", "output format: table or json")
+	snapshotFile := flags.String("snapshot", "", "identity snapshot to use instead of the live identity")
+	requestContext := contextFlag{}
+	flags.Var(requestContext, "context", "condition key or policy variable of the request as key=value; repeat for more keys or values")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: identity explain [flags] action resource")
+	}
+
+	iamIdentity, err := loadIdentity(ctx, *snapshotFile)
+	if err != nil {
+		return err
+	}
+
+	result := Identity.Explain(iamIdentity, flags.Arg(0), flags.Arg(1), requestContext)
+
+	switch *format {
+	case "json":
+		return writeJSON(os.Stdout, result)
+	case "table":
+		return writeExplanation(os.Stdout, result)
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+}
+
+// contextFlag collects repeated key=value flags into the request context of explain.
+type contextFlag map[string][]string
+
+func (c contextFlag) String() string {
+	return ""
+}
+
+func (c contextFlag) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return fmt.Errorf("context must be key=value, not %q", value)
+	}
+
+	c[key] = append(c[key], val)
+
+	return nil
+}
+
+// policyAccount returns the account to read a managed policy in: the policy's own account, or the caller's
+// for AWS managed policies.
+func policyAccount(ctx context.Context, arn string) (Identity.IAM, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || !strings.HasPrefix(parts[5], "policy/") {
+		return Identity.IAM{}, fmt.Errorf("invalid policy ARN %q", arn)
+	}
+
+	if parts[4] != "aws" {
+		return Identity.IAM{Account: parts[4]}, nil
+	}
+
+	caller, err := Identity.CallerArn(ctx)
+	if err != nil {
+		return Identity.IAM{}, err
+	}
+
+	return Identity.IAM{Account: strings.Split(caller, ":")[4]}, nil
+}
+
+// loadIdentity reads a snapshot when one is given and otherwise resolves the live identity.
+func loadIdentity(ctx context.Context, snapshotFile string) (Identity.IAM, error) {
+	if snapshotFile != "" {
+		return Identity.LoadIAM(snapshotFile)
+	}
+
+	return Identity.GetIam(ctx)
+}
+
+// loadComparable reads an identity snapshot, or else the policies defined by infrastructure code at path.
+func loadComparable(path string) (Identity.IAM, error) {
+	if snapshot, err := Identity.LoadIAM(path); err == nil && snapshot.IamType != "" {
+		return snapshot, nil
+	}
+
+	policies, err := Identity.LoadPolicies(path)
+
+	var problems Identity.LoadErrors
+	if errors.As(err, &problems) {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", problem)
+		}
+	} else if err != nil {
+		return Identity.IAM{}, err
+	}
+
+	return Identity.IAM{Name: path, Policies: policies}, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func writeEffective(w io.Writer, matrix Identity.EffectivePermissions) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "SERVICE\tACTION\tLEVEL\tEFFECT\tRESOURCES\tCONDITIONS")
+
+	for _, service := range matrix.Services() {
+		for _, permission := range matrix[service] {
+			for _, grant := range permission.Allow {
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", service, permission.Action, permission.AccessLevel,
+					Identity.Allow, formatResources(grant), formatCondition(grant.Condition))
+			}
+
+			for _, grant := range permission.Deny {
+				fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", service, permission.Action, permission.AccessLevel,
+					Identity.Deny, formatResources(grant), formatCondition(grant.Condition))
+			}
+		}
+	}
+
+	return table.Flush()
+}
+
+func formatResources(grant Identity.Grant) string {
+	resources := strings.Join(grant.Resources, ",")
+	if len(grant.NotResources) > 0 {
+		resources += " except " + strings.Join(grant.NotResources, ",")
+	}
+
+	return resources
+}
+
+func formatCondition(condition Identity.Condition) string {
+	if len(condition) == 0 {
+		return "-"
+	}
+
+	raw, err := json.Marshal(condition)
+	if err != nil {
+		return "?"
+	}
+
+	return string(raw)
+}
+
+func writeDiff(w io.Writer, result Identity.PermissionDiff) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "CHANGE\tSERVICE\tLEVEL\tACTION\tADDED\tREMOVED")
+
+	sections := []struct {
+		name    string
+		changes []Identity.ActionChange
+	}{
+		{"gained", result.Gained},
+		{"lost", result.Lost},
+		{"changed", result.Changed},
+		{"conditioned", result.Conditioned},
+	}
+
+	for _, section := range sections {
+		for _, change := range section.changes {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", section.name, change.Service, change.AccessLevel,
+				change.Action, orDash(strings.Join(change.Added, ",")), orDash(strings.Join(change.Removed, ",")))
+		}
+	}
+
+	return table.Flush()
+}
+
+func writeVersions(w io.Writer, versions []Identity.PolicyVersion) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "VERSION\tCREATED\tDEFAULT")
+
+	for _, version := range versions {
+		current := "-"
+		if version.Default {
+			current = "yes"
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\n", version.VersionID, version.Created.Format(time.RFC3339), current)
+	}
+
+	return table.Flush()
+}
+
+func writeExplanation(w io.Writer, result Identity.Explanation) error {
+	decision := result.Decision
+	if result.Conditional {
+		decision += " (conditional: depends on condition keys or policy variables not given with --context)"
+	}
+
+	fmt.Fprintf(w, "%s on %s: %s\n%s\n\n", result.Action, result.Resource, decision, result.Reason)
+
+	layers := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(layers, "LAYER\tALLOWS\tDENIES\tNOTE")
+
+	for _, layer := range result.Layers {
+		if !layer.Evaluated {
+			fmt.Fprintf(layers, "%s\t-\t-\t%s\n", layer.Layer, layer.Note)
+			continue
+		}
+
+		fmt.Fprintf(layers, "%s\t%s\t%s\t%s\n", layer.Layer, layer.Allows, layer.Denies, orDash(layer.Note))
+	}
+
+	if err := layers.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "LAYER\tPOLICY\tSTATEMENT\tEFFECT\tACTION\tRESOURCE\tCONDITION\tAPPLIES")
+
+	for _, layer := range result.Layers {
+		for _, statement := range layer.Statements {
+			name := statement.Path
+			if statement.File != "" {
+				name += fmt.Sprintf(" (%s:%d)", statement.File, statement.Line)
+			}
+
+			index := strconv.Itoa(statement.Statement)
+			if statement.Sid != "" {
+				index += " " + statement.Sid
+			}
+
+			conditions := []string{}
+			for _, condition := range statement.Conditions {
+				conditions = append(conditions, fmt.Sprintf("%s %s=%s got %s: %s", condition.Operator, condition.Key,
+					strings.Join(condition.Values, ","), orDash(strings.Join(condition.Request, ",")), condition.Result))
+			}
+
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s: %s\t%s: %s\t%s\t%s\n", layer.Layer, name, index, statement.Effect,
+				statement.Action, statement.ActionReason, statement.Resource, statement.ResourceReason,
+				orDash(strings.Join(conditions, "; ")), statement.Applies)
+		}
+	}
+
+	return table.Flush()
+}
+
+func writeFindings(w io.Writer, findings []Identity.Finding) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "RULE\tLEVEL\tLOCATION\tPOLICY\tSTATEMENT\tMESSAGE")
+
+	for _, finding := range findings {
+		location := "-"
+		if finding.File != "" {
+			location = fmt.Sprintf("%s:%d", finding.File, finding.Line)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%s\n", finding.RuleID, finding.Level, location,
+			finding.Policy, finding.Statement, finding.Message)
+	}
+
+	return table.Flush()
+}
+
+func writeQuotas(w io.Writer, report Identity.QuotaReport) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "SUBJECT\tQUOTA\tUSED\tLIMIT\tSTATUS")
+
+	for _, usage := range report.Usage {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%s\n", usage.Subject, usage.Quota, usage.Used, usage.Limit, usage.Status)
+	}
+
+	if len(report.Suggestions) > 0 {
+		fmt.Fprintln(table, "\nPOLICY\tSTATEMENTS\tSAVING\tSUGGESTION")
+
+		for _, suggestion := range report.Suggestions {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", suggestion.Policy, strings.Trim(fmt.Sprint(suggestion.Statements), "[]"),
+				suggestion.Saving, suggestion.Change)
+		}
+	}
+
+	return table.Flush()
+}
+
+func writeDoctor(w io.Writer, checks []Identity.DoctorCheck) error {
+	for _, check := range checks {
+		status := "PASS"
+		if !check.Passed {
+			status = "FAIL"
+		}
+
+		if _, err := fmt.Fprintf(w, "%s  %s\n", status, check.Name); err != nil {
+			return err
+		}
+
+		if check.Detail != "" {
+			fmt.Fprintf(w, "      %s\n", check.Detail)
+		}
+
+		if check.Remediation != "" {
+			fmt.Fprintf(w, "      fix: %s\n", check.Remediation)
+		}
+	}
+
+	return nil
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
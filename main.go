@@ -4,16 +4,115 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	Identity "github.com/jameswoolfenden/identity/src"
 	"github.com/rs/zerolog/log"
 )
 
+// Exit codes, so that scripts can tell why a command failed.
+const (
+	exitFailure          = 1
+	exitRoleNotAssumable = 2
+	exitAccessDenied     = 3
+	exitNoSuchEntity     = 4
+	exitThrottled        = 5
+)
+
 func main() {
-	iamIdentity, err := Identity.GetIam(context.Background())
+	ctx := context.Background()
+
+	global := flag.NewFlagSet("identity", flag.ExitOnError)
+	verbose := global.Bool("verbose", false, "report the AWS calls made and how many were retried")
+	policy := Identity.DefaultRetryPolicy()
+	global.IntVar(&policy.MaxAttempts, "max-attempts", policy.MaxAttempts, "attempts per AWS call, including the first")
+	mode := global.String("retry-mode", string(policy.Mode), "retry mode: standard or adaptive")
+	global.DurationVar(&policy.MaxBackoff, "max-backoff", policy.MaxBackoff, "longest delay between retries")
+	global.BoolVar(&policy.Jitter, "jitter", policy.Jitter, "randomise the delay between retries")
+	global.Float64Var(&policy.RequestsPerSecond, "rps", policy.RequestsPerSecond, "most AWS requests per second across all calls, 0 for no limit")
+	noCache := global.Bool("no-cache", false, "fetch every managed policy from IAM instead of the local cache")
+	cacheTTL := global.Duration("cache-ttl", Identity.DefaultCacheTTL, "how long a cached managed policy document is used")
+
+	_ = global.Parse(os.Args[1:])
+
+	policy.Mode = aws.RetryMode(*mode)
+	if err := Identity.SetRetryPolicy(policy); err != nil {
+		log.Error().Err(err).Msg("invalid retry settings")
+		os.Exit(exitFailure)
+	}
+
+	if *noCache {
+		Identity.SetPolicyCache(nil)
+	} else {
+		Identity.SetPolicyCache(&Identity.PolicyCache{TTL: *cacheTTL})
+	}
+
+	err := run(ctx, global.Args())
+
+	if *verbose {
+		reportCalls(Identity.CallStatistics())
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("identity failed")
+		os.Exit(exitCode(err))
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		command, ok := commands[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown command %s", args[0])
+		}
+
+		if err := command(ctx, args[1:]); err != nil {
+			return fmt.Errorf("%s failed: %w", args[0], err)
+		}
+
+		return nil
+	}
+
+	iamIdentity, err := Identity.GetIam(ctx)
 	if err != nil {
-		return
+		return fmt.Errorf("failed to get identity: %w", err)
 	}
 
-	log.Info().Msgf("Identity %s", iamIdentity)
+	log.Info().Msgf("Identity %v", iamIdentity)
+
+	return nil
+}
+
+// reportCalls logs the AWS calls made by operation, with their retries.
+func reportCalls(stats []Identity.CallStats) {
+	var calls, retries, throttled int
+
+	for _, operation := range stats {
+		log.Info().Msgf("%s: %d calls, %d retries, %d throttled", operation.Operation, operation.Calls, operation.Retries(), operation.Throttled)
+
+		calls += operation.Calls
+		retries += operation.Retries()
+		throttled += operation.Throttled
+	}
+
+	log.Info().Msgf("AWS: %d calls, %d retries, %d throttled", calls, retries, throttled)
+}
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, Identity.ErrRoleNotAssumable):
+		return exitRoleNotAssumable
+	case errors.Is(err, Identity.ErrAccessDenied):
+		return exitAccessDenied
+	case errors.Is(err, Identity.ErrNoSuchEntity):
+		return exitNoSuchEntity
+	case errors.Is(err, Identity.ErrThrottled):
+		return exitThrottled
+	default:
+		return exitFailure
+	}
 }
@@ -0,0 +1,201 @@
+package Identity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheDirEnv overrides where managed policy documents are cached.
+const cacheDirEnv = "IDENTITY_CACHE_DIR"
+
+// DefaultCacheTTL is how long a cached policy document is used before it is fetched again.
+const DefaultCacheTTL = 30 * 24 * time.Hour
+
+// lookupTTL is how long the ID and default version of a policy, once asked of IAM, are used: long
+// enough for the principals of one scan to share the lookup.
+const lookupTTL = 5 * time.Minute
+
+// PolicyCache keeps managed policy documents on disk, keyed by policy ARN, policy ID and version ID, so
+// that runs and the principals of a scan share them. The document of a version never changes, and a
+// policy deleted and created again under the same name has a new policy ID, so an entry cannot be stale;
+// the TTL only bounds how long one is trusted. The ID and default version of a policy are asked of IAM
+// again once lookupTTL has passed, and are never kept on disk.
+type PolicyCache struct {
+	// Dir holds the cache; when empty it is DefaultCacheDir.
+	Dir string
+	// TTL is how long an entry is used; zero keeps entries for ever.
+	TTL time.Duration
+
+	mutex   sync.Mutex
+	lookups map[string]policyLookup
+}
+
+// policyLookup is what GetPolicy last said of a policy, and when.
+type policyLookup struct {
+	policyID  string
+	versionID string
+	at        time.Time
+}
+
+type cachedPolicy struct {
+	Arn       string    `json:"Arn"`
+	PolicyID  string    `json:"PolicyId"`
+	VersionID string    `json:"VersionId"`
+	Fetched   time.Time `json:"Fetched"`
+	Document  string    `json:"Document"`
+}
+
+// policyCache is nil, so off, until SetPolicyCache is called; the CLI turns it on, and library callers
+// choose whether to write to disk.
+var (
+	cacheMutex  sync.Mutex
+	policyCache *PolicyCache
+)
+
+// SetPolicyCache sets the cache GetPolicy uses; nil, the default, turns caching off.
+func SetPolicyCache(cache *PolicyCache) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	policyCache = cache
+}
+
+func currentPolicyCache() *PolicyCache {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	return policyCache
+}
+
+// DefaultCacheDir returns IDENTITY_CACHE_DIR if set, and otherwise identity/policies in the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv(cacheDirEnv); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, "identity", "policies"), nil
+}
+
+// lookup returns the ID and default version of a policy if they were looked up within lookupTTL.
+func (c *PolicyCache) lookup(arn string) (policyLookup, bool) {
+	if c == nil {
+		return policyLookup{}, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	lookup, ok := c.lookups[arn]
+	if !ok || time.Since(lookup.at) > lookupTTL {
+		return policyLookup{}, false
+	}
+
+	return lookup, true
+}
+
+func (c *PolicyCache) setLookup(arn string, lookup policyLookup) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.lookups == nil {
+		c.lookups = map[string]policyLookup{}
+	}
+
+	lookup.at = time.Now()
+	c.lookups[arn] = lookup
+}
+
+// Get returns the cached document of a policy version, if there is one within the TTL.
+func (c *PolicyCache) Get(arn string, policyID string, versionID string) (string, bool) {
+	path, err := c.path(arn, policyID, versionID)
+	if err != nil {
+		return "", false
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	var entry cachedPolicy
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.Arn != arn || entry.PolicyID != policyID || entry.VersionID != versionID {
+		return "", false
+	}
+
+	if c.TTL > 0 && time.Since(entry.Fetched) > c.TTL {
+		return "", false
+	}
+
+	return entry.Document, true
+}
+
+// Put caches the document of a policy version.
+func (c *PolicyCache) Put(arn string, policyID string, versionID string, document string) error {
+	path, err := c.path(arn, policyID, versionID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(cachedPolicy{Arn: arn, PolicyID: policyID, VersionID: versionID, Fetched: time.Now().UTC(), Document: document})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create policy cache: %w", err)
+	}
+
+	// Write and rename, so that concurrent runs never read half an entry
+	temp, err := os.CreateTemp(filepath.Dir(path), ".policy-*")
+	if err != nil {
+		return fmt.Errorf("failed to write policy cache: %w", err)
+	}
+
+	if _, err := temp.Write(raw); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+
+		return fmt.Errorf("failed to write policy cache: %w", err)
+	}
+
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+
+		return fmt.Errorf("failed to write policy cache: %w", err)
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+func (c *PolicyCache) path(arn string, policyID string, versionID string) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("policy cache is off")
+	}
+
+	dir := c.Dir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256([]byte(arn + "\x00" + policyID + "\x00" + versionID))
+
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
@@ -0,0 +1,143 @@
+package Identity
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPolicyCache(t *testing.T) {
+	arn := "arn:aws:iam::aws:policy/ReadOnlyAccess"
+
+	tests := []struct {
+		name     string
+		cache    *PolicyCache
+		policyID string
+		version  string
+		want     bool
+	}{
+		{"hit", &PolicyCache{TTL: time.Hour}, "ANPAONE", "v1", true},
+		{"no_expiry", &PolicyCache{}, "ANPAONE", "v1", true},
+		{"other_version", &PolicyCache{TTL: time.Hour}, "ANPAONE", "v2", false},
+		{"recreated_policy", &PolicyCache{TTL: time.Hour}, "ANPATWO", "v1", false},
+		{"expired", &PolicyCache{TTL: time.Nanosecond}, "ANPAONE", "v1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cache.Dir = t.TempDir()
+
+			if err := tt.cache.Put(arn, "ANPAONE", "v1", `{"Version":"2012-10-17"}`); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			time.Sleep(time.Millisecond)
+
+			got, ok := tt.cache.Get(arn, tt.policyID, tt.version)
+			if ok != tt.want || (ok && got != `{"Version":"2012-10-17"}`) {
+				t.Errorf("Get() = %q, %v, want hit %v", got, ok, tt.want)
+			}
+		})
+	}
+
+	var off *PolicyCache
+	if _, ok := off.Get(arn, "ANPAONE", "v1"); ok {
+		t.Errorf("Get() on a nil cache hit")
+	}
+
+	if err := off.Put(arn, "ANPAONE", "v1", "{}"); err == nil {
+		t.Errorf("Put() on a nil cache error = nil")
+	}
+}
+
+func TestPolicyCacheOffByDefault(t *testing.T) {
+	if cache := currentPolicyCache(); cache != nil {
+		t.Errorf("currentPolicyCache() = %+v, want nil until SetPolicyCache is called", cache)
+	}
+}
+
+func TestGetPolicyCached(t *testing.T) {
+	fake := useFakeAWS(t, "account.json")
+
+	ctx := context.Background()
+	account := IAM{Account: "680235478471"}
+	arn := "arn:aws:iam::680235478471:policy/test-policy"
+
+	tests := []struct {
+		name string
+		// before changes the account or cache, as between runs
+		before     func()
+		getPolicy  int
+		getVersion int
+		wantSid    string
+	}{
+		{"first_run", func() { SetPolicyCache(&PolicyCache{TTL: DefaultCacheTTL}) }, 1, 1, ""},
+		{"same_run", func() {}, 0, 0, ""},
+		{
+			"lookup_expired",
+			func() {
+				cache := currentPolicyCache()
+				lookup := cache.lookups[arn]
+				lookup.at = lookup.at.Add(-lookupTTL - time.Second)
+				cache.lookups[arn] = lookup
+			},
+			1, 0, "",
+		},
+		{"next_run", func() { SetPolicyCache(&PolicyCache{TTL: DefaultCacheTTL}) }, 1, 0, ""},
+		{
+			"new_default_version",
+			func() {
+				SetPolicyCache(&PolicyCache{TTL: DefaultCacheTTL})
+
+				policy := &fake.account.Policies[0]
+				policy.Versions = append(policy.Versions, fakePolicyVersion{VersionID: "v2", Document: `{"Version":"2012-10-17","Statement":[{"Sid":"v2","Effect":"Allow","Action":"s3:ListBucket","Resource":"*"}]}`})
+				policy.DefaultVersionID = "v2"
+			},
+			1, 1, "v2",
+		},
+		{
+			"recreated_policy",
+			func() {
+				SetPolicyCache(&PolicyCache{TTL: DefaultCacheTTL})
+
+				policy := &fake.account.Policies[0]
+				policy.PolicyID = "ANPARECREATED"
+				policy.Versions = []fakePolicyVersion{{VersionID: "v1", Document: `{"Version":"2012-10-17","Statement":[{"Sid":"recreated","Effect":"Allow","Action":"s3:ListBucket","Resource":"*"}]}`}}
+				policy.DefaultVersionID = ""
+			},
+			1, 1, "recreated",
+		},
+		{"no_cache", func() { SetPolicyCache(nil) }, 1, 1, "recreated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.before()
+			ResetCallStatistics()
+
+			document, err := GetPolicy(ctx, arn, account)
+			if err != nil {
+				t.Fatalf("GetPolicy() error = %v", err)
+			}
+
+			policy, err := Parse(*document)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sid := policy.Statements[0].Sid; sid != tt.wantSid {
+				t.Errorf("GetPolicy() = %s, want the version with Sid %q", *document, tt.wantSid)
+			}
+
+			calls := map[string]int{}
+			for _, stats := range CallStatistics() {
+				calls[stats.Operation] = stats.Calls
+			}
+
+			if calls["IAM.GetPolicy"] != tt.getPolicy || calls["IAM.GetPolicyVersion"] != tt.getVersion {
+				t.Errorf("GetPolicy() called GetPolicy %d and GetPolicyVersion %d times, want %d and %d",
+					calls["IAM.GetPolicy"], calls["IAM.GetPolicyVersion"], tt.getPolicy, tt.getVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package Identity
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Access levels used by the action catalogue, named as in the AWS service authorization reference.
+const (
+	ListLevel        = "List"
+	ReadLevel        = "Read"
+	WriteLevel       = "Write"
+	PermissionsLevel = "Permissions management"
+	TaggingLevel     = "Tagging"
+	UnknownLevel     = "Unknown"
+)
+
+//go:generate go run data/generate.go
+
+//go:embed data/actions.json
+var rawCatalogue []byte
+
+// catalogue maps a lower-cased service prefix to its actions and their access levels.
+var catalogue = loadCatalogue(rawCatalogue)
+
+func loadCatalogue(raw []byte) map[string]map[string]string {
+	var services map[string]map[string]string
+
+	if err := json.Unmarshal(raw, &services); err != nil {
+		panic("invalid embedded action catalogue: " + err.Error())
+	}
+
+	result := make(map[string]map[string]string, len(services))
+
+	for service, actions := range services {
+		result[strings.ToLower(service)] = actions
+	}
+
+	return result
+}
+
+// SplitAction splits an action such as s3:GetObject into its service prefix and name.
+func SplitAction(action string) (service string, name string) {
+	service, name, found := strings.Cut(action, ":")
+	if !found {
+		return "", action
+	}
+
+	return strings.ToLower(service), name
+}
+
+//go:embed data/complete.json
+var rawComplete []byte
+
+// completeServices are the services whose every action the catalogue lists, so that a wildcard for them
+// matches nothing beyond its catalogue expansion. data/generate.go records each service it fetches from
+// the service authorization reference; add a hand-written service only after checking it there.
+var completeServices = loadComplete(rawComplete)
+
+func loadComplete(raw []byte) map[string]bool {
+	var services []string
+
+	if err := json.Unmarshal(raw, &services); err != nil {
+		panic("invalid embedded list of complete services: " + err.Error())
+	}
+
+	result := make(map[string]bool, len(services))
+
+	for _, service := range services {
+		result[strings.ToLower(service)] = true
+	}
+
+	return result
+}
+
+// completeService reports whether the catalogue lists every action of a service.
+func completeService(service string) bool {
+	return completeServices[strings.ToLower(service)]
+}
+
+// KnownService reports whether the catalogue lists the actions of a service.
+func KnownService(service string) bool {
+	_, ok := catalogue[strings.ToLower(service)]
+
+	return ok
+}
+
+// ExpandAction returns the concrete catalogue actions that an action pattern such as s3:Get* matches.
+// Patterns for services missing from the catalogue are returned unchanged, as is * alongside its expansion,
+// and so is a concrete action the catalogue does not list, since the catalogue is not complete.
+func ExpandAction(pattern string) []string {
+	service, name := SplitAction(pattern)
+
+	if service == "" && name == "*" {
+		var all []string
+		for known := range catalogue {
+			all = append(all, ExpandAction(known+":*")...)
+		}
+
+		sort.Strings(all)
+
+		// keep * itself, which also stands for the services the catalogue does not list
+		return append([]string{"*"}, all...)
+	}
+
+	actions, ok := catalogue[service]
+	if !ok {
+		return []string{pattern}
+	}
+
+	if !strings.ContainsAny(name, "*?") && AccessLevel(pattern) == UnknownLevel {
+		return []string{pattern}
+	}
+
+	var matched []string
+
+	for action := range actions {
+		if MatchAction(name, action) {
+			matched = append(matched, service+":"+action)
+		}
+	}
+
+	sort.Strings(matched)
+
+	return matched
+}
+
+// AccessLevel returns the catalogue access level of a concrete action, or UnknownLevel.
+func AccessLevel(action string) string {
+	service, name := SplitAction(action)
+
+	for known, level := range catalogue[service] {
+		if strings.EqualFold(known, name) {
+			return level
+		}
+	}
+
+	return UnknownLevel
+}
+
+// MatchAction reports whether an action matches an action pattern; action names are case-insensitive.
+func MatchAction(pattern string, action string) bool {
+	return WildcardMatch(strings.ToLower(pattern), strings.ToLower(action))
+}
+
+// WildcardMatch reports whether value matches an IAM pattern using * and ?.
+func WildcardMatch(pattern string, value string) bool {
+	return wildcardMatch([]rune(pattern), []rune(value))
+}
+
+func wildcardMatch(pattern []rune, value []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := 0; i <= len(value); i++ {
+				if wildcardMatch(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+		default:
+			if len(value) == 0 || pattern[0] != value[0] {
+				return false
+			}
+		}
+
+		pattern = pattern[1:]
+		value = value[1:]
+	}
+
+	return len(value) == 0
+}
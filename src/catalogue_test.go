@@ -0,0 +1,89 @@
+package Identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandAction(t *testing.T) {
+	type args struct {
+		pattern string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{"concrete", args{"s3:GetObject"}, []string{"s3:GetObject"}},
+		{"wildcard", args{"sts:Get*"}, []string{"sts:GetAccessKeyInfo", "sts:GetCallerIdentity", "sts:GetFederationToken", "sts:GetSessionToken"}},
+		{"case", args{"STS:getcalleridentity"}, []string{"sts:GetCallerIdentity"}},
+		{"question", args{"sqs:?etQueueUrl"}, []string{"sqs:GetQueueUrl"}},
+		{"unknown_service", args{"made-up:Do*"}, []string{"made-up:Do*"}},
+		{"not_catalogued", args{"ec2:ModifyInstanceMetadataOptions"}, []string{"ec2:ModifyInstanceMetadataOptions"}},
+		{"no_match", args{"s3:Frobni*"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandAction(tt.args.pattern); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandAction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		want   string
+	}{
+		{"read", "s3:GetObject", ReadLevel},
+		{"list", "iam:ListRoles", ListLevel},
+		{"permissions", "iam:AttachRolePolicy", PermissionsLevel},
+		{"case", "S3:putobject", WriteLevel},
+		{"unknown", "made-up:Thing", UnknownLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AccessLevel(tt.action); got != tt.want {
+				t.Errorf("AccessLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"star", "*", "arn:aws:s3:::bucket", true},
+		{"prefix", "arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket/key", true},
+		{"middle", "arn:aws:s3:::*-logs", "arn:aws:s3:::prod-logs", true},
+		{"question", "a?c", "abc", true},
+		{"question_empty", "a?", "a", false},
+		{"case_sensitive", "arn:aws:s3:::Bucket", "arn:aws:s3:::bucket", false},
+		{"mismatch", "arn:aws:s3:::other/*", "arn:aws:s3:::bucket/key", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WildcardMatch(tt.pattern, tt.value); got != tt.want {
+				t.Errorf("WildcardMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteServices(t *testing.T) {
+	for service := range completeServices {
+		if !KnownService(service) {
+			t.Errorf("complete service %s is not in the catalogue", service)
+		}
+	}
+
+	if got := loadComplete([]byte(`["SQS"]`)); !reflect.DeepEqual(got, map[string]bool{"sqs": true}) {
+		t.Errorf("loadComplete() = %v, want sqs", got)
+	}
+}
@@ -0,0 +1,271 @@
+package Identity
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrailEvent is the part of a CloudTrail record needed to work out which permission was used.
+type TrailEvent struct {
+	Time      time.Time
+	Action    string
+	Principal string
+	Issuer    string
+	Resources []string
+	ErrorCode string
+}
+
+// TrailUsage is a least-privilege policy built from CloudTrail, and the granted actions that went unused.
+type TrailUsage struct {
+	Policy Policy   `json:"Policy"`
+	Unused []string `json:"Unused"`
+}
+
+type trailLog struct {
+	Records []trailRecord `json:"Records"`
+}
+
+type trailRecord struct {
+	EventTime    time.Time `json:"eventTime"`
+	EventSource  string    `json:"eventSource"`
+	EventName    string    `json:"eventName"`
+	ErrorCode    string    `json:"errorCode"`
+	UserIdentity struct {
+		Arn            string `json:"arn"`
+		SessionContext struct {
+			SessionIssuer struct {
+				Arn string `json:"arn"`
+			} `json:"sessionIssuer"`
+		} `json:"sessionContext"`
+	} `json:"userIdentity"`
+	Resources []struct {
+		ARN string `json:"ARN"`
+	} `json:"resources"`
+}
+
+// eventSources lists the CloudTrail event sources whose host name differs from the IAM service prefix.
+var eventSources = map[string]string{
+	"monitoring":       "cloudwatch",
+	"email":            "ses",
+	"streams.dynamodb": "dynamodb",
+}
+
+// eventNames lists CloudTrail event names that differ from the action they authorise.
+var eventNames = map[string]string{
+	"lambda:Invoke": "lambda:InvokeFunction",
+}
+
+// apiVersionSuffix matches the API version some services append to event names, such as UpdateFunctionCode20150331v2.
+var apiVersionSuffix = regexp.MustCompile(`20\d{6}(v\d+)?$`)
+
+// denialCodes are error codes that mean the call was refused, so the permission was not held.
+var denialCodes = map[string]bool{
+	"AccessDenied":                       true,
+	"AccessDeniedException":              true,
+	"UnauthorizedOperation":              true,
+	"Client.UnauthorizedOperation":       true,
+	"UnauthorizedAccess":                 true,
+	"AuthorizationErrorException":        true,
+	"UnrecognizedClientException":        true,
+	"InvalidClientTokenId":               true,
+	"ExpiredToken":                       true,
+	"ExpiredTokenException":              true,
+	"SignatureDoesNotMatch":              true,
+	"AuthFailure":                        true,
+	"AccessDeniedForDependencyException": true,
+}
+
+// ReadCloudTrail reads the records of CloudTrail log files, decompressing .gz files.
+// Directories are walked for .json and .json.gz files.
+func ReadCloudTrail(paths []string) ([]TrailEvent, error) {
+	var events []TrailEvent
+
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() || (file != path && !strings.HasSuffix(file, ".json") && !strings.HasSuffix(file, ".json.gz")) {
+				return nil
+			}
+
+			found, err := readTrailFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+
+			events = append(events, found...)
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+func readTrailFile(path string) ([]TrailEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if strings.HasSuffix(path, ".gz") {
+		unzipped, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+
+		defer unzipped.Close()
+
+		reader = unzipped
+	}
+
+	var raw trailLog
+
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	events := make([]TrailEvent, 0, len(raw.Records))
+
+	for _, record := range raw.Records {
+		event := TrailEvent{
+			Time:      record.EventTime,
+			Action:    EventAction(record.EventSource, record.EventName),
+			Principal: record.UserIdentity.Arn,
+			Issuer:    record.UserIdentity.SessionContext.SessionIssuer.Arn,
+			ErrorCode: record.ErrorCode,
+		}
+
+		for _, resource := range record.Resources {
+			if resource.ARN != "" {
+				event.Resources = append(event.Resources, resource.ARN)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// EventAction converts a CloudTrail event source and name, such as s3.amazonaws.com and GetObject, into an action.
+func EventAction(source string, name string) string {
+	service := strings.TrimSuffix(source, ".amazonaws.com")
+	if mapped, ok := eventSources[service]; ok {
+		service = mapped
+	}
+
+	action := service + ":" + apiVersionSuffix.ReplaceAllString(name, "")
+	if mapped, ok := eventNames[action]; ok {
+		return mapped
+	}
+
+	return action
+}
+
+// MatchesPrincipal reports whether an event was made by an identity, either directly or through a role session.
+func (e TrailEvent) MatchesPrincipal(ident IAM) bool {
+	arn := FormatArn(ident)
+
+	return e.Principal == arn || e.Issuer == arn
+}
+
+// UsedActions returns the resources each action was used on by an identity between from and to.
+// A zero from or to leaves that end of the window open; refused calls are ignored.
+func UsedActions(events []TrailEvent, ident IAM, from time.Time, to time.Time) map[string][]string {
+	used := map[string][]string{}
+
+	for _, event := range events {
+		if !event.MatchesPrincipal(ident) || denialCodes[event.ErrorCode] {
+			continue
+		}
+
+		if (!from.IsZero() && event.Time.Before(from)) || (!to.IsZero() && event.Time.After(to)) {
+			continue
+		}
+
+		resources := event.Resources
+		if len(resources) == 0 {
+			resources = []string{"*"}
+		}
+
+		used[event.Action] = compactResources(append(used[event.Action], resources...))
+	}
+
+	return used
+}
+
+// LeastPrivilege builds a policy that allows exactly the used actions, one statement per resource set.
+func LeastPrivilege(used map[string][]string) Policy {
+	policy := NewPolicy()
+	policy.Version = "2012-10-17"
+
+	byResources := map[string]*Statement{}
+
+	var keys []string
+
+	for action, resources := range used {
+		key := strings.Join(resources, "\n")
+
+		statement, ok := byResources[key]
+		if !ok {
+			statement = &Statement{Effect: Allow, Resource: resources}
+			byResources[key] = statement
+			keys = append(keys, key)
+		}
+
+		statement.Action = append(statement.Action, action)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		statement := byResources[key]
+		sort.Strings(statement.Action)
+		policy.Statements = append(policy.Statements, *statement)
+	}
+
+	return policy
+}
+
+// TrailReport generates the least-privilege policy for an identity from CloudTrail events and lists
+// the concrete actions its current policies allow that it did not use in the window.
+func TrailReport(events []TrailEvent, ident IAM, from time.Time, to time.Time) TrailUsage {
+	used := UsedActions(events, ident, from, to)
+
+	usedActions := map[string]bool{}
+	for action := range used {
+		usedActions[strings.ToLower(action)] = true
+	}
+
+	unused := []string{}
+	matrix := Effective(ident)
+
+	for _, service := range matrix.Services() {
+		for _, permission := range matrix[service] {
+			if permission.Effect == Allow && !usedActions[strings.ToLower(permission.Action)] {
+				unused = append(unused, permission.Action)
+			}
+		}
+	}
+
+	return TrailUsage{Policy: LeastPrivilege(used), Unused: unused}
+}
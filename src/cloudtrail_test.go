@@ -0,0 +1,103 @@
+package Identity
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const trailFixture = `{"Records": [
+	{"eventTime": "2026-01-02T10:00:00Z", "eventSource": "s3.amazonaws.com", "eventName": "GetObject",
+	 "userIdentity": {"arn": "arn:aws:sts::123456789012:assumed-role/deploy/session",
+	  "sessionContext": {"sessionIssuer": {"arn": "arn:aws:iam::123456789012:role/deploy"}}},
+	 "resources": [{"ARN": "arn:aws:s3:::bucket/key"}, {"ARN": "arn:aws:s3:::bucket"}]},
+	{"eventTime": "2026-01-03T10:00:00Z", "eventSource": "sts.amazonaws.com", "eventName": "GetCallerIdentity",
+	 "userIdentity": {"arn": "arn:aws:sts::123456789012:assumed-role/deploy/session",
+	  "sessionContext": {"sessionIssuer": {"arn": "arn:aws:iam::123456789012:role/deploy"}}}},
+	{"eventTime": "2026-01-03T11:00:00Z", "eventSource": "iam.amazonaws.com", "eventName": "CreateUser", "errorCode": "AccessDenied",
+	 "userIdentity": {"arn": "arn:aws:sts::123456789012:assumed-role/deploy/session",
+	  "sessionContext": {"sessionIssuer": {"arn": "arn:aws:iam::123456789012:role/deploy"}}}},
+	{"eventTime": "2026-01-03T12:00:00Z", "eventSource": "s3.amazonaws.com", "eventName": "PutObject",
+	 "userIdentity": {"arn": "arn:aws:iam::123456789012:user/someone-else"}},
+	{"eventTime": "2025-06-01T12:00:00Z", "eventSource": "sqs.amazonaws.com", "eventName": "SendMessage",
+	 "userIdentity": {"arn": "arn:aws:sts::123456789012:assumed-role/deploy/session",
+	  "sessionContext": {"sessionIssuer": {"arn": "arn:aws:iam::123456789012:role/deploy"}}}}
+]}`
+
+func TestReadCloudTrail(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := os.Create(filepath.Join(dir, "trail.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write([]byte(trailFixture)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a trail"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadCloudTrail([]string{dir})
+	if err != nil {
+		t.Fatalf("ReadCloudTrail() error = %v", err)
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("ReadCloudTrail() read %d events, want 5", len(events))
+	}
+
+	deploy := IAM{Name: "deploy", Account: "123456789012", IamType: RoleType, Policies: []Policy{{
+		Version:    "2012-10-17",
+		Statements: []Statement{{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject", "sts:GetCallerIdentity"}, Resource: []string{"*"}}},
+	}}}
+
+	got := TrailReport(events, deploy, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+
+	want := TrailUsage{
+		Policy: Policy{Version: "2012-10-17", Statements: []Statement{
+			{Effect: "Allow", Action: []string{"sts:GetCallerIdentity"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::bucket", "arn:aws:s3:::bucket/key"}},
+		}},
+		Unused: []string{"s3:PutObject"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TrailReport() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEventAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		event  string
+		want   string
+	}{
+		{"plain", "s3.amazonaws.com", "GetObject", "s3:GetObject"},
+		{"renamed_service", "monitoring.amazonaws.com", "PutMetricData", "cloudwatch:PutMetricData"},
+		{"versioned", "lambda.amazonaws.com", "UpdateFunctionCode20150331v2", "lambda:UpdateFunctionCode"},
+		{"renamed_event", "lambda.amazonaws.com", "Invoke", "lambda:InvokeFunction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EventAction(tt.source, tt.event); got != tt.want {
+				t.Errorf("EventAction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+//go:build ignore
+
+// generate refreshes actions.json and complete.json from the AWS service authorization reference,
+// which lists every action of every service with its access level. Run it from src with
+//
+//	go generate ./...
+//
+// or name the services to refresh, such as go run data/generate.go s3 ec2. The services it fetches
+// replace their hand-written entries and are recorded as complete.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	referenceIndex = "https://servicereference.us-east-1.amazonaws.com/"
+	actionsPath    = "data/actions.json"
+	completePath   = "data/complete.json"
+)
+
+type serviceEntry struct {
+	Service string `json:"service"`
+	URL     string `json:"url"`
+}
+
+type serviceReference struct {
+	Name    string `json:"Name"`
+	Actions []struct {
+		Name        string `json:"Name"`
+		Annotations struct {
+			Properties struct {
+				IsList                 bool `json:"IsList"`
+				IsPermissionManagement bool `json:"IsPermissionManagement"`
+				IsTaggingOnly          bool `json:"IsTaggingOnly"`
+				IsWrite                bool `json:"IsWrite"`
+			} `json:"Properties"`
+		} `json:"Annotations"`
+	} `json:"Actions"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(only []string) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var index []serviceEntry
+	if err := fetch(client, referenceIndex, &index); err != nil {
+		return err
+	}
+
+	wanted := map[string]bool{}
+	for _, service := range only {
+		wanted[strings.ToLower(service)] = true
+	}
+
+	actions := map[string]map[string]string{}
+	if err := readJSON(actionsPath, &actions); err != nil {
+		return err
+	}
+
+	var complete []string
+	if err := readJSON(completePath, &complete); err != nil {
+		return err
+	}
+
+	done := map[string]bool{}
+	for _, service := range complete {
+		done[service] = true
+	}
+
+	for _, entry := range index {
+		service := strings.ToLower(entry.Service)
+		if len(wanted) > 0 && !wanted[service] {
+			continue
+		}
+
+		var reference serviceReference
+		if err := fetch(client, entry.URL, &reference); err != nil {
+			return err
+		}
+
+		levels := make(map[string]string, len(reference.Actions))
+		for _, action := range reference.Actions {
+			levels[action.Name] = accessLevel(action.Annotations.Properties.IsList, action.Annotations.Properties.IsPermissionManagement,
+				action.Annotations.Properties.IsTaggingOnly, action.Annotations.Properties.IsWrite)
+		}
+
+		if len(levels) == 0 {
+			return fmt.Errorf("no actions listed for %s", service)
+		}
+
+		actions[service] = levels
+		done[service] = true
+		delete(wanted, service)
+	}
+
+	if len(wanted) > 0 {
+		return fmt.Errorf("services not in the service authorization reference: %v", sortedKeys(wanted))
+	}
+
+	if err := writeJSON(actionsPath, actions); err != nil {
+		return err
+	}
+
+	return writeJSON(completePath, sortedKeys(done))
+}
+
+// accessLevel names an action's access level as catalogue.go does, from the reference's annotations.
+func accessLevel(list bool, permissions bool, tagging bool, write bool) string {
+	switch {
+	case permissions:
+		return "Permissions management"
+	case tagging:
+		return "Tagging"
+	case write:
+		return "Write"
+	case list:
+		return "List"
+	}
+
+	return "Read"
+}
+
+func fetch(client *http.Client, url string, value interface{}) error {
+	response, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: %s", url, response.Status)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	if err := json.Unmarshal(body, value); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+
+	return nil
+}
+
+func readJSON(path string, value interface{}) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(raw, value); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func writeJSON(path string, value interface{}) error {
+	raw, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,265 @@
+package Identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy document formats.
+const (
+	JSONFormat = "json"
+	YAMLFormat = "yaml"
+	HCLFormat  = "hcl"
+	AutoFormat = "auto"
+)
+
+// ParseYAML parses a policy document written in YAML, as in a CloudFormation template.
+func ParseYAML(raw string) (Policy, error) {
+	return ParseWithOptions(raw, ParseOptions{Format: YAMLFormat})
+}
+
+// ParseHCL parses a policy document written as a Terraform object, such as the argument of jsonencode.
+func ParseHCL(raw string) (Policy, error) {
+	return ParseWithOptions(raw, ParseOptions{Format: HCLFormat})
+}
+
+// DetectFormat guesses the format of a policy document: JSON when it is valid JSON, HCL when it is an
+// object or jsonencode call that is not, and otherwise YAML.
+func DetectFormat(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+
+	switch {
+	case json.Valid([]byte(trimmed)):
+		return JSONFormat
+	case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "jsonencode("):
+		return HCLFormat
+	}
+
+	return YAMLFormat
+}
+
+// decodeDocument decodes a policy document into plain values, with the byte offset of each value by JSON path.
+func decodeDocument(raw string, format string) (interface{}, map[string]int64, error) {
+	if format == AutoFormat {
+		format = DetectFormat(raw)
+	}
+
+	switch format {
+	case "", JSONFormat:
+		var document interface{}
+
+		if err := json.Unmarshal([]byte(raw), &document); err != nil {
+			var syntaxError *json.SyntaxError
+			if errors.As(err, &syntaxError) {
+				return nil, nil, ParseErrors{{Statement: -1, Offset: syntaxError.Offset, Expected: "valid JSON", Actual: syntaxError.Error()}}
+			}
+
+			return nil, nil, err
+		}
+
+		return document, jsonOffsets([]byte(raw)), nil
+	case YAMLFormat:
+		return decodeYAML(raw)
+	case HCLFormat:
+		return decodeHCL(raw)
+	}
+
+	return nil, nil, fmt.Errorf("unknown policy format %s", format)
+}
+
+func decodeHCL(raw string) (interface{}, map[string]int64, error) {
+	src := []byte(raw)
+
+	expr, diags := hclsyntax.ParseExpression(src, "policy.hcl", hcl.Pos{Line: 1, Column: 1, Byte: 0})
+	if diags.HasErrors() {
+		parseErrors := ParseErrors{}
+
+		for _, diag := range diags {
+			offset := int64(-1)
+			if diag.Subject != nil {
+				offset = int64(diag.Subject.Start.Byte)
+			}
+
+			parseErrors = append(parseErrors, &ParseError{Statement: -1, Offset: offset, Expected: "valid HCL", Actual: diag.Summary + ": " + diag.Detail})
+		}
+
+		return nil, nil, parseErrors
+	}
+
+	if call, ok := expr.(*hclsyntax.FunctionCallExpr); ok && call.Name == "jsonencode" && len(call.Args) == 1 {
+		expr = call.Args[0]
+	}
+
+	offsets := map[string]int64{}
+
+	return hclValueAt(expr, src, "", offsets), offsets, nil
+}
+
+func decodeYAML(raw string) (interface{}, map[string]int64, error) {
+	var root yaml.Node
+
+	if err := yaml.Unmarshal([]byte(raw), &root); err != nil {
+		return nil, nil, ParseErrors{{Statement: -1, Offset: -1, Expected: "valid YAML", Actual: err.Error()}}
+	}
+
+	if err := checkYAMLAliases(&root); err != nil {
+		return nil, nil, ParseErrors{{Statement: -1, Offset: -1, Expected: "valid YAML", Actual: err.Error()}}
+	}
+
+	lineStarts := []int64{0}
+	for i, c := range raw {
+		if c == '\n' {
+			lineStarts = append(lineStarts, int64(i+1))
+		}
+	}
+
+	offsets := map[string]int64{}
+
+	var walk func(node *yaml.Node, path string) interface{}
+
+	walk = func(node *yaml.Node, path string) interface{} {
+		if node.Line > 0 && node.Line <= len(lineStarts) {
+			offsets[path] = lineStarts[node.Line-1] + int64(node.Column-1)
+		}
+
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+
+			return walk(node.Content[0], path)
+		case yaml.AliasNode:
+			return walk(node.Alias, path)
+		case yaml.MappingNode:
+			if customTag(node.Tag) {
+				return yamlValue(node)
+			}
+
+			object := map[string]interface{}{}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				object[key] = walk(node.Content[i+1], joinPath(path, key))
+			}
+
+			return object
+		case yaml.SequenceNode:
+			if customTag(node.Tag) {
+				return yamlValue(node)
+			}
+
+			items := make([]interface{}, 0, len(node.Content))
+			for i, item := range node.Content {
+				items = append(items, walk(item, fmt.Sprintf("%s[%d]", path, i)))
+			}
+
+			return items
+		}
+
+		return yamlScalar(node)
+	}
+
+	return walk(&root, ""), offsets, nil
+}
+
+// maxYAMLAliasNodes caps the nodes that aliases may add to a YAML document, so that nested aliases (a
+// "billion laughs") are rejected rather than expanded.
+const maxYAMLAliasNodes = 10000
+
+// checkYAMLAliases returns an error if expanding the aliases under root would add more than
+// maxYAMLAliasNodes nodes, or if an alias refers to a node that contains it.
+func checkYAMLAliases(root *yaml.Node) error {
+	var count func(node *yaml.Node) int
+
+	count = func(node *yaml.Node) int {
+		total := 1
+		for _, child := range node.Content {
+			total += count(child)
+		}
+
+		return total
+	}
+
+	limit := count(root) + maxYAMLAliasNodes
+
+	const inProgress = -1
+
+	sizes := map[*yaml.Node]int{}
+
+	var size func(node *yaml.Node) (int, error)
+
+	size = func(node *yaml.Node) (int, error) {
+		if known, ok := sizes[node]; ok {
+			if known == inProgress {
+				return 0, errors.New("YAML alias refers to a node that contains it")
+			}
+
+			return known, nil
+		}
+
+		sizes[node] = inProgress
+
+		children := node.Content
+		if node.Kind == yaml.AliasNode && node.Alias != nil {
+			children = []*yaml.Node{node.Alias}
+		}
+
+		total := 1
+
+		for _, child := range children {
+			childSize, err := size(child)
+			if err != nil {
+				return 0, err
+			}
+
+			total += childSize
+			if total > limit {
+				return 0, fmt.Errorf("YAML aliases expand to more than %d nodes", maxYAMLAliasNodes)
+			}
+		}
+
+		sizes[node] = total
+
+		return total, nil
+	}
+
+	_, err := size(root)
+
+	return err
+}
+
+// yamlScalar converts a scalar to the JSON type YAML resolves it to. Timestamps, such as an unquoted
+// Version of 2012-10-17, stay strings, and CloudFormation intrinsic functions become interpolations.
+func yamlScalar(node *yaml.Node) interface{} {
+	switch node.Tag {
+	case "!!null":
+		return nil
+	case "!!bool":
+		value, err := strconv.ParseBool(strings.ToLower(node.Value))
+		if err == nil {
+			return value
+		}
+	case "!!int", "!!float":
+		var value float64
+		if err := node.Decode(&value); err == nil {
+			return value
+		}
+	}
+
+	if customTag(node.Tag) {
+		return yamlValue(node)
+	}
+
+	return node.Value
+}
+
+func customTag(tag string) bool {
+	return strings.HasPrefix(tag, "!") && !strings.HasPrefix(tag, "!!")
+}
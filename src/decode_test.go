@@ -0,0 +1,222 @@
+package Identity
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// yamlAliasBomb nests aliases nine deep, ten to a list, so that expanded it has a billion leaves.
+func yamlAliasBomb() string {
+	var builder strings.Builder
+
+	builder.WriteString("a0: &a0 [" + strings.TrimSuffix(strings.Repeat("lol, ", 10), ", ") + "]\n")
+
+	for i := 1; i < 9; i++ {
+		alias := fmt.Sprintf("*a%d", i-1)
+		builder.WriteString(fmt.Sprintf("a%d: &a%d [%s]\n", i, i, strings.TrimSuffix(strings.Repeat(alias+", ", 10), ", ")))
+	}
+
+	return builder.String()
+}
+
+func TestParseFormats(t *testing.T) {
+	want := Policy{Version: "2012-10-17", Statements: []Statement{{
+		Sid:       "Read",
+		Effect:    "Allow",
+		Action:    []string{"s3:GetObject", "s3:ListBucket"},
+		Resource:  []string{"*"},
+		Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+	}}}
+
+	tests := []struct {
+		name   string
+		raw    string
+		format string
+		lone   bool
+	}{
+		{
+			name:   "json",
+			raw:    `{"Version": "2012-10-17", "Statement": [{"Sid": "Read", "Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": "*", "Condition": {"Bool": {"aws:SecureTransport": "true"}}}]}`,
+			format: JSONFormat,
+		},
+		{
+			name: "yaml",
+			raw: `Version: 2012-10-17
+Statement:
+  - Sid: Read
+    Effect: Allow
+    Action:
+      - s3:GetObject
+      - s3:ListBucket
+    Resource: "*"
+    Condition:
+      Bool:
+        aws:SecureTransport: true
+`,
+			format: YAMLFormat,
+		},
+		{
+			name: "hcl",
+			raw: `{
+  Version = "2012-10-17"
+  Statement = [{
+    Sid      = "Read"
+    Effect   = "Allow"
+    Action   = ["s3:GetObject", "s3:ListBucket"]
+    Resource = "*"
+    Condition = {
+      Bool = { "aws:SecureTransport" = "true" }
+    }
+  }]
+}`,
+			format: HCLFormat,
+		},
+		{
+			name:   "jsonencode",
+			raw:    `jsonencode({ Version = "2012-10-17", Statement = [{ Sid = "Read", Effect = "Allow", Action = ["s3:GetObject", "s3:ListBucket"], Resource = "*", Condition = { Bool = { "aws:SecureTransport" = "true" } } }] })`,
+			format: AutoFormat,
+		},
+		{
+			name:   "auto_yaml",
+			raw:    "Version: '2012-10-17'\nStatement:\n  Sid: Read\n  Effect: Allow\n  Action: [s3:GetObject, s3:ListBucket]\n  Resource: '*'\n  Condition: {Bool: {aws:SecureTransport: 'true'}}\n",
+			format: AutoFormat,
+			lone:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.raw, ParseOptions{Strict: true, Format: tt.format})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+
+			want := want
+			want.loneStatement = tt.lone
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseWithOptions() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseFormatErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		format string
+		strict bool
+		want   []ParseError
+	}{
+		{
+			name:   "yaml_types",
+			raw:    "Version: 2012-10-17\nStatement:\n  - Effect: Allow\n    Action: 5\n    Resource: '*'\n",
+			format: YAMLFormat,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement[0].Action", Offset: 61, Expected: "string or array of strings", Actual: "number"},
+			},
+		},
+		{
+			name:   "yaml_strict_keys",
+			raw:    "Version: 2012-10-17\nStatement:\n  - effect: Allow\n    Action: s3:*\n    Resource: '*'\n",
+			format: YAMLFormat,
+			strict: true,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement[0].Effect", Offset: -1, Expected: "string", Actual: "nothing"},
+				{Statement: 0, Path: "Statement[0].effect", Offset: 43, Expected: "Effect", Actual: `key "effect"`},
+			},
+		},
+		{
+			name:   "yaml_syntax",
+			raw:    "Version: [2012-10-17\n",
+			format: YAMLFormat,
+			want: []ParseError{
+				{Statement: -1, Offset: -1, Expected: "valid YAML", Actual: "yaml: line 1: did not find expected ',' or ']'"},
+			},
+		},
+		{
+			name:   "yaml_alias_bomb",
+			raw:    yamlAliasBomb(),
+			format: YAMLFormat,
+			want: []ParseError{
+				{Statement: -1, Offset: -1, Expected: "valid YAML", Actual: "YAML aliases expand to more than 10000 nodes"},
+			},
+		},
+		{
+			name:   "auto_alias_bomb",
+			raw:    yamlAliasBomb(),
+			format: AutoFormat,
+			want: []ParseError{
+				{Statement: -1, Offset: -1, Expected: "valid YAML", Actual: "YAML aliases expand to more than 10000 nodes"},
+			},
+		},
+		{
+			name:   "yaml_alias_cycle",
+			raw:    "Version: 2012-10-17\nStatement: &s\n  - *s\n",
+			format: YAMLFormat,
+			want: []ParseError{
+				{Statement: -1, Offset: -1, Expected: "valid YAML", Actual: "YAML alias refers to a node that contains it"},
+			},
+		},
+		{
+			name:   "hcl_types",
+			raw:    `{ Version = "2012-10-17", Statement = { Effect = "Allow", Action = ["s3:*", true], Resource = "*" } }`,
+			format: HCLFormat,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement.Action[1]", Offset: 76, Expected: "string", Actual: "boolean"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWithOptions(tt.raw, ParseOptions{Strict: tt.strict, Format: tt.format})
+
+			var got []ParseError
+
+			var parseErrors ParseErrors
+			if !errors.As(err, &parseErrors) {
+				t.Fatalf("ParseWithOptions() error = %v, want ParseErrors", err)
+			}
+
+			for _, parseError := range parseErrors {
+				got = append(got, *parseError)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWithOptions() errors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"json", ` {"Version": "2012-10-17"}`, JSONFormat},
+		{"hcl", `{ Version = "2012-10-17" }`, HCLFormat},
+		{"jsonencode", `jsonencode({})`, HCLFormat},
+		{"yaml", "Version: 2012-10-17\n", YAMLFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.raw); got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := ParseWithOptions("{}", ParseOptions{Format: "toml"}); err == nil {
+		t.Error("ParseWithOptions() error = nil, want an error for an unknown format")
+	}
+}
@@ -0,0 +1,205 @@
+package Identity
+
+import (
+	"sort"
+	"strings"
+)
+
+// ActionChange describes how the allowed resources of one action differ between two identities.
+type ActionChange struct {
+	Service     string   `json:"Service"`
+	Action      string   `json:"Action"`
+	AccessLevel string   `json:"AccessLevel"`
+	Added       []string `json:"Added,omitempty"`
+	Removed     []string `json:"Removed,omitempty"`
+}
+
+// PermissionDiff lists the actions gained, lost and changed going from one identity to another, and
+// the actions whose conditions changed on resources they were already allowed on.
+type PermissionDiff struct {
+	Gained      []ActionChange `json:"Gained"`
+	Lost        []ActionChange `json:"Lost"`
+	Changed     []ActionChange `json:"Changed"`
+	Conditioned []ActionChange `json:"Conditioned"`
+}
+
+// Diff compares the effective permissions of two identities, or of one identity at two points in time.
+// An action is gained when it is allowed in b but not in a, lost in the opposite case, and changed
+// when it is allowed in both but on different resources. A resource that gains a condition, or whose
+// condition changes, is a conditions change rather than a new resource; dropping a condition is not.
+func Diff(a IAM, b IAM) PermissionDiff {
+	return DiffEffective(Effective(a), Effective(b))
+}
+
+// DiffEffective compares two effective permission matrices.
+func DiffEffective(before EffectivePermissions, after EffectivePermissions) PermissionDiff {
+	var result PermissionDiff
+
+	actions := map[string]string{}
+
+	for _, matrix := range []EffectivePermissions{before, after} {
+		for _, permissions := range matrix {
+			for _, permission := range permissions {
+				actions[strings.ToLower(permission.Action)] = permission.Action
+			}
+		}
+	}
+
+	for _, action := range actions {
+		old := allowedGrants(before, action)
+		current := allowedGrants(after, action)
+
+		service, _ := SplitAction(action)
+		change := ActionChange{
+			Service:     service,
+			Action:      action,
+			AccessLevel: AccessLevel(action),
+			Added:       difference(current, old),
+			Removed:     difference(old, current),
+		}
+
+		switch {
+		case len(old) == 0 && len(current) > 0:
+			result.Gained = append(result.Gained, change)
+		case len(old) > 0 && len(current) == 0:
+			result.Lost = append(result.Lost, change)
+		default:
+			conditioned := splitConditioned(&change, old)
+
+			if len(change.Added) > 0 || len(change.Removed) > 0 {
+				result.Changed = append(result.Changed, change)
+			}
+
+			if len(conditioned.Added) > 0 {
+				result.Conditioned = append(result.Conditioned, conditioned)
+			}
+		}
+	}
+
+	sortChanges(result.Gained)
+	sortChanges(result.Lost)
+	sortChanges(result.Changed)
+	sortChanges(result.Conditioned)
+
+	return result
+}
+
+// splitConditioned moves the added grants that put a condition on a resource allowed before, and the
+// grants of that resource they replace, out of a change and into a conditions change.
+func splitConditioned(change *ActionChange, old []string) ActionChange {
+	conditioned := ActionChange{Service: change.Service, Action: change.Action, AccessLevel: change.AccessLevel}
+
+	before := map[string]bool{}
+	for _, grant := range old {
+		resource, _, _ := strings.Cut(grant, " when ")
+		before[resource] = true
+	}
+
+	var added []string
+	resources := map[string]bool{}
+
+	for _, grant := range change.Added {
+		resource, _, conditional := strings.Cut(grant, " when ")
+		if conditional && before[resource] {
+			conditioned.Added = append(conditioned.Added, grant)
+			resources[resource] = true
+		} else {
+			added = append(added, grant)
+		}
+	}
+
+	var removed []string
+
+	for _, grant := range change.Removed {
+		if resource, _, _ := strings.Cut(grant, " when "); resources[resource] {
+			conditioned.Removed = append(conditioned.Removed, grant)
+		} else {
+			removed = append(removed, grant)
+		}
+	}
+
+	change.Added, change.Removed = added, removed
+
+	return conditioned
+}
+
+// Empty reports whether the two identities have the same effective permissions.
+func (d PermissionDiff) Empty() bool {
+	return len(d.Gained) == 0 && len(d.Lost) == 0 && len(d.Changed) == 0 && len(d.Conditioned) == 0
+}
+
+// Broadened reports whether any action was gained or allowed on new resources, or had a condition
+// dropped. Conditions added or changed on resources allowed before are not counted.
+func (d PermissionDiff) Broadened() bool {
+	if len(d.Gained) > 0 {
+		return true
+	}
+
+	for _, change := range d.Changed {
+		if len(change.Added) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedGrants describes each allowed resource as the resource followed by the resources it leaves
+// out and its conditions, if any.
+func allowedGrants(matrix EffectivePermissions, action string) []string {
+	permission, ok := matrix.Lookup(action)
+	if !ok {
+		return nil
+	}
+
+	var grants []string
+
+	for _, grant := range permission.Allow {
+		for _, resource := range grant.Resources {
+			if len(grant.NotResources) > 0 {
+				resource += " except " + strings.Join(grant.NotResources, ", ")
+			}
+
+			if len(grant.Condition) > 0 {
+				resource += " when " + conditionKey(grant.Condition)
+			}
+
+			grants = append(grants, resource)
+		}
+	}
+
+	return uniqueSorted(grants)
+}
+
+// difference returns the values of left that are not in right.
+func difference(left []string, right []string) []string {
+	seen := map[string]bool{}
+	for _, value := range right {
+		seen[value] = true
+	}
+
+	var result []string
+
+	for _, value := range left {
+		if !seen[value] {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// sortChanges orders changes by service, then access level, then action.
+func sortChanges(changes []ActionChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Service != changes[j].Service {
+			return changes[i].Service < changes[j].Service
+		}
+
+		if changes[i].AccessLevel != changes[j].AccessLevel {
+			return changes[i].AccessLevel < changes[j].AccessLevel
+		}
+
+		return changes[i].Action < changes[j].Action
+	})
+}
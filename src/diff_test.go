@@ -0,0 +1,122 @@
+package Identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	policy := func(statements ...Statement) []Policy {
+		return []Policy{{Version: "2012-10-17", Statements: statements}}
+	}
+
+	before := IAM{Name: "deploy", IamType: RoleType, Policies: policy(
+		Statement{Effect: "Allow", Action: []string{"sqs:SendMessage", "sqs:GetQueueUrl"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:a"}},
+		Statement{Effect: "Allow", Action: []string{"sts:GetCallerIdentity"}, Resource: []string{"*"}},
+	)}
+	after := IAM{Name: "deploy", IamType: RoleType, Policies: policy(
+		Statement{Effect: "Allow", Action: []string{"sqs:SendMessage", "sqs:GetQueueUrl"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:a"}},
+		Statement{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:b"}},
+		Statement{Effect: "Allow", Action: []string{"iam:PassRole"}, Resource: []string{"*"}},
+	)}
+
+	tests := []struct {
+		name      string
+		a         IAM
+		b         IAM
+		want      PermissionDiff
+		broadened bool
+	}{
+		{
+			name: "creep",
+			a:    before,
+			b:    after,
+			want: PermissionDiff{
+				Gained: []ActionChange{{Service: "iam", Action: "iam:PassRole", AccessLevel: WriteLevel, Added: []string{"*"}}},
+				Lost:   []ActionChange{{Service: "sts", Action: "sts:GetCallerIdentity", AccessLevel: ReadLevel, Removed: []string{"*"}}},
+				Changed: []ActionChange{{
+					Service:     "sqs",
+					Action:      "sqs:SendMessage",
+					AccessLevel: WriteLevel,
+					Added:       []string{"arn:aws:sqs:eu-west-2:123456789012:b"},
+				}},
+			},
+			broadened: true,
+		},
+		{
+			name:      "same",
+			a:         before,
+			b:         before,
+			want:      PermissionDiff{},
+			broadened: false,
+		},
+		{
+			name: "condition_added",
+			a:    IAM{Policies: policy(Statement{Effect: "Allow", Action: []string{"sqs:GetQueueUrl"}, Resource: []string{"*"}})},
+			b: IAM{Policies: policy(Statement{
+				Effect: "Allow", Action: []string{"sqs:GetQueueUrl"}, Resource: []string{"*"},
+				Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+			})},
+			want: PermissionDiff{Conditioned: []ActionChange{{
+				Service:     "sqs",
+				Action:      "sqs:GetQueueUrl",
+				AccessLevel: ReadLevel,
+				Added:       []string{`* when {"Bool":{"aws:SecureTransport":["true"]}}`},
+				Removed:     []string{"*"},
+			}}},
+			broadened: false,
+		},
+		{
+			name: "condition_dropped",
+			a: IAM{Policies: policy(Statement{
+				Effect: "Allow", Action: []string{"sqs:GetQueueUrl"}, Resource: []string{"*"},
+				Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+			})},
+			b: IAM{Policies: policy(Statement{Effect: "Allow", Action: []string{"sqs:GetQueueUrl"}, Resource: []string{"*"}})},
+			want: PermissionDiff{Changed: []ActionChange{{
+				Service:     "sqs",
+				Action:      "sqs:GetQueueUrl",
+				AccessLevel: ReadLevel,
+				Added:       []string{"*"},
+				Removed:     []string{`* when {"Bool":{"aws:SecureTransport":["true"]}}`},
+			}}},
+			broadened: true,
+		},
+		{
+			name: "condition_added_and_resource_gained",
+			a:    IAM{Policies: policy(Statement{Effect: "Allow", Action: []string{"sqs:GetQueueUrl"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:a"}})},
+			b: IAM{Policies: policy(Statement{
+				Effect: "Allow", Action: []string{"sqs:GetQueueUrl"},
+				Resource:  []string{"arn:aws:sqs:eu-west-2:123456789012:a", "arn:aws:sqs:eu-west-2:123456789012:b"},
+				Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+			})},
+			want: PermissionDiff{
+				Changed: []ActionChange{{
+					Service:     "sqs",
+					Action:      "sqs:GetQueueUrl",
+					AccessLevel: ReadLevel,
+					Added:       []string{`arn:aws:sqs:eu-west-2:123456789012:b when {"Bool":{"aws:SecureTransport":["true"]}}`},
+				}},
+				Conditioned: []ActionChange{{
+					Service:     "sqs",
+					Action:      "sqs:GetQueueUrl",
+					AccessLevel: ReadLevel,
+					Added:       []string{`arn:aws:sqs:eu-west-2:123456789012:a when {"Bool":{"aws:SecureTransport":["true"]}}`},
+					Removed:     []string{"arn:aws:sqs:eu-west-2:123456789012:a"},
+				}},
+			},
+			broadened: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+			if got.Broadened() != tt.broadened {
+				t.Errorf("Broadened() = %v, want %v", got.Broadened(), tt.broadened)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package Identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// doctorProbe names the users, groups, roles and policies the doctor asks about. None are expected to
+// exist: a permission is granted when the answer is anything but access denied.
+const doctorProbe = "identity-doctor-probe"
+
+// DoctorCheck is one line of the doctor's checklist, with what to do about it when it failed.
+type DoctorCheck struct {
+	Name        string `json:"Name"`
+	Passed      bool   `json:"Passed"`
+	Detail      string `json:"Detail,omitempty"`
+	Remediation string `json:"Remediation,omitempty"`
+}
+
+// doctorProbes makes a call that exercises each action the reader role needs.
+var doctorProbes = map[string]func(ctx context.Context, svc IAMClient, account string) error{
+	"iam:ListUserPolicies": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListUserPolicies(ctx, &iam.ListUserPoliciesInput{UserName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:ListAttachedUserPolicies": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{UserName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GetUserPolicy": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.GetUserPolicy(ctx, &iam.GetUserPolicyInput{UserName: aws.String(doctorProbe), PolicyName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GetPolicy": func(ctx context.Context, svc IAMClient, account string) error {
+		_, err := svc.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(doctorPolicyArn(account))})
+		return err
+	},
+	"iam:GetPolicyVersion": func(ctx context.Context, svc IAMClient, account string) error {
+		_, err := svc.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{PolicyArn: aws.String(doctorPolicyArn(account)), VersionId: aws.String("v1")})
+		return err
+	},
+	"iam:ListPolicyVersions": func(ctx context.Context, svc IAMClient, account string) error {
+		_, err := svc.ListPolicyVersions(ctx, &iam.ListPolicyVersionsInput{PolicyArn: aws.String(doctorPolicyArn(account))})
+		return err
+	},
+	"iam:ListGroupsForUser": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListGroupsForUser(ctx, &iam.ListGroupsForUserInput{UserName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:ListGroupPolicies": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListGroupPolicies(ctx, &iam.ListGroupPoliciesInput{GroupName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:ListAttachedGroupPolicies": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListAttachedGroupPolicies(ctx, &iam.ListAttachedGroupPoliciesInput{GroupName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GetGroupPolicy": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.GetGroupPolicy(ctx, &iam.GetGroupPolicyInput{GroupName: aws.String(doctorProbe), PolicyName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:ListRolePolicies": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListRolePolicies(ctx, &iam.ListRolePoliciesInput{RoleName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:ListAttachedRolePolicies": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.ListAttachedRolePolicies(ctx, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GetRolePolicy": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.GetRolePolicy(ctx, &iam.GetRolePolicyInput{RoleName: aws.String(doctorProbe), PolicyName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GetUser": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.GetUser(ctx, &iam.GetUserInput{UserName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GetRole": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(doctorProbe)})
+		return err
+	},
+	"iam:GenerateServiceLastAccessedDetails": func(ctx context.Context, svc IAMClient, account string) error {
+		_, err := svc.GenerateServiceLastAccessedDetails(ctx, &iam.GenerateServiceLastAccessedDetailsInput{Arn: aws.String(FormatRole(IAM{Account: account}))})
+		return err
+	},
+	"iam:GetServiceLastAccessedDetails": func(ctx context.Context, svc IAMClient, _ string) error {
+		_, err := svc.GetServiceLastAccessedDetails(ctx, &iam.GetServiceLastAccessedDetailsInput{JobId: aws.String(doctorProbe)})
+		return err
+	},
+}
+
+// Doctor checks that the tool can run: the profile resolves to credentials, the reader role exists and
+// can be assumed, and the role is granted each action in ReaderActions. Checks after a failure that they
+// depend on are not run.
+func Doctor(ctx context.Context) []DoctorCheck {
+	var checks []DoctorCheck
+
+	profile := GetAWSProfile()
+	roleName := GetIAMRoleName()
+
+	cfg, err := loadConfig(ctx)
+	checks = append(checks, doctorCheck(fmt.Sprintf("AWS profile %s resolves", profile), err,
+		fmt.Sprintf("create the profile with `aws configure --profile %s`, or set AWS_PROFILE to a configured profile", profile)))
+
+	if err != nil {
+		return checks
+	}
+
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	check := doctorCheck("Credentials are available", err,
+		fmt.Sprintf("add credentials to profile %s with `aws configure --profile %s`, or sign in with `aws sso login --profile %s`", profile, profile, profile))
+	if err == nil {
+		check.Detail = credentials.Source
+	}
+
+	checks = append(checks, check)
+
+	if err != nil {
+		return checks
+	}
+
+	caller, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	check = doctorCheck("Credentials are valid", err,
+		fmt.Sprintf("refresh the credentials of profile %s; they may have expired or been deactivated", profile))
+	if err == nil {
+		check.Detail = aws.ToString(caller.Arn)
+	}
+
+	checks = append(checks, check)
+
+	if err != nil {
+		return checks
+	}
+
+	account := IAM{Account: aws.ToString(caller.Account)}
+
+	roleCfg, err := getConfigWithAssumedRole(ctx, account)
+	if err == nil {
+		_, err = roleCfg.Credentials.Retrieve(ctx)
+	}
+
+	check = doctorCheck(fmt.Sprintf("Role %s can be assumed", roleName), err,
+		fmt.Sprintf("allow %s to call sts:AssumeRole on %s, both in the role's trust policy and in the caller's own policies",
+			aws.ToString(caller.Arn), FormatRole(account)))
+	if err == nil {
+		check.Detail = FormatRole(account)
+	} else {
+		var notFound *types.NoSuchEntityException
+
+		_, getErr := iam.NewFromConfig(cfg).GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if errors.As(getErr, &notFound) {
+			check.Name = fmt.Sprintf("Role %s exists", roleName)
+			check.Remediation = fmt.Sprintf("create the role from `identity reader-role --format terraform` or with `tofu apply` in src/role, or set IAM_ROLE_NAME to an existing reader role in account %s", account.Account)
+		}
+	}
+
+	checks = append(checks, check)
+
+	if err != nil {
+		return checks
+	}
+
+	svc, err := NewIAMClient(ctx, account)
+	if err != nil {
+		return append(checks, doctorCheck("IAM client", err, "check the AWS configuration of the profile"))
+	}
+
+	actions, _ := ReaderActions()
+	for _, action := range actions {
+		checks = append(checks, doctorPermission(action, roleName, doctorProbes[action](ctx, svc, account.Account)))
+	}
+
+	return checks
+}
+
+// DoctorFailures counts the checks that failed.
+func DoctorFailures(checks []DoctorCheck) int {
+	var failed int
+
+	for _, check := range checks {
+		if !check.Passed {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+func doctorCheck(name string, err error, remediation string) DoctorCheck {
+	if err == nil {
+		return DoctorCheck{Name: name, Passed: true}
+	}
+
+	return DoctorCheck{Name: name, Detail: err.Error(), Remediation: remediation}
+}
+
+// doctorPermission passes a probe unless the role was refused it. Errors that are not answers from AWS,
+// such as a network failure, leave the permission unchecked and so fail it too.
+func doctorPermission(action string, roleName string, err error) DoctorCheck {
+	check := DoctorCheck{Name: fmt.Sprintf("Permission %s", action), Passed: true}
+
+	var api smithy.APIError
+	if err == nil || (errors.As(err, &api) && !errors.Is(apiError(err, IAM{}, action, ""), ErrAccessDenied)) {
+		return check
+	}
+
+	check.Passed = false
+	check.Detail = err.Error()
+	check.Remediation = fmt.Sprintf("attach the %s policy from `identity reader-role` to role %s, or grant it %s", ReaderPolicyName, roleName, action)
+
+	return check
+}
+
+func doctorPolicyArn(account string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:policy/%s", account, doctorProbe)
+}
@@ -0,0 +1,101 @@
+package Identity
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	readerActions, _ := ReaderActions()
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		checks int
+		failed []string
+		fix    string
+	}{
+		{
+			name:   "all_pass",
+			checks: 4 + len(readerActions),
+		},
+		{
+			name:   "missing_profile",
+			env:    map[string]string{"AWS_PROFILE": "nope"},
+			checks: 1,
+			failed: []string{"AWS profile nope resolves"},
+			fix:    "aws configure --profile nope",
+		},
+		{
+			name:   "missing_role",
+			env:    map[string]string{"IAM_ROLE_NAME": "missing"},
+			checks: 4,
+			failed: []string{"Role missing exists"},
+			fix:    "tofu apply",
+		},
+		{
+			name:   "role_without_permissions",
+			env:    map[string]string{"IAM_ROLE_NAME": "assume_role"},
+			checks: 4 + len(readerActions),
+			failed: []string{"Permission iam:ListUserPolicies", "Permission iam:GetRole", "Permission iam:GetServiceLastAccessedDetails"},
+			fix:    "attach the identity-minimum policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeAWS(t, "account.json")
+
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			checks := Doctor(context.Background())
+			if len(checks) != tt.checks {
+				t.Fatalf("Doctor() returned %d checks, want %d: %+v", len(checks), tt.checks, checks)
+			}
+
+			failed := map[string]DoctorCheck{}
+			for _, check := range checks {
+				if !check.Passed {
+					failed[check.Name] = check
+				}
+			}
+
+			if len(tt.failed) == 0 && len(failed) > 0 {
+				t.Errorf("Doctor() failed %+v, want all checks to pass", failed)
+			}
+
+			for _, name := range tt.failed {
+				check, ok := failed[name]
+				if !ok {
+					t.Errorf("Doctor() did not fail %q: %+v", name, checks)
+
+					continue
+				}
+
+				if !strings.Contains(check.Remediation, tt.fix) {
+					t.Errorf("%s remediation = %q, want it to mention %q", name, check.Remediation, tt.fix)
+				}
+			}
+
+			if got := DoctorFailures(checks); got != len(failed) {
+				t.Errorf("DoctorFailures() = %d, want %d", got, len(failed))
+			}
+		})
+	}
+}
+
+func TestDoctorProbes(t *testing.T) {
+	actions, err := ReaderActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range actions {
+		if doctorProbes[action] == nil {
+			t.Errorf("no doctor probe for %s", action)
+		}
+	}
+}
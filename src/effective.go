@@ -0,0 +1,455 @@
+package Identity
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+const (
+	Allow = "Allow"
+	Deny  = "Deny"
+)
+
+// Grant is one effect on a set of resource patterns under a single set of conditions. A grant
+// from a NotResource statement has the resource * and lists the resources it leaves out.
+type Grant struct {
+	Resources    []string  `json:"Resources"`
+	NotResources []string  `json:"NotResources,omitempty"`
+	Condition    Condition `json:"Condition,omitempty"`
+	Sources      []string  `json:"Sources"`
+}
+
+// Permission is the net result of every statement that names one concrete action.
+type Permission struct {
+	Action      string  `json:"Action"`
+	AccessLevel string  `json:"AccessLevel"`
+	Effect      string  `json:"Effect"`
+	Allow       []Grant `json:"Allow,omitempty"`
+	Deny        []Grant `json:"Deny,omitempty"`
+}
+
+// EffectivePermissions maps a service prefix to its permissions, sorted by action.
+type EffectivePermissions map[string][]Permission
+
+// String renders a source as its attachment path followed by the policy name.
+func (s Source) String() string {
+	return strings.Join(append(append([]string{}, s.Via...), s.Name), " > ")
+}
+
+// Effective flattens the policies of an identity into one allow/deny matrix per service.
+// Statements naming the same action, effect and conditions are merged, unconditional
+// explicit denies remove the resources they cover, and a permissions boundary limits what
+// is allowed to the actions and resources it also allows. A NotAction statement stands for
+// every catalogue action it does not exclude. As the catalogue is not complete, a wildcard is also
+// kept as a permission of its own, and a deny applies to every permission whose action it matches,
+// catalogued or not.
+func Effective(identity IAM) EffectivePermissions {
+	actions := collectGrants(identity.Policies)
+
+	if identity.Boundary != nil {
+		applyBoundary(actions, collectGrants([]Policy{*identity.Boundary}))
+	}
+
+	result := EffectivePermissions{}
+
+	for _, permission := range actions {
+		permission.Allow = subtractDenies(permission.Allow, permission.Deny)
+
+		permission.Effect = Deny
+		if len(permission.Allow) > 0 {
+			permission.Effect = Allow
+		}
+
+		sortGrants(permission.Allow)
+		sortGrants(permission.Deny)
+
+		service, _ := SplitAction(permission.Action)
+		result[service] = append(result[service], *permission)
+	}
+
+	for service := range result {
+		sort.Slice(result[service], func(i, j int) bool {
+			return result[service][i].Action < result[service][j].Action
+		})
+	}
+
+	return result
+}
+
+// Services returns the service prefixes in the matrix in alphabetical order.
+func (e EffectivePermissions) Services() []string {
+	services := make([]string, 0, len(e))
+
+	for service := range e {
+		services = append(services, service)
+	}
+
+	sort.Strings(services)
+
+	return services
+}
+
+// Lookup returns the permission for a concrete action, if any statement names it.
+func (e EffectivePermissions) Lookup(action string) (Permission, bool) {
+	service, _ := SplitAction(action)
+
+	for _, permission := range e[service] {
+		if strings.EqualFold(permission.Action, action) {
+			return permission, true
+		}
+	}
+
+	return Permission{}, false
+}
+
+// Allowed reports whether an action is allowed on at least one resource.
+func (e EffectivePermissions) Allowed(action string) bool {
+	permission, ok := e.Lookup(action)
+
+	return ok && permission.Effect == Allow
+}
+
+func collectGrants(policies []Policy) map[string]*Permission {
+	actions := map[string]*Permission{}
+
+	for _, policy := range policies {
+		for _, statement := range policy.Statements {
+			grant := statementGrant(statement, policy.Source)
+
+			for _, action := range statementActions(statement) {
+				key := strings.ToLower(action)
+
+				permission, ok := actions[key]
+				if !ok {
+					permission = &Permission{Action: action, AccessLevel: AccessLevel(action)}
+					actions[key] = permission
+				}
+
+				if statement.Effect == Deny {
+					permission.Deny = mergeGrant(permission.Deny, grant)
+				} else {
+					permission.Allow = mergeGrant(permission.Allow, grant)
+				}
+			}
+		}
+	}
+
+	// a deny also covers the actions it matches that the catalogue does not list
+	for _, policy := range policies {
+		for _, statement := range policy.Statements {
+			if statement.Effect != Deny {
+				continue
+			}
+
+			grant := statementGrant(statement, policy.Source)
+
+			for _, permission := range actions {
+				if statementMatches(statement, permission.Action) {
+					permission.Deny = mergeGrant(permission.Deny, grant)
+				}
+			}
+		}
+	}
+
+	return actions
+}
+
+// statementGrant returns the grant a statement makes on every action it applies to.
+func statementGrant(statement Statement, source Source) Grant {
+	grant := Grant{
+		Resources: statement.Resource,
+		Condition: statement.Condition,
+		Sources:   []string{source.String()},
+	}
+
+	if len(statement.NotResource) > 0 {
+		grant.Resources = []string{"*"}
+		grant.NotResources = statement.NotResource
+	}
+
+	return grant
+}
+
+// statementMatches reports whether a statement applies to an action, which may itself be a pattern.
+func statementMatches(statement Statement, action string) bool {
+	if len(statement.NotAction) > 0 {
+		return !coveredByPattern(action, statement.NotAction)
+	}
+
+	return coveredByPattern(action, statement.Action)
+}
+
+// statementActions expands the actions a statement applies to: its Action patterns, or for a
+// NotAction statement every catalogue action that none of the NotAction patterns match. A wildcard
+// for a service whose catalogue is not complete is kept alongside its expansion, as it also stands
+// for the actions the catalogue does not list.
+func statementActions(statement Statement) []string {
+	var actions []string
+
+	if len(statement.NotAction) == 0 {
+		for _, pattern := range statement.Action {
+			actions = append(actions, ExpandAction(pattern)...)
+
+			if service, _ := SplitAction(pattern); service != "" && strings.ContainsAny(pattern, "*?") && KnownService(service) && !completeService(service) {
+				actions = append(actions, pattern)
+			}
+		}
+
+		return actions
+	}
+
+	for _, action := range ExpandAction("*") {
+		if action != "*" && !coveredByPattern(action, statement.NotAction) {
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+// mergeGrant folds a grant into the existing grant with identical conditions and excluded
+// resources, if there is one.
+func mergeGrant(grants []Grant, grant Grant) []Grant {
+	grant.NotResources = uniqueSorted(grant.NotResources)
+	key := grantKey(grant)
+
+	for i := range grants {
+		if grantKey(grants[i]) == key {
+			grants[i].Resources = compactResources(append(grants[i].Resources, grant.Resources...))
+			grants[i].Sources = uniqueSorted(append(grants[i].Sources, grant.Sources...))
+
+			return grants
+		}
+	}
+
+	grant.Resources = compactResources(append([]string{}, grant.Resources...))
+	grant.Sources = uniqueSorted(grant.Sources)
+
+	return append(grants, grant)
+}
+
+// applyBoundary intersects each allowed action with what the boundary allows and adds the boundary's denies.
+// Resources a boundary NotResource statement leaves out are left out of every allowed grant.
+func applyBoundary(actions map[string]*Permission, boundary map[string]*Permission) {
+	for key, permission := range actions {
+		limit, ok := boundaryLimit(boundary, key, permission.Action)
+
+		var allowed, excluded []string
+		if ok {
+			allowed = grantResources(limit.Allow)
+			excluded = grantNotResources(limit.Allow)
+		}
+
+		var kept []Grant
+
+		for _, grant := range permission.Allow {
+			grant.Resources = intersectResources(grant.Resources, allowed)
+			if len(excluded) > 0 {
+				grant.NotResources = uniqueSorted(append(append([]string{}, grant.NotResources...), excluded...))
+			}
+
+			if len(grant.Resources) > 0 {
+				kept = append(kept, grant)
+			}
+		}
+
+		permission.Allow = kept
+
+		if ok {
+			for _, grant := range limit.Deny {
+				permission.Deny = mergeGrant(permission.Deny, grant)
+			}
+		}
+	}
+}
+
+// boundaryLimit merges what a boundary says of an action: its own permission and those of the
+// boundary's wildcards that match it, which also cover actions the catalogue does not list.
+func boundaryLimit(boundary map[string]*Permission, key string, action string) (*Permission, bool) {
+	limit := &Permission{Action: action}
+	found := false
+
+	for other, permission := range boundary {
+		if other != key && !(strings.ContainsAny(other, "*?") && MatchAction(other, action)) {
+			continue
+		}
+
+		found = true
+
+		for _, grant := range permission.Allow {
+			limit.Allow = mergeGrant(limit.Allow, grant)
+		}
+
+		for _, grant := range permission.Deny {
+			limit.Deny = mergeGrant(limit.Deny, grant)
+		}
+	}
+
+	return limit, found
+}
+
+// subtractDenies removes allowed resources that an unconditional deny covers. A NotResource deny
+// covers everything but the resources it leaves out, so only those stay allowed.
+func subtractDenies(allows []Grant, denies []Grant) []Grant {
+	var denied []string
+	var spared [][]string
+
+	for _, deny := range denies {
+		if len(deny.Condition) > 0 {
+			continue
+		}
+
+		if len(deny.NotResources) > 0 {
+			spared = append(spared, deny.NotResources)
+		} else {
+			denied = append(denied, deny.Resources...)
+		}
+	}
+
+	var kept []Grant
+
+	for _, grant := range allows {
+		var resources []string
+
+		remaining := grant.Resources
+		for _, only := range spared {
+			remaining = intersectResources(remaining, only)
+		}
+
+		for _, resource := range remaining {
+			if !coveredBy(resource, denied) {
+				resources = append(resources, resource)
+			}
+		}
+
+		if len(resources) > 0 {
+			grant.Resources = resources
+			kept = append(kept, grant)
+		}
+	}
+
+	return kept
+}
+
+// grantResources returns the resources of the unconditional grants.
+func grantResources(grants []Grant) []string {
+	var resources []string
+
+	for _, grant := range grants {
+		if len(grant.Condition) == 0 {
+			resources = append(resources, grant.Resources...)
+		}
+	}
+
+	return resources
+}
+
+// grantNotResources returns the resources the unconditional grants leave out.
+func grantNotResources(grants []Grant) []string {
+	var resources []string
+
+	for _, grant := range grants {
+		if len(grant.Condition) == 0 {
+			resources = append(resources, grant.NotResources...)
+		}
+	}
+
+	return resources
+}
+
+// grantCovers reports whether a grant applies to a resource, minding the resources it leaves out.
+func grantCovers(grant Grant, resource string) bool {
+	return coveredBy(resource, grant.Resources) && !coveredBy(resource, grant.NotResources)
+}
+
+// intersectResources keeps, for every pair of overlapping patterns, the narrower of the two.
+func intersectResources(left []string, right []string) []string {
+	var result []string
+
+	for _, l := range left {
+		for _, r := range right {
+			switch {
+			case WildcardMatch(r, l):
+				result = append(result, l)
+			case WildcardMatch(l, r):
+				result = append(result, r)
+			}
+		}
+	}
+
+	return compactResources(result)
+}
+
+func coveredBy(resource string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if WildcardMatch(pattern, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compactResources sorts and de-duplicates resources, dropping any already matched by a broader pattern.
+func compactResources(resources []string) []string {
+	unique := uniqueSorted(resources)
+
+	var result []string
+
+	for i, resource := range unique {
+		subsumed := false
+
+		for j, other := range unique {
+			if i != j && other != resource && WildcardMatch(other, resource) {
+				subsumed = true
+				break
+			}
+		}
+
+		if !subsumed {
+			result = append(result, resource)
+		}
+	}
+
+	return result
+}
+
+func uniqueSorted(values []string) []string {
+	seen := map[string]bool{}
+
+	var result []string
+
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+func sortGrants(grants []Grant) {
+	sort.Slice(grants, func(i, j int) bool {
+		return grantKey(grants[i]) < grantKey(grants[j])
+	})
+}
+
+// grantKey identifies the grants that can be merged: those with the same conditions and excluded resources.
+func grantKey(grant Grant) string {
+	return conditionKey(grant.Condition) + "\x00" + strings.Join(grant.NotResources, "\x00")
+}
+
+// conditionKey is a stable encoding of a condition; encoding/json sorts map keys.
+func conditionKey(condition Condition) string {
+	if len(condition) == 0 {
+		return ""
+	}
+
+	raw, _ := json.Marshal(condition)
+
+	return string(raw)
+}
@@ -0,0 +1,204 @@
+package Identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEffective(t *testing.T) {
+	user := Policy{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:a"}},
+			{Effect: "Allow", Action: []string{"sqs:sendmessage"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:b"}},
+		},
+		Source: Source{Name: "inline", Kind: InlineKind, Via: []string{"user/basic"}},
+	}
+	group := Policy{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"*"}, Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}}},
+			{Effect: "Deny", Action: []string{"sqs:DeleteQueue"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"sqs:DeleteQueue"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:a"}},
+		},
+		Source: Source{Name: "managed", Kind: ManagedKind, Via: []string{"user/basic", "group/devs"}},
+	}
+
+	tests := []struct {
+		name     string
+		identity IAM
+		want     EffectivePermissions
+	}{
+		{
+			name:     "merged_and_denied",
+			identity: IAM{Name: "basic", IamType: UserType, Policies: []Policy{user, group}},
+			want: EffectivePermissions{"sqs": {
+				{
+					Action:      "sqs:DeleteQueue",
+					AccessLevel: WriteLevel,
+					Effect:      Deny,
+					Deny:        []Grant{{Resources: []string{"*"}, Sources: []string{"user/basic > group/devs > managed"}}},
+				},
+				{
+					Action:      "sqs:SendMessage",
+					AccessLevel: WriteLevel,
+					Effect:      Allow,
+					Allow: []Grant{
+						{
+							Resources: []string{"arn:aws:sqs:eu-west-2:123456789012:a", "arn:aws:sqs:eu-west-2:123456789012:b"},
+							Sources:   []string{"user/basic > inline"},
+						},
+						{
+							Resources: []string{"*"},
+							Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+							Sources:   []string{"user/basic > group/devs > managed"},
+						},
+					},
+				},
+			}},
+		},
+		{
+			name: "boundary",
+			identity: IAM{Name: "basic", IamType: UserType, Policies: []Policy{{
+				Version:    "2012-10-17",
+				Statements: []Statement{{Effect: "Allow", Action: []string{"sts:GetCallerIdentity", "sqs:GetQueueUrl"}, Resource: []string{"*"}}},
+				Source:     Source{Name: "wide", Via: []string{"user/basic"}},
+			}}, Boundary: &Policy{
+				Version:    "2012-10-17",
+				Statements: []Statement{{Effect: "Allow", Action: []string{"sqs:*"}, Resource: []string{"arn:aws:sqs:*:123456789012:*"}}},
+				Source:     Source{Name: "limit", Kind: BoundaryKind, Via: []string{"user/basic"}},
+			}},
+			want: EffectivePermissions{
+				"sqs": {{
+					Action:      "sqs:GetQueueUrl",
+					AccessLevel: ReadLevel,
+					Effect:      Allow,
+					Allow:       []Grant{{Resources: []string{"arn:aws:sqs:*:123456789012:*"}, Sources: []string{"user/basic > wide"}}},
+				}},
+				"sts": {{Action: "sts:GetCallerIdentity", AccessLevel: ReadLevel, Effect: Deny}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Effective(tt.identity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Effective() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffective_NotActionAndNotResource(t *testing.T) {
+	identity := func(statements ...Statement) IAM {
+		return IAM{Name: "basic", IamType: UserType, Policies: []Policy{{
+			Version:    "2012-10-17",
+			Statements: statements,
+			Source:     Source{Name: "inline", Kind: InlineKind, Via: []string{"user/basic"}},
+		}}}
+	}
+
+	powerUser := identity(
+		Statement{Effect: Allow, NotAction: []string{"iam:*", "organizations:*", "account:*"}, Resource: []string{"*"}},
+		Statement{Effect: Allow, Action: []string{"iam:CreateServiceLinkedRole", "iam:ListRoles"}, Resource: []string{"*"}},
+	)
+	denyAllButIam := identity(
+		Statement{Effect: Allow, Action: []string{"s3:*", "iam:GetUser"}, Resource: []string{"*"}},
+		Statement{Effect: Deny, NotAction: []string{"iam:*"}, Resource: []string{"*"}},
+	)
+	notSecret := identity(
+		Statement{Effect: Allow, Action: []string{"s3:GetObject"}, NotResource: []string{"arn:aws:s3:::secret/*"}},
+	)
+	onlyPublic := identity(
+		Statement{Effect: Allow, Action: []string{"s3:GetObject"}, Resource: []string{"*"}},
+		Statement{Effect: Deny, Action: []string{"s3:*"}, NotResource: []string{"arn:aws:s3:::public/*"}},
+	)
+	uncatalogued := identity(
+		Statement{Effect: Allow, Action: []string{"ec2:ModifyInstanceMetadataOptions"}, Resource: []string{"*"}},
+	)
+	// neither s3:PutBucketInventoryConfiguration nor ec2:CreateFleet is in the catalogue
+	wildcardDeny := identity(
+		Statement{Effect: Allow, Action: []string{"s3:PutBucketInventoryConfiguration", "ec2:*"}, Resource: []string{"*"}},
+		Statement{Effect: Deny, Action: []string{"s3:*", "ec2:Create*"}, Resource: []string{"*"}},
+	)
+	wildcardAllow := identity(
+		Statement{Effect: Allow, Action: []string{"ec2:*"}, Resource: []string{"*"}},
+	)
+	wildcardBoundary := identity(
+		Statement{Effect: Allow, Action: []string{"s3:PutBucketInventoryConfiguration"}, Resource: []string{"*"}},
+	)
+	wildcardBoundary.Boundary = &Policy{Version: "2012-10-17", Statements: []Statement{
+		{Effect: Allow, Action: []string{"s3:*"}, Resource: []string{"*"}},
+	}}
+
+	tests := []struct {
+		name     string
+		identity IAM
+		action   string
+		resource string
+		want     bool
+	}{
+		{"not_action_allows_the_rest", powerUser, "s3:GetObject", "arn:aws:s3:::logs/key", true},
+		{"not_action_allows_other_services", powerUser, "ec2:RunInstances", "*", true},
+		{"not_action_excludes", powerUser, "iam:CreateUser", "*", false},
+		{"not_action_with_action", powerUser, "iam:CreateServiceLinkedRole", "*", true},
+		{"not_action_deny", denyAllButIam, "s3:GetObject", "arn:aws:s3:::logs/key", false},
+		{"not_action_deny_spares", denyAllButIam, "iam:GetUser", "*", true},
+		{"not_resource_allows", notSecret, "s3:GetObject", "arn:aws:s3:::public/key", true},
+		{"not_resource_excludes", notSecret, "s3:GetObject", "arn:aws:s3:::secret/key", false},
+		{"not_resource_deny_spares", onlyPublic, "s3:GetObject", "arn:aws:s3:::public/key", true},
+		{"not_resource_deny", onlyPublic, "s3:GetObject", "arn:aws:s3:::private/key", false},
+		{"uncatalogued_action", uncatalogued, "ec2:ModifyInstanceMetadataOptions", "*", true},
+		{"wildcard_deny_of_uncatalogued_allow", wildcardDeny, "s3:PutBucketInventoryConfiguration", "*", false},
+		{"wildcard_deny_of_uncatalogued_wildcard", wildcardDeny, "ec2:CreateFleet", "*", false},
+		{"wildcard_deny_spares", wildcardDeny, "ec2:DescribeFleets", "*", true},
+		{"wildcard_allow_of_uncatalogued", wildcardAllow, "ec2:CreateFleet", "*", true},
+		{"wildcard_boundary_of_uncatalogued", wildcardBoundary, "s3:PutBucketInventoryConfiguration", "*", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Effective(tt.identity).AllowedOn(tt.action, tt.resource); got != tt.want {
+				t.Errorf("AllowedOn(%s, %s) = %v, want %v", tt.action, tt.resource, got, tt.want)
+			}
+		})
+	}
+
+	if Effective(wildcardDeny).Allowed("s3:PutBucketInventoryConfiguration") {
+		t.Errorf("Allowed(s3:PutBucketInventoryConfiguration) = true under Deny s3:*")
+	}
+
+	if !Effective(wildcardAllow).Allowed("ec2:*") {
+		t.Errorf("Allowed(ec2:*) = false, want the wildcard kept as a permission")
+	}
+
+	grant := Effective(notSecret)["s3"][0].Allow[0]
+	if !reflect.DeepEqual(grant.Resources, []string{"*"}) || !reflect.DeepEqual(grant.NotResources, []string{"arn:aws:s3:::secret/*"}) {
+		t.Errorf("NotResource grant = %+v, want * except arn:aws:s3:::secret/*", grant)
+	}
+
+	allowed := Effective(onlyPublic)["s3"]
+	for _, permission := range allowed {
+		if permission.Action == "s3:GetObject" && !reflect.DeepEqual(permission.Allow[0].Resources, []string{"arn:aws:s3:::public/*"}) {
+			t.Errorf("s3:GetObject allowed on %v, want only arn:aws:s3:::public/*", permission.Allow[0].Resources)
+		}
+	}
+}
+
+func TestCompactResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		want      []string
+	}{
+		{"star_wins", []string{"arn:aws:s3:::a", "*"}, []string{"*"}},
+		{"duplicates", []string{"b", "a", "b"}, []string{"a", "b"}},
+		{"prefix", []string{"arn:aws:s3:::a/*", "arn:aws:s3:::a/key"}, []string{"arn:aws:s3:::a/*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compactResources(tt.resources); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compactResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
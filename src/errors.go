@@ -0,0 +1,128 @@
+package Identity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+// Sentinel errors for the ways retrieving an identity fails. Each is matched with errors.Is by the
+// typed error that carries the detail.
+var (
+	ErrRoleNotAssumable = errors.New("reader role cannot be assumed")
+	ErrAccessDenied     = errors.New("access denied")
+	ErrNoSuchEntity     = errors.New("no such entity")
+	ErrThrottled        = errors.New("request throttled")
+)
+
+// RoleNotAssumableError is returned when the caller cannot assume the reader role, because the role
+// does not exist or does not trust the caller.
+type RoleNotAssumableError struct {
+	Role string
+	Err  error
+}
+
+func (e *RoleNotAssumableError) Error() string {
+	return fmt.Sprintf("cannot assume reader role %s: %v", e.Role, e.Err)
+}
+
+func (e *RoleNotAssumableError) Unwrap() error { return e.Err }
+
+func (e *RoleNotAssumableError) Is(target error) bool { return target == ErrRoleNotAssumable }
+
+// AccessDeniedError is returned when the reader role was refused an action.
+type AccessDeniedError struct {
+	Action string
+	Err    error
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("access denied to %s: %v", e.Action, e.Err)
+}
+
+func (e *AccessDeniedError) Unwrap() error { return e.Err }
+
+func (e *AccessDeniedError) Is(target error) bool { return target == ErrAccessDenied }
+
+// NoSuchEntityError is returned when a user, group, role or policy does not exist. Entity is a user,
+// group or role as type/name, an inline policy as type/name:policy, or a managed policy ARN.
+type NoSuchEntityError struct {
+	Entity string
+	Err    error
+}
+
+func (e *NoSuchEntityError) Error() string {
+	return fmt.Sprintf("%s does not exist: %v", e.Entity, e.Err)
+}
+
+func (e *NoSuchEntityError) Unwrap() error { return e.Err }
+
+func (e *NoSuchEntityError) Is(target error) bool { return target == ErrNoSuchEntity }
+
+// ThrottledError is returned when AWS throttled an action beyond the client's retries.
+type ThrottledError struct {
+	Action string
+	Err    error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled calling %s: %v", e.Action, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error { return e.Err }
+
+func (e *ThrottledError) Is(target error) bool { return target == ErrThrottled }
+
+// apiError converts an error from an AWS call of action about entity, made with account's reader role,
+// into the typed error it stands for. Errors it does not recognise are returned as they are.
+func apiError(err error, account IAM, action string, entity string) error {
+	if err == nil {
+		return nil
+	}
+
+	if assumeRoleFailed(err) {
+		return &RoleNotAssumableError{Role: FormatRole(account), Err: err}
+	}
+
+	var api smithy.APIError
+	if !errors.As(err, &api) {
+		return err
+	}
+
+	switch code := api.ErrorCode(); {
+	case strings.HasPrefix(code, "AccessDenied"):
+		return &AccessDeniedError{Action: action, Err: err}
+	case code == "NoSuchEntity":
+		return &NoSuchEntityError{Entity: entity, Err: err}
+	case isThrottle(code):
+		return &ThrottledError{Action: action, Err: err}
+	}
+
+	return err
+}
+
+// assumeRoleFailed reports whether err came from assuming the reader role for credentials. Throttling
+// of AssumeRole is not a failure of the role.
+func assumeRoleFailed(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		operation, ok := err.(*smithy.OperationError)
+		if !ok || operation.Service() != "STS" || operation.Operation() != "AssumeRole" {
+			continue
+		}
+
+		var api smithy.APIError
+
+		return !errors.As(operation.Err, &api) || !isThrottle(api.ErrorCode())
+	}
+
+	return false
+}
+
+func isThrottle(code string) bool {
+	_, ok := retry.DefaultThrottleErrorCodes[code]
+
+	return ok
+}
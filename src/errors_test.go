@@ -0,0 +1,104 @@
+package Identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestAPIError(t *testing.T) {
+	account := IAM{Account: "680235478471"}
+
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   string
+	}{
+		{"access_denied", &smithy.GenericAPIError{Code: "AccessDenied"}, ErrAccessDenied, "access denied to iam:GetUser: api error AccessDenied: "},
+		{"access_denied_exception", &smithy.GenericAPIError{Code: "AccessDeniedException"}, ErrAccessDenied, "access denied to iam:GetUser: api error AccessDeniedException: "},
+		{"no_such_entity", &smithy.GenericAPIError{Code: "NoSuchEntity"}, ErrNoSuchEntity, "user/basic does not exist: api error NoSuchEntity: "},
+		{"throttled", &smithy.GenericAPIError{Code: "Throttling"}, ErrThrottled, "throttled calling iam:GetUser: api error Throttling: "},
+		{
+			"role_not_assumable",
+			&smithy.OperationError{ServiceID: "IAM", OperationName: "GetUser", Err: fmt.Errorf("failed to refresh cached credentials, %w",
+				&smithy.OperationError{ServiceID: "STS", OperationName: "AssumeRole", Err: &smithy.GenericAPIError{Code: "AccessDenied"}})},
+			ErrRoleNotAssumable,
+			"",
+		},
+		{
+			"assume_role_throttled",
+			&smithy.OperationError{ServiceID: "IAM", OperationName: "GetUser", Err: fmt.Errorf("failed to refresh cached credentials, %w",
+				&smithy.OperationError{ServiceID: "STS", OperationName: "AssumeRole", Err: &smithy.GenericAPIError{Code: "Throttling"}})},
+			ErrThrottled,
+			"",
+		},
+		{"other_api_error", &smithy.GenericAPIError{Code: "ServiceFailure"}, nil, "api error ServiceFailure: "},
+		{"not_api_error", errors.New("connection refused"), nil, "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := apiError(tt.err, account, "iam:GetUser", "user/basic")
+
+			for _, sentinel := range []error{ErrRoleNotAssumable, ErrAccessDenied, ErrNoSuchEntity, ErrThrottled} {
+				if errors.Is(got, sentinel) != (sentinel == tt.target) {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", got, sentinel, !(sentinel == tt.target), sentinel == tt.target)
+				}
+			}
+
+			if !errors.Is(got, tt.err) {
+				t.Errorf("apiError() = %v, want it to wrap %v", got, tt.err)
+			}
+
+			if tt.want != "" && got.Error() != tt.want {
+				t.Errorf("apiError() = %q, want %q", got.Error(), tt.want)
+			}
+		})
+	}
+
+	if apiError(nil, account, "iam:GetUser", "user/basic") != nil {
+		t.Errorf("apiError(nil) != nil")
+	}
+}
+
+func TestAPIErrorFromAWS(t *testing.T) {
+	useFakeAWS(t, "account.json")
+
+	ctx := context.Background()
+	account := IAM{Account: "680235478471"}
+
+	_, err := GetUser(ctx, IAM{Name: "nobody", IamType: UserType, Account: account.Account})
+
+	var missing *NoSuchEntityError
+	if !errors.As(err, &missing) || missing.Entity != "user/nobody" {
+		t.Errorf("GetUser() of a missing user error = %v, want NoSuchEntityError for user/nobody", err)
+	}
+
+	_, err = GetPolicy(ctx, "arn:aws:iam::680235478471:policy/nothing", account)
+
+	if !errors.As(err, &missing) || missing.Entity != "arn:aws:iam::680235478471:policy/nothing" {
+		t.Errorf("GetPolicy() of a missing policy error = %v, want NoSuchEntityError for its ARN", err)
+	}
+
+	t.Setenv("IAM_ROLE_NAME", "assume_role")
+
+	_, err = GetIam(ctx)
+
+	var denied *AccessDeniedError
+	if !errors.As(err, &denied) || denied.Action != "iam:ListUserPolicies" {
+		t.Errorf("GetIam() through a role without IAM permissions error = %v, want AccessDeniedError for iam:ListUserPolicies", err)
+	}
+
+	t.Setenv("IAM_ROLE_NAME", "missing")
+
+	_, err = GetIam(ctx)
+
+	var notAssumable *RoleNotAssumableError
+	if !errors.As(err, &notAssumable) || notAssumable.Role != "arn:aws:iam::680235478471:role/missing" {
+		t.Errorf("GetIam() through a missing role error = %v, want RoleNotAssumableError", err)
+	}
+}
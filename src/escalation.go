@@ -0,0 +1,122 @@
+package Identity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// escalationPath is a set of actions that together let an identity raise its own privileges.
+type escalationPath struct {
+	id          string
+	name        string
+	actions     []string
+	description string
+}
+
+// escalationPaths are the known IAM privilege escalation methods that use catalogued services.
+var escalationPaths = []escalationPath{
+	{"ESC001", "create-policy-version", []string{"iam:CreatePolicyVersion"},
+		"can publish a new default version of a managed policy attached to it"},
+	{"ESC002", "set-default-policy-version", []string{"iam:SetDefaultPolicyVersion"},
+		"can switch a managed policy attached to it to an older, broader version"},
+	{"ESC003", "pass-role-ec2", []string{"iam:PassRole", "ec2:RunInstances"},
+		"can start an instance with a more privileged role and use its credentials"},
+	{"ESC004", "create-access-key", []string{"iam:CreateAccessKey"},
+		"can create access keys for other users"},
+	{"ESC005", "create-login-profile", []string{"iam:CreateLoginProfile"},
+		"can set a console password for users that have none"},
+	{"ESC006", "update-login-profile", []string{"iam:UpdateLoginProfile"},
+		"can change the console password of other users"},
+	{"ESC007", "attach-user-policy", []string{"iam:AttachUserPolicy"},
+		"can attach any managed policy, such as AdministratorAccess, to a user"},
+	{"ESC008", "attach-group-policy", []string{"iam:AttachGroupPolicy"},
+		"can attach any managed policy to a group it belongs to"},
+	{"ESC009", "attach-role-policy", []string{"iam:AttachRolePolicy", "sts:AssumeRole"},
+		"can attach any managed policy to a role it can assume"},
+	{"ESC010", "put-user-policy", []string{"iam:PutUserPolicy"},
+		"can write an inline policy granting anything to a user"},
+	{"ESC011", "put-group-policy", []string{"iam:PutGroupPolicy"},
+		"can write an inline policy granting anything to a group it belongs to"},
+	{"ESC012", "put-role-policy", []string{"iam:PutRolePolicy", "sts:AssumeRole"},
+		"can write an inline policy granting anything to a role it can assume"},
+	{"ESC013", "add-user-to-group", []string{"iam:AddUserToGroup"},
+		"can add itself to a more privileged group"},
+	{"ESC014", "update-assume-role-policy", []string{"iam:UpdateAssumeRolePolicy", "sts:AssumeRole"},
+		"can change the trust policy of a privileged role to let itself assume it"},
+	{"ESC015", "pass-role-lambda", []string{"iam:PassRole", "lambda:CreateFunction", "lambda:InvokeFunction"},
+		"can create and invoke a function that runs as a more privileged role"},
+	{"ESC016", "pass-role-lambda-event-source", []string{"iam:PassRole", "lambda:CreateFunction", "lambda:CreateEventSourceMapping"},
+		"can create a function that runs as a more privileged role and have an event source invoke it"},
+	{"ESC017", "update-function-code", []string{"lambda:UpdateFunctionCode"},
+		"can replace the code of a function that runs as a more privileged role"},
+	{"ESC018", "pass-role-cloudformation", []string{"iam:PassRole", "cloudformation:CreateStack"},
+		"can create a stack that runs as a more privileged role"},
+	{"ESC019", "pass-role-ecs", []string{"iam:PassRole", "ecs:RegisterTaskDefinition", "ecs:RunTask"},
+		"can run a task that runs as a more privileged role"},
+}
+
+func escalationRules() []Rule {
+	rules := make([]Rule, 0, len(escalationPaths))
+
+	for _, path := range escalationPaths {
+		rules = append(rules, Rule{
+			ID:    path.id,
+			Name:  path.name,
+			Level: ErrorLevel,
+			Short: "Privilege escalation through " + strings.Join(path.actions, ", "),
+			Help: fmt.Sprintf("An identity allowed %s %s. Remove the actions, or scope them to resources "+
+				"that cannot lead to more privilege.", strings.Join(path.actions, " and "), path.description),
+		})
+	}
+
+	return rules
+}
+
+// Escalations reports the privilege escalation paths an identity's effective permissions allow.
+// Each finding points at the statement that allows the first action of the path.
+func Escalations(ident IAM) []Finding {
+	findings := []Finding{}
+	matrix := Effective(ident)
+	rules := escalationRules()
+
+	for i, path := range escalationPaths {
+		allowed := true
+
+		for _, action := range path.actions {
+			if !matrix.AllowedOn(action, "*") {
+				allowed = false
+
+				break
+			}
+		}
+
+		if !allowed {
+			continue
+		}
+
+		policy, statement := grantingStatement(ident, path.actions[0])
+		findings = append(findings, newFinding(rules[i], policy, statement,
+			fmt.Sprintf("allows %s, so it %s", strings.Join(path.actions, ", "), path.description)))
+	}
+
+	SortFindings(findings)
+
+	return findings
+}
+
+// grantingStatement finds the first statement that allows an action.
+func grantingStatement(ident IAM, action string) (Policy, int) {
+	for _, policy := range ident.Policies {
+		for index, statement := range policy.Statements {
+			if statement.Effect != Allow {
+				continue
+			}
+
+			if statementNames(statement, action) {
+				return policy, index
+			}
+		}
+	}
+
+	return Policy{}, -1
+}
@@ -0,0 +1,77 @@
+package Identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscalations(t *testing.T) {
+	tests := []struct {
+		name       string
+		statements []Statement
+		want       []string
+	}{
+		{
+			name:       "read_only",
+			statements: []Statement{{Effect: "Allow", Action: []string{"iam:Get*", "iam:List*"}, Resource: []string{"*"}}},
+			want:       []string{},
+		},
+		{
+			name: "pass_role_needs_both",
+			statements: []Statement{
+				{Effect: "Allow", Action: []string{"iam:PassRole"}, Resource: []string{"*"}},
+				{Effect: "Allow", Action: []string{"ec2:RunInstances"}, Resource: []string{"*"}},
+			},
+			want: []string{"ESC003"},
+		},
+		{
+			name: "denied",
+			statements: []Statement{
+				{Effect: "Allow", Action: []string{"iam:CreateAccessKey", "iam:AddUserToGroup"}, Resource: []string{"*"}},
+				{Effect: "Deny", Action: []string{"iam:CreateAccessKey"}, Resource: []string{"*"}},
+			},
+			want: []string{"ESC013"},
+		},
+		{
+			name: "not_action_except_iam",
+			statements: []Statement{
+				{Effect: "Allow", NotAction: []string{"iam:*"}, Resource: []string{"*"}},
+				{Effect: "Deny", NotAction: []string{"iam:CreateAccessKey", "iam:AddUserToGroup", "iam:ListUsers"}, Resource: []string{"*"}},
+			},
+			want: []string{},
+		},
+		{
+			name: "not_action_denies_the_rest",
+			statements: []Statement{
+				{Effect: "Allow", Action: []string{"iam:*"}, Resource: []string{"*"}},
+				{Effect: "Deny", NotAction: []string{"iam:AddUserToGroup"}, Resource: []string{"*"}},
+			},
+			want: []string{"ESC013"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ident := IAM{Name: "dev", IamType: UserType, Policies: []Policy{{Version: "2012-10-17", Statements: tt.statements}}}
+
+			got := []string{}
+			for _, finding := range Escalations(ident) {
+				got = append(got, finding.RuleID)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Escalations() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscalationActionsInCatalogue(t *testing.T) {
+	for _, path := range escalationPaths {
+		for _, action := range path.actions {
+			if AccessLevel(action) == UnknownLevel {
+				t.Errorf("%s needs %s, which is not in the action catalogue", path.id, action)
+			}
+		}
+	}
+}
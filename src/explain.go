@@ -0,0 +1,547 @@
+package Identity
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decisions of an explained request, named as the IAM policy simulator names them.
+const (
+	AllowedDecision      = "allowed"
+	ExplicitDenyDecision = "explicitDeny"
+	ImplicitDenyDecision = "implicitDeny"
+)
+
+// Layers of policy evaluation, in the order IAM applies them.
+const (
+	SCPLayer      = "scp"
+	BoundaryLayer = "boundary"
+	SessionLayer  = "session"
+	IdentityLayer = "identity"
+)
+
+// Match is whether part of a statement matches a request. It is unknown when that depends on a
+// condition key or policy variable the request context does not give.
+type Match string
+
+const (
+	Matched      Match = "match"
+	NotMatched   Match = "no match"
+	UnknownMatch Match = "unknown"
+)
+
+// Explanation traces how IAM evaluates a request for an action on a resource.
+type Explanation struct {
+	Action   string              `json:"Action"`
+	Resource string              `json:"Resource"`
+	Context  map[string][]string `json:"Context,omitempty"`
+	Decision string              `json:"Decision"`
+	// Conditional is set when statements that could not be fully evaluated would change the decision.
+	Conditional bool         `json:"Conditional"`
+	Reason      string       `json:"Reason"`
+	Layers      []LayerTrace `json:"Layers"`
+}
+
+// LayerTrace is the evaluation of one layer of policies. Layers the tool cannot read are not evaluated.
+type LayerTrace struct {
+	Layer      string           `json:"Layer"`
+	Evaluated  bool             `json:"Evaluated"`
+	Note       string           `json:"Note,omitempty"`
+	Allows     Match            `json:"Allows,omitempty"`
+	Denies     Match            `json:"Denies,omitempty"`
+	Statements []StatementTrace `json:"Statements,omitempty"`
+}
+
+// StatementTrace is the evaluation of one statement, with the policy and attachment path it came from.
+type StatementTrace struct {
+	Policy         string           `json:"Policy"`
+	Path           string           `json:"Path"`
+	Arn            string           `json:"Arn,omitempty"`
+	File           string           `json:"File,omitempty"`
+	Line           int              `json:"Line,omitempty"`
+	Statement      int              `json:"Statement"`
+	Sid            string           `json:"Sid,omitempty"`
+	Effect         string           `json:"Effect"`
+	Action         Match            `json:"Action"`
+	ActionReason   string           `json:"ActionReason"`
+	Resource       Match            `json:"Resource"`
+	ResourceReason string           `json:"ResourceReason"`
+	Condition      Match            `json:"Condition"`
+	Conditions     []ConditionTrace `json:"Conditions,omitempty"`
+	Applies        Match            `json:"Applies"`
+}
+
+// ConditionTrace is the evaluation of one condition key under one operator.
+type ConditionTrace struct {
+	Operator string   `json:"Operator"`
+	Key      string   `json:"Key"`
+	Values   []string `json:"Values"`
+	Request  []string `json:"Request,omitempty"`
+	Result   Match    `json:"Result"`
+}
+
+// Explain evaluates a request for an action on a resource against an identity's policies and permissions
+// boundary, with the values of condition keys and policy variables in context. Service control policies
+// and session policies are not read, so those layers are assumed to allow the request. Statements whose
+// match is unknown are taken not to apply, and Conditional reports whether they would change the decision.
+func Explain(ident IAM, action string, resource string, context map[string][]string) Explanation {
+	explanation := Explanation{Action: action, Resource: resource, Context: context}
+
+	identity := evaluateLayer(IdentityLayer, ident.Policies, action, resource, context)
+
+	boundary := LayerTrace{Layer: BoundaryLayer, Note: "no permissions boundary is set"}
+	boundaryAllows, boundaryDenies := Matched, NotMatched
+
+	if ident.Boundary != nil {
+		boundary = evaluateLayer(BoundaryLayer, []Policy{*ident.Boundary}, action, resource, context)
+		boundaryAllows, boundaryDenies = boundary.Allows, boundary.Denies
+	}
+
+	explanation.Layers = []LayerTrace{
+		{Layer: SCPLayer, Note: "service control policies are not read; assumed to allow"},
+		boundary,
+		{Layer: SessionLayer, Note: "session policies are not read; assumed to allow"},
+		identity,
+	}
+
+	denies := anyMatch(identity.Denies, boundaryDenies)
+	allows := allMatch(identity.Allows, boundaryAllows)
+
+	switch {
+	case denies == Matched:
+		explanation.Decision = ExplicitDenyDecision
+		explanation.Reason = "denied by " + firstApplying([]LayerTrace{boundary, identity}, Deny)
+	case allows == Matched:
+		explanation.Decision = AllowedDecision
+		explanation.Reason = "allowed by " + firstApplying([]LayerTrace{identity}, Allow)
+		explanation.Conditional = denies == UnknownMatch
+	default:
+		explanation.Decision = ImplicitDenyDecision
+		explanation.Conditional = allows == UnknownMatch
+
+		switch {
+		case identity.Allows != Matched:
+			explanation.Reason = "no identity policy statement allows it"
+		default:
+			explanation.Reason = "the permissions boundary does not allow it"
+		}
+	}
+
+	return explanation
+}
+
+func evaluateLayer(layer string, policies []Policy, action string, resource string, context map[string][]string) LayerTrace {
+	trace := LayerTrace{Layer: layer, Evaluated: true, Allows: NotMatched, Denies: NotMatched}
+
+	for i, policy := range policies {
+		// Snapshots written without sources do not name their policies, so they are named by position
+		if policy.Source.Name == "" {
+			policy.Source.Name = fmt.Sprintf("policy %d", i)
+		}
+
+		for index, statement := range policy.Statements {
+			statementTrace := evaluateStatement(policy, index, statement, action, resource, context)
+			trace.Statements = append(trace.Statements, statementTrace)
+
+			if statement.Effect == Deny {
+				trace.Denies = anyMatch(trace.Denies, statementTrace.Applies)
+			} else {
+				trace.Allows = anyMatch(trace.Allows, statementTrace.Applies)
+			}
+		}
+	}
+
+	return trace
+}
+
+func evaluateStatement(policy Policy, index int, statement Statement, action string, resource string, context map[string][]string) StatementTrace {
+	trace := StatementTrace{
+		Policy:    policy.Source.Name,
+		Path:      policy.Source.String(),
+		Arn:       policy.Source.Arn,
+		File:      policy.Source.File,
+		Line:      policy.Source.Line,
+		Statement: index,
+		Sid:       statement.Sid,
+		Effect:    statement.Effect,
+		Condition: Matched,
+	}
+
+	if index < len(policy.Source.Lines) {
+		trace.Line = policy.Source.Lines[index]
+	}
+
+	trace.Action, trace.ActionReason = matchStatementAction(statement, action)
+	trace.Resource, trace.ResourceReason = matchStatementResource(statement, resource, context)
+
+	for _, operator := range sortedKeys(statement.Condition) {
+		for _, key := range sortedKeys(statement.Condition[operator]) {
+			values := statement.Condition[operator][key]
+			request, _ := contextValues(context, key)
+
+			condition := ConditionTrace{
+				Operator: operator,
+				Key:      key,
+				Values:   values,
+				Request:  request,
+				Result:   EvaluateCondition(operator, key, values, context),
+			}
+
+			trace.Conditions = append(trace.Conditions, condition)
+			trace.Condition = allMatch(trace.Condition, condition.Result)
+		}
+	}
+
+	trace.Applies = allMatch(trace.Action, trace.Resource, trace.Condition)
+
+	return trace
+}
+
+func matchStatementAction(statement Statement, action string) (Match, string) {
+	if len(statement.NotAction) > 0 {
+		for _, pattern := range statement.NotAction {
+			if MatchAction(pattern, action) {
+				return NotMatched, "excluded by NotAction " + pattern
+			}
+		}
+
+		return Matched, "not excluded by NotAction"
+	}
+
+	for _, pattern := range statement.Action {
+		if MatchAction(pattern, action) {
+			return Matched, "Action " + pattern
+		}
+	}
+
+	return NotMatched, "not in Action"
+}
+
+func matchStatementResource(statement Statement, resource string, context map[string][]string) (Match, string) {
+	if len(statement.NotResource) > 0 {
+		result, pattern := matchResource(statement.NotResource, resource, context)
+
+		switch result {
+		case Matched:
+			return NotMatched, "excluded by NotResource " + pattern
+		case UnknownMatch:
+			return UnknownMatch, "unresolved policy variable in NotResource " + pattern
+		default:
+			return Matched, "not excluded by NotResource"
+		}
+	}
+
+	if len(statement.Resource) == 0 {
+		return Matched, "no Resource element"
+	}
+
+	result, pattern := matchResource(statement.Resource, resource, context)
+
+	switch result {
+	case Matched:
+		return Matched, "Resource " + pattern
+	case UnknownMatch:
+		return UnknownMatch, "unresolved policy variable in Resource " + pattern
+	default:
+		return NotMatched, "not in Resource"
+	}
+}
+
+// matchResource returns whether a resource matches any of the patterns, and the pattern that decided it.
+func matchResource(patterns []string, resource string, context map[string][]string) (Match, string) {
+	result, decided := NotMatched, ""
+
+	for _, pattern := range patterns {
+		resolved, ok := resolveVariables(pattern, context)
+		if !ok {
+			if result == NotMatched {
+				result, decided = UnknownMatch, pattern
+			}
+
+			continue
+		}
+
+		if WildcardMatch(resolved, resource) {
+			return Matched, pattern
+		}
+	}
+
+	return result, decided
+}
+
+var policyVariable = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// resolveVariables substitutes the policy variables in a value from the request context. It reports
+// false when a variable has no value there.
+func resolveVariables(value string, context map[string][]string) (string, bool) {
+	resolved := true
+
+	result := policyVariable.ReplaceAllStringFunc(value, func(match string) string {
+		name := match[2 : len(match)-1]
+
+		switch name {
+		case "*", "?", "$":
+			return name
+		}
+
+		// A variable may give a default value after a comma, as in ${aws:username, 'none'}
+		key, fallback, hasDefault := strings.Cut(name, ",")
+
+		if values, ok := contextValues(context, strings.TrimSpace(key)); ok && len(values) == 1 {
+			return values[0]
+		}
+
+		if hasDefault {
+			return strings.Trim(strings.TrimSpace(fallback), "'")
+		}
+
+		resolved = false
+
+		return match
+	})
+
+	return result, resolved
+}
+
+// contextValues looks up a condition key in the request context; keys are case-insensitive.
+func contextValues(context map[string][]string, key string) ([]string, bool) {
+	for name, values := range context {
+		if strings.EqualFold(name, key) {
+			return values, true
+		}
+	}
+
+	return nil, false
+}
+
+// negatedOperators maps each negated condition operator to the operator it negates.
+var negatedOperators = map[string]string{
+	"StringNotEquals":           "StringEquals",
+	"StringNotEqualsIgnoreCase": "StringEqualsIgnoreCase",
+	"StringNotLike":             "StringLike",
+	"ArnNotEquals":              "ArnEquals",
+	"ArnNotLike":                "ArnLike",
+	"NumericNotEquals":          "NumericEquals",
+	"DateNotEquals":             "DateEquals",
+	"NotIpAddress":              "IpAddress",
+}
+
+// EvaluateCondition evaluates one condition key under an operator, such as StringLike or
+// ForAnyValue:StringEqualsIfExists, against the request context. It is unknown when the context does not
+// give the key, since whether the key is present in a real request cannot be told, or when the operator is
+// not supported.
+func EvaluateCondition(operator string, key string, values []string, context map[string][]string) Match {
+	request, ok := contextValues(context, key)
+	if !ok {
+		return UnknownMatch
+	}
+
+	qualifier, base, found := strings.Cut(operator, ":")
+	if !found {
+		qualifier, base = "", operator
+	}
+
+	base = strings.TrimSuffix(base, "IfExists")
+
+	if base == "Null" {
+		// The key is in the context, so it is not null
+		for _, value := range values {
+			if strings.EqualFold(value, "false") {
+				return Matched
+			}
+		}
+
+		return NotMatched
+	}
+
+	positive, negated := base, false
+	if operator, ok := negatedOperators[base]; ok {
+		positive, negated = operator, true
+	}
+
+	var resolved []string
+
+	for _, value := range values {
+		value, ok := resolveVariables(value, context)
+		if !ok {
+			return UnknownMatch
+		}
+
+		resolved = append(resolved, value)
+	}
+
+	results := make([]bool, 0, len(request))
+
+	for _, actual := range request {
+		matched := false
+
+		for _, expected := range resolved {
+			result, err := compareCondition(positive, actual, expected)
+			if err != nil {
+				return UnknownMatch
+			}
+
+			matched = matched || result
+		}
+
+		results = append(results, matched != negated)
+	}
+
+	switch qualifier {
+	case "ForAllValues":
+		for _, result := range results {
+			if !result {
+				return NotMatched
+			}
+		}
+
+		return Matched
+	case "", "ForAnyValue":
+		for _, result := range results {
+			if result {
+				return Matched
+			}
+		}
+
+		return NotMatched
+	default:
+		return UnknownMatch
+	}
+}
+
+func compareCondition(operator string, actual string, expected string) (bool, error) {
+	switch operator {
+	case "StringEquals":
+		return actual == expected, nil
+	case "StringEqualsIgnoreCase":
+		return strings.EqualFold(actual, expected), nil
+	case "StringLike", "ArnEquals", "ArnLike":
+		return WildcardMatch(expected, actual), nil
+	case "Bool":
+		return strings.EqualFold(actual, expected), nil
+	case "NumericEquals", "NumericLessThan", "NumericLessThanEquals", "NumericGreaterThan", "NumericGreaterThanEquals":
+		left, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false, err
+		}
+
+		right, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false, err
+		}
+
+		return compareOrdered(operator, left, right), nil
+	case "DateEquals", "DateLessThan", "DateLessThanEquals", "DateGreaterThan", "DateGreaterThanEquals":
+		left, err := parseConditionDate(actual)
+		if err != nil {
+			return false, err
+		}
+
+		right, err := parseConditionDate(expected)
+		if err != nil {
+			return false, err
+		}
+
+		return compareOrdered(operator, float64(left.UnixNano()), float64(right.UnixNano())), nil
+	case "IpAddress":
+		if !strings.Contains(expected, "/") {
+			expected += "/32"
+			if strings.Contains(expected, ":") {
+				expected = strings.TrimSuffix(expected, "/32") + "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(expected)
+		if err != nil {
+			return false, err
+		}
+
+		ip := net.ParseIP(actual)
+		if ip == nil {
+			return false, fmt.Errorf("invalid IP address %s", actual)
+		}
+
+		return network.Contains(ip), nil
+	default:
+		return false, fmt.Errorf("unsupported condition operator %s", operator)
+	}
+}
+
+func compareOrdered(operator string, left float64, right float64) bool {
+	switch {
+	case strings.HasSuffix(operator, "LessThan"):
+		return left < right
+	case strings.HasSuffix(operator, "LessThanEquals"):
+		return left <= right
+	case strings.HasSuffix(operator, "GreaterThan"):
+		return left > right
+	case strings.HasSuffix(operator, "GreaterThanEquals"):
+		return left >= right
+	default:
+		return left == right
+	}
+}
+
+// parseConditionDate parses a date condition value, written in ISO 8601 or as epoch seconds.
+func parseConditionDate(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %s", value)
+}
+
+// anyMatch is true when any of the matches is, unknown when none is but one is unknown, and false otherwise.
+func anyMatch(matches ...Match) Match {
+	result := NotMatched
+
+	for _, match := range matches {
+		switch match {
+		case Matched:
+			return Matched
+		case UnknownMatch:
+			result = UnknownMatch
+		}
+	}
+
+	return result
+}
+
+// allMatch is false when any of the matches is, unknown when none is but one is unknown, and true otherwise.
+func allMatch(matches ...Match) Match {
+	result := Matched
+
+	for _, match := range matches {
+		switch match {
+		case NotMatched:
+			return NotMatched
+		case UnknownMatch:
+			result = UnknownMatch
+		}
+	}
+
+	return result
+}
+
+// firstApplying describes the first statement with an effect that applies in the layers.
+func firstApplying(layers []LayerTrace, effect string) string {
+	for _, layer := range layers {
+		for _, statement := range layer.Statements {
+			if statement.Effect == effect && statement.Applies == Matched {
+				return fmt.Sprintf("%s statement %d", statement.Path, statement.Statement)
+			}
+		}
+	}
+
+	return "no statement"
+}
@@ -0,0 +1,244 @@
+package Identity
+
+import (
+	"testing"
+)
+
+func explainIdentity(t *testing.T, boundary bool) IAM {
+	t.Helper()
+
+	parse := func(document string, source Source) Policy {
+		policy, err := Parse(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		policy.Source = source
+
+		return policy
+	}
+
+	ident := IAM{
+		Name:    "basic",
+		Account: "680235478471",
+		IamType: UserType,
+		Policies: []Policy{
+			parse(`{"Version":"2012-10-17","Statement":[{"Sid":"Buckets","Effect":"Allow","Action":"s3:*","Resource":["arn:aws:s3:::prod-*","arn:aws:s3:::${aws:username}-*"]}]}`,
+				Source{Name: "storage", Arn: "arn:aws:iam::680235478471:policy/storage", Kind: ManagedKind, Via: []string{"user/basic", "group/devs"}}),
+			parse(`{"Version":"2012-10-17","Statement":[{"Sid":"NoDeleteWithoutMFA","Effect":"Deny","Action":"s3:DeleteBucket","Resource":"*","Condition":{"BoolIfExists":{"aws:MultiFactorAuthPresent":"false"}}}]}`,
+				Source{Name: "guard", Kind: InlineKind, Via: []string{"user/basic"}}),
+			parse(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","NotAction":"iam:*","Resource":"*","Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`,
+				Source{Name: "office", Kind: InlineKind, Via: []string{"user/basic"}}),
+		},
+	}
+
+	if boundary {
+		limit := parse(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:Get*","s3:List*","ec2:*"],"Resource":"*"}]}`,
+			Source{Name: "limit", Arn: "arn:aws:iam::680235478471:policy/limit", Kind: BoundaryKind, Via: []string{"user/basic"}})
+		ident.Boundary = &limit
+	}
+
+	return ident
+}
+
+func TestExplain(t *testing.T) {
+	tests := []struct {
+		name        string
+		boundary    bool
+		action      string
+		resource    string
+		context     map[string][]string
+		decision    string
+		conditional bool
+		reason      string
+	}{
+		{
+			name:     "deny_without_mfa",
+			action:   "s3:DeleteBucket",
+			resource: "arn:aws:s3:::prod-logs",
+			context:  map[string][]string{"aws:MultiFactorAuthPresent": {"false"}},
+			decision: ExplicitDenyDecision,
+			reason:   "denied by user/basic > guard statement 0",
+		},
+		{
+			name:     "allow_with_mfa",
+			action:   "s3:DeleteBucket",
+			resource: "arn:aws:s3:::prod-logs",
+			context:  map[string][]string{"aws:multifactorauthpresent": {"true"}},
+			decision: AllowedDecision,
+			reason:   "allowed by user/basic > group/devs > storage statement 0",
+		},
+		{
+			name:        "allow_unless_mfa_missing",
+			action:      "s3:DeleteBucket",
+			resource:    "arn:aws:s3:::prod-logs",
+			decision:    AllowedDecision,
+			conditional: true,
+			reason:      "allowed by user/basic > group/devs > storage statement 0",
+		},
+		{
+			name:     "policy_variable",
+			action:   "s3:GetObject",
+			resource: "arn:aws:s3:::basic-home",
+			context:  map[string][]string{"aws:username": {"basic"}},
+			decision: AllowedDecision,
+			reason:   "allowed by user/basic > group/devs > storage statement 0",
+		},
+		{
+			name:        "unresolved_policy_variable",
+			action:      "s3:GetObject",
+			resource:    "arn:aws:s3:::basic-home",
+			decision:    ImplicitDenyDecision,
+			conditional: true,
+			reason:      "no identity policy statement allows it",
+		},
+		{
+			name:     "not_action_from_office",
+			action:   "ec2:RunInstances",
+			resource: "*",
+			context:  map[string][]string{"aws:SourceIp": {"10.1.2.3"}},
+			decision: AllowedDecision,
+			reason:   "allowed by user/basic > office statement 0",
+		},
+		{
+			name:     "not_action_from_elsewhere",
+			action:   "ec2:RunInstances",
+			resource: "*",
+			context:  map[string][]string{"aws:SourceIp": {"192.0.2.1"}},
+			decision: ImplicitDenyDecision,
+			reason:   "no identity policy statement allows it",
+		},
+		{
+			name:     "excluded_by_not_action",
+			action:   "iam:CreateUser",
+			resource: "*",
+			context:  map[string][]string{"aws:SourceIp": {"10.1.2.3"}},
+			decision: ImplicitDenyDecision,
+			reason:   "no identity policy statement allows it",
+		},
+		{
+			name:     "boundary_blocks",
+			boundary: true,
+			action:   "s3:PutObject",
+			resource: "arn:aws:s3:::prod-logs/key",
+			decision: ImplicitDenyDecision,
+			reason:   "the permissions boundary does not allow it",
+		},
+		{
+			name:     "boundary_allows",
+			boundary: true,
+			action:   "s3:GetObject",
+			resource: "arn:aws:s3:::prod-logs/key",
+			decision: AllowedDecision,
+			reason:   "allowed by user/basic > group/devs > storage statement 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Explain(explainIdentity(t, tt.boundary), tt.action, tt.resource, tt.context)
+
+			if got.Decision != tt.decision || got.Conditional != tt.conditional || got.Reason != tt.reason {
+				t.Errorf("Explain() = %s, conditional %v, %q, want %s, conditional %v, %q",
+					got.Decision, got.Conditional, got.Reason, tt.decision, tt.conditional, tt.reason)
+			}
+
+			if len(got.Layers) != 4 {
+				t.Fatalf("Explain() has %d layers, want 4", len(got.Layers))
+			}
+
+			if got.Layers[1].Evaluated != tt.boundary {
+				t.Errorf("boundary layer evaluated = %v, want %v", got.Layers[1].Evaluated, tt.boundary)
+			}
+
+			identity := got.Layers[3]
+			if identity.Layer != IdentityLayer || len(identity.Statements) != 3 {
+				t.Fatalf("identity layer = %+v, want every statement traced", identity)
+			}
+		})
+	}
+}
+
+func TestExplain_Trace(t *testing.T) {
+	got := Explain(explainIdentity(t, false), "s3:DeleteBucket", "arn:aws:s3:::prod-logs", map[string][]string{"aws:MultiFactorAuthPresent": {"false"}})
+
+	want := []StatementTrace{
+		{Action: Matched, ActionReason: "Action s3:*", Resource: Matched, ResourceReason: "Resource arn:aws:s3:::prod-*", Condition: Matched, Applies: Matched},
+		{Action: Matched, ActionReason: "Action s3:DeleteBucket", Resource: Matched, ResourceReason: "Resource *", Condition: Matched, Applies: Matched},
+		{Action: Matched, ActionReason: "not excluded by NotAction", Resource: Matched, ResourceReason: "Resource *", Condition: UnknownMatch, Applies: UnknownMatch},
+	}
+
+	for i, statement := range got.Layers[3].Statements {
+		if statement.Action != want[i].Action || statement.ActionReason != want[i].ActionReason ||
+			statement.Resource != want[i].Resource || statement.ResourceReason != want[i].ResourceReason ||
+			statement.Condition != want[i].Condition || statement.Applies != want[i].Applies {
+			t.Errorf("statement %d = %+v, want %+v", i, statement, want[i])
+		}
+	}
+
+	guard := got.Layers[3].Statements[1]
+	if guard.Path != "user/basic > guard" || guard.Sid != "NoDeleteWithoutMFA" || len(guard.Conditions) != 1 {
+		t.Fatalf("statement 1 = %+v, want the guard policy's statement with its condition", guard)
+	}
+
+	if condition := guard.Conditions[0]; condition.Operator != "BoolIfExists" || condition.Request[0] != "false" || condition.Result != Matched {
+		t.Errorf("condition = %+v, want BoolIfExists matched on false", condition)
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	context := map[string][]string{
+		"aws:SourceIp":              {"10.1.2.3"},
+		"aws:PrincipalTag/team":     {"Platform"},
+		"aws:TagKeys":               {"team", "env"},
+		"aws:CurrentTime":           {"2026-10-19T12:00:00Z"},
+		"s3:max-keys":               {"50"},
+		"aws:SourceArn":             {"arn:aws:sns:eu-west-2:680235478471:alerts"},
+		"aws:username":              {"basic"},
+		"aws:PrincipalTag/username": {"basic"},
+	}
+
+	tests := []struct {
+		operator string
+		key      string
+		values   []string
+		want     Match
+	}{
+		{"StringEquals", "aws:PrincipalTag/team", []string{"Platform"}, Matched},
+		{"StringEquals", "aws:PrincipalTag/team", []string{"platform"}, NotMatched},
+		{"StringEqualsIgnoreCase", "aws:PrincipalTag/team", []string{"platform"}, Matched},
+		{"StringNotEquals", "aws:PrincipalTag/team", []string{"Security", "Audit"}, Matched},
+		{"StringLike", "aws:PrincipalTag/team", []string{"Plat*"}, Matched},
+		{"StringNotLike", "aws:PrincipalTag/team", []string{"Plat*"}, NotMatched},
+		{"StringEquals", "aws:PrincipalTag/username", []string{"${aws:username}"}, Matched},
+		{"StringEquals", "aws:PrincipalTag/username", []string{"${aws:userid}"}, UnknownMatch},
+		{"ArnLike", "aws:SourceArn", []string{"arn:aws:sns:*:680235478471:*"}, Matched},
+		{"ArnNotEquals", "aws:SourceArn", []string{"arn:aws:sns:eu-west-2:680235478471:alerts"}, NotMatched},
+		{"IpAddress", "aws:SourceIp", []string{"10.0.0.0/8"}, Matched},
+		{"IpAddress", "aws:SourceIp", []string{"10.1.2.3"}, Matched},
+		{"NotIpAddress", "aws:SourceIp", []string{"10.0.0.0/8"}, NotMatched},
+		{"NumericLessThanEquals", "s3:max-keys", []string{"100"}, Matched},
+		{"NumericGreaterThan", "s3:max-keys", []string{"100"}, NotMatched},
+		{"NumericEquals", "s3:max-keys", []string{"many"}, UnknownMatch},
+		{"DateLessThan", "aws:CurrentTime", []string{"2027-01-01T00:00:00Z"}, Matched},
+		{"DateGreaterThan", "aws:CurrentTime", []string{"1798761600"}, NotMatched},
+		{"ForAllValues:StringEquals", "aws:TagKeys", []string{"team", "env", "owner"}, Matched},
+		{"ForAllValues:StringEquals", "aws:TagKeys", []string{"team"}, NotMatched},
+		{"ForAnyValue:StringEquals", "aws:TagKeys", []string{"env"}, Matched},
+		{"StringEqualsIfExists", "aws:PrincipalTag/team", []string{"Platform"}, Matched},
+		{"StringEqualsIfExists", "aws:PrincipalTag/cost-centre", []string{"42"}, UnknownMatch},
+		{"Null", "aws:PrincipalTag/team", []string{"false"}, Matched},
+		{"Null", "aws:PrincipalTag/team", []string{"true"}, NotMatched},
+		{"Null", "aws:PrincipalTag/cost-centre", []string{"true"}, UnknownMatch},
+		{"StringEquals", "aws:RequestedRegion", []string{"eu-west-2"}, UnknownMatch},
+		{"BinaryEquals", "aws:PrincipalTag/team", []string{"UGxhdGZvcm0="}, UnknownMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operator+"_"+tt.key, func(t *testing.T) {
+			if got := EvaluateCondition(tt.operator, tt.key, tt.values, context); got != tt.want {
+				t.Errorf("EvaluateCondition(%s, %s, %v) = %s, want %s", tt.operator, tt.key, tt.values, got, tt.want)
+			}
+		})
+	}
+}
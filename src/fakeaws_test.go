@@ -0,0 +1,704 @@
+package Identity
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeAccount is the fixture a fakeAWS server answers from: an account's users, groups, roles and
+// managed policies, and the identity that the caller's credentials belong to.
+type fakeAccount struct {
+	Account  string              `json:"Account"`
+	Caller   string              `json:"Caller"`
+	PageSize int                 `json:"PageSize"`
+	Users    []fakePrincipal     `json:"Users"`
+	Groups   []fakePrincipal     `json:"Groups"`
+	Roles    []fakePrincipal     `json:"Roles"`
+	Policies []fakeManagedPolicy `json:"Policies"`
+}
+
+type fakePrincipal struct {
+	Name                     string             `json:"Name"`
+	Path                     string             `json:"Path,omitempty"`
+	Policies                 []fakeInlinePolicy `json:"Policies,omitempty"`
+	AttachedPolicies         []string           `json:"AttachedPolicies,omitempty"`
+	Groups                   []string           `json:"Groups,omitempty"`
+	PermissionsBoundary      string             `json:"PermissionsBoundary,omitempty"`
+	AssumeRolePolicyDocument string             `json:"AssumeRolePolicyDocument,omitempty"`
+}
+
+type fakeInlinePolicy struct {
+	Name     string `json:"Name"`
+	Document string `json:"Document"`
+}
+
+type fakeManagedPolicy struct {
+	Arn              string              `json:"Arn"`
+	PolicyID         string              `json:"PolicyId,omitempty"`
+	DefaultVersionID string              `json:"DefaultVersionId,omitempty"`
+	Versions         []fakePolicyVersion `json:"Versions"`
+}
+
+type fakePolicyVersion struct {
+	VersionID  string `json:"VersionId"`
+	CreateDate string `json:"CreateDate,omitempty"`
+	Document   string `json:"Document"`
+}
+
+func (v fakePolicyVersion) created() string {
+	if v.CreateDate != "" {
+		return v.CreateDate
+	}
+
+	return "2020-01-01T00:00:00Z"
+}
+
+// fakeError is an AWS query protocol error.
+type fakeError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *fakeError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// fakeAWS is a local endpoint that speaks enough of the IAM and STS query protocol for the tool:
+// GetCallerIdentity, AssumeRole, and the Get and List calls for users, groups, roles and policies,
+// with Marker and MaxItems pagination. Both services share the endpoint, as the action names differ.
+type fakeAWS struct {
+	account fakeAccount
+	server  *httptest.Server
+
+	mutex sync.Mutex
+	// throttle holds how many more calls of each action to refuse with Throttling.
+	throttle map[string]int
+}
+
+// readFakeAccount reads a fixture from testdata/fake.
+func readFakeAccount(name string) (fakeAccount, error) {
+	var account fakeAccount
+
+	raw, err := os.ReadFile(filepath.Join("testdata", "fake", name))
+	if err != nil {
+		return account, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return account, fmt.Errorf("failed to parse fixture %s: %w", name, err)
+	}
+
+	return account, nil
+}
+
+// startFakeAWS starts a fake endpoint for an account. The caller closes its server.
+func startFakeAWS(account fakeAccount) *fakeAWS {
+	fake := &fakeAWS{account: account, throttle: map[string]int{}}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serve))
+
+	return fake
+}
+
+// useFakeAWS points the AWS SDK at a fake endpoint seeded from a fixture, with a shared config profile
+// and dummy credentials, so that code under test loads its configuration as it would against AWS.
+func useFakeAWS(t *testing.T, name string) *fakeAWS {
+	t.Helper()
+
+	account, err := readFakeAccount(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := startFakeAWS(account)
+	t.Cleanup(fake.server.Close)
+
+	for key, value := range fake.environment(t.TempDir()) {
+		t.Setenv(key, value)
+	}
+
+	// No cache, the library default, so that no test sees the default policy versions another looked up
+	SetPolicyCache(nil)
+	t.Cleanup(func() { SetPolicyCache(nil) })
+
+	return fake
+}
+
+// environment returns the variables that point the SDK at the fake, writing its shared config to dir.
+func (f *fakeAWS) environment(dir string) map[string]string {
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+
+	_ = os.WriteFile(configFile, []byte("[profile "+defaultProfile+"]\nregion = us-east-1\n"), 0o600)
+	_ = os.WriteFile(credentialsFile, []byte("["+defaultProfile+"]\naws_access_key_id = AKIAFAKE\naws_secret_access_key = fake\n"), 0o600)
+
+	return map[string]string{
+		"AWS_ENDPOINT_URL":            f.server.URL,
+		"AWS_CONFIG_FILE":             configFile,
+		"AWS_SHARED_CREDENTIALS_FILE": credentialsFile,
+		"AWS_PROFILE":                 defaultProfile,
+		"AWS_ACCESS_KEY_ID":           "",
+		"AWS_SECRET_ACCESS_KEY":       "",
+		"AWS_SESSION_TOKEN":           "",
+		"AWS_EC2_METADATA_DISABLED":   "true",
+		"IAM_ROLE_NAME":               defaultRoleName,
+		cacheDirEnv:                   filepath.Join(dir, "cache"),
+	}
+}
+
+func (f *fakeAWS) serve(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.writeError(w, &fakeError{http.StatusBadRequest, "MalformedQueryString", err.Error()})
+
+		return
+	}
+
+	action := r.Form.Get("Action")
+
+	if f.throttled(action) {
+		f.writeError(w, &fakeError{http.StatusBadRequest, "Throttling", "Rate exceeded"})
+
+		return
+	}
+
+	var result interface{}
+
+	caller, err := f.authorize(r, action)
+	if err == nil {
+		result, err = f.handle(action, r.Form, caller)
+	}
+	if err != nil {
+		f.writeError(w, err)
+
+		return
+	}
+
+	body, err := xml.Marshal(xmlResult{
+		{action + "Result", result},
+		{"ResponseMetadata", xmlResult{{"RequestId", "fake"}}},
+	})
+	if err != nil {
+		f.writeError(w, &fakeError{http.StatusInternalServerError, "ServiceFailure", err.Error()})
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, "<%sResponse>%s</%sResponse>", action, body, action)
+}
+
+func (f *fakeAWS) writeError(w http.ResponseWriter, err error) {
+	failure, ok := err.(*fakeError)
+	if !ok {
+		failure = &fakeError{http.StatusInternalServerError, "ServiceFailure", err.Error()}
+	}
+
+	var message strings.Builder
+	_ = xml.EscapeText(&message, []byte(failure.message))
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(failure.status)
+	fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>fake</RequestId></ErrorResponse>`,
+		failure.code, message.String())
+}
+
+// xmlResult is a sequence of XML elements, written in order. Nested inside another element it is
+// wrapped in that element; on its own it is written bare.
+type xmlResult []xmlElement
+
+type xmlElement struct {
+	name  string
+	value interface{}
+}
+
+func (r xmlResult) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	nested := start.Name.Local != "xmlResult"
+
+	if nested {
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+	}
+
+	for _, element := range r {
+		if err := e.EncodeElement(element.value, xml.StartElement{Name: xml.Name{Local: element.name}}); err != nil {
+			return err
+		}
+	}
+
+	if nested {
+		return e.EncodeToken(start.End())
+	}
+
+	return nil
+}
+
+// fakeJobID is the id of the only service last accessed job the fake runs.
+const fakeJobID = "fake-job"
+
+type xmlMembers struct {
+	Members []interface{} `xml:"member"`
+}
+
+// throttled reports whether to refuse a call of action, counting down the calls still to refuse.
+func (f *fakeAWS) throttled(action string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.throttle[action] == 0 {
+		return false
+	}
+
+	f.throttle[action]--
+
+	return true
+}
+
+// fakeRoleKey prefixes the access key of credentials from AssumeRole, which is followed by the role name.
+const fakeRoleKey = "ASIAFAKE"
+
+// authorize returns the ARN of the identity that signed a request. IAM calls signed with a role's
+// credentials must be allowed by the role's policies; the caller's own credentials may do anything.
+func (f *fakeAWS) authorize(r *http.Request, action string) (string, error) {
+	credential := r.Header.Get("Authorization")
+	if start := strings.Index(credential, "Credential="); start >= 0 {
+		credential = credential[start+len("Credential="):]
+	}
+
+	key, _, _ := strings.Cut(credential, "/")
+
+	role, assumed := strings.CutPrefix(key, fakeRoleKey)
+	if !assumed {
+		return f.account.Caller, nil
+	}
+
+	arn := fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/fake", f.account.Account, role)
+
+	if action == "GetCallerIdentity" || action == "AssumeRole" {
+		return arn, nil
+	}
+
+	principal, err := f.principal(f.account.Roles, RoleType, role)
+	if err != nil {
+		return "", err
+	}
+
+	ident := IAM{Name: role, IamType: RoleType, Account: f.account.Account}
+
+	for _, inline := range principal.Policies {
+		policy, err := Parse(inline.Document)
+		if err != nil {
+			return "", err
+		}
+
+		ident.Policies = append(ident.Policies, policy)
+	}
+
+	for _, attached := range principal.AttachedPolicies {
+		managed, err := f.managedPolicy(attached)
+		if err != nil {
+			return "", err
+		}
+
+		for _, version := range managed.Versions {
+			if version.VersionID == managed.defaultVersion() {
+				policy, err := Parse(version.Document)
+				if err != nil {
+					return "", err
+				}
+
+				ident.Policies = append(ident.Policies, policy)
+			}
+		}
+	}
+
+	if !Effective(ident).Allowed("iam:" + action) {
+		return "", &fakeError{http.StatusForbidden, "AccessDenied", fmt.Sprintf("User: %s is not authorized to perform: iam:%s", arn, action)}
+	}
+
+	return arn, nil
+}
+
+func (f *fakeAWS) handle(action string, form url.Values, caller string) (interface{}, error) {
+	switch action {
+	case "GetCallerIdentity":
+		return xmlResult{{"Arn", caller}, {"UserId", "AIDAFAKE"}, {"Account", f.account.Account}}, nil
+	case "AssumeRole":
+		return f.assumeRole(form.Get("RoleArn"), form.Get("RoleSessionName"))
+	case "GetUser":
+		user, err := f.principal(f.account.Users, UserType, form.Get("UserName"))
+		if err != nil {
+			return nil, err
+		}
+
+		return xmlResult{{"User", f.entity(UserType, user)}}, nil
+	case "GetRole":
+		role, err := f.principal(f.account.Roles, RoleType, form.Get("RoleName"))
+		if err != nil {
+			return nil, err
+		}
+
+		return xmlResult{{"Role", f.entity(RoleType, role)}}, nil
+	case "GetUserPolicy":
+		return f.inlinePolicy(f.account.Users, UserType, "UserName", form)
+	case "GetRolePolicy":
+		return f.inlinePolicy(f.account.Roles, RoleType, "RoleName", form)
+	case "GetGroupPolicy":
+		return f.inlinePolicy(f.account.Groups, GroupType, "GroupName", form)
+	case "ListUserPolicies":
+		return f.policyNames(f.account.Users, UserType, "UserName", form)
+	case "ListRolePolicies":
+		return f.policyNames(f.account.Roles, RoleType, "RoleName", form)
+	case "ListGroupPolicies":
+		return f.policyNames(f.account.Groups, GroupType, "GroupName", form)
+	case "ListAttachedUserPolicies":
+		return f.attachedPolicies(f.account.Users, UserType, "UserName", form)
+	case "ListAttachedRolePolicies":
+		return f.attachedPolicies(f.account.Roles, RoleType, "RoleName", form)
+	case "ListAttachedGroupPolicies":
+		return f.attachedPolicies(f.account.Groups, GroupType, "GroupName", form)
+	case "ListGroupsForUser":
+		user, err := f.principal(f.account.Users, UserType, form.Get("UserName"))
+		if err != nil {
+			return nil, err
+		}
+
+		var groups []interface{}
+		for _, name := range user.Groups {
+			group, err := f.principal(f.account.Groups, GroupType, name)
+			if err != nil {
+				return nil, err
+			}
+
+			groups = append(groups, f.entity(GroupType, group))
+		}
+
+		return f.page("Groups", groups, form)
+	case "GetPolicy":
+		policy, err := f.managedPolicy(form.Get("PolicyArn"))
+		if err != nil {
+			return nil, err
+		}
+
+		return xmlResult{{"Policy", xmlResult{
+			{"PolicyName", policy.Arn[strings.LastIndex(policy.Arn, "/")+1:]},
+			{"PolicyId", policy.id()},
+			{"Arn", policy.Arn},
+			{"Path", "/"},
+			{"DefaultVersionId", policy.defaultVersion()},
+			{"AttachmentCount", 1},
+			{"IsAttachable", true},
+		}}}, nil
+	case "GetPolicyVersion":
+		policy, err := f.managedPolicy(form.Get("PolicyArn"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, version := range policy.Versions {
+			if version.VersionID == form.Get("VersionId") {
+				return xmlResult{{"PolicyVersion", xmlResult{
+					{"Document", url.QueryEscape(version.Document)},
+					{"VersionId", version.VersionID},
+					{"IsDefaultVersion", version.VersionID == policy.defaultVersion()},
+					{"CreateDate", version.created()},
+				}}}, nil
+			}
+		}
+
+		return nil, noSuchEntity("policy version", form.Get("VersionId"))
+	case "ListPolicyVersions":
+		policy, err := f.managedPolicy(form.Get("PolicyArn"))
+		if err != nil {
+			return nil, err
+		}
+
+		// IAM lists the newest version first
+		var versions []interface{}
+		for i := len(policy.Versions) - 1; i >= 0; i-- {
+			version := policy.Versions[i]
+			versions = append(versions, xmlResult{
+				{"VersionId", version.VersionID},
+				{"IsDefaultVersion", version.VersionID == policy.defaultVersion()},
+				{"CreateDate", version.created()},
+			})
+		}
+
+		return f.page("Versions", versions, form)
+	case "GenerateServiceLastAccessedDetails":
+		return xmlResult{{"JobId", fakeJobID}}, nil
+	case "GetServiceLastAccessedDetails":
+		if form.Get("JobId") != fakeJobID {
+			return nil, noSuchEntity("job", form.Get("JobId"))
+		}
+
+		return xmlResult{
+			{"JobStatus", "COMPLETED"},
+			{"JobType", "ACTION_LEVEL"},
+			{"JobCreationDate", "2020-01-01T00:00:00Z"},
+			{"JobCompletionDate", "2020-01-01T00:00:00Z"},
+			{"ServicesLastAccessed", xmlMembers{}},
+			{"IsTruncated", false},
+		}, nil
+	}
+
+	return nil, &fakeError{http.StatusBadRequest, "InvalidAction", "the fake does not implement " + action}
+}
+
+// assumeRole allows a role whose trust policy names the caller, its account root, or anyone.
+func (f *fakeAWS) assumeRole(arn string, session string) (interface{}, error) {
+	name := arn[strings.LastIndex(arn, "/")+1:]
+
+	role, err := f.principal(f.account.Roles, RoleType, name)
+	if err != nil || arn != f.arn(RoleType, name) {
+		return nil, &fakeError{http.StatusForbidden, "AccessDenied", fmt.Sprintf("User: %s is not authorized to perform: sts:AssumeRole on resource: %s", f.account.Caller, arn)}
+	}
+
+	trust, err := Parse(role.AssumeRolePolicyDocument)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := false
+
+	for _, statement := range trust.Statements {
+		for _, principal := range statement.Principal["AWS"] {
+			if statement.Effect == Allow && (principal == f.account.Caller || principal == anonymousPrincipal || principal == "arn:aws:iam::"+f.account.Account+":root") {
+				trusted = true
+			}
+		}
+	}
+
+	if !trusted {
+		return nil, &fakeError{http.StatusForbidden, "AccessDenied", fmt.Sprintf("User: %s is not authorized to perform: sts:AssumeRole on resource: %s", f.account.Caller, arn)}
+	}
+
+	return xmlResult{
+		{"Credentials", xmlResult{
+			{"AccessKeyId", fakeRoleKey + name},
+			{"SecretAccessKey", "fake"},
+			{"SessionToken", "fake"},
+			{"Expiration", "2099-01-01T00:00:00Z"},
+		}},
+		{"AssumedRoleUser", xmlResult{
+			{"AssumedRoleId", "AROAFAKE:" + session},
+			{"Arn", fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", f.account.Account, name, session)},
+		}},
+	}, nil
+}
+
+func (f *fakeAWS) inlinePolicy(principals []fakePrincipal, kind string, field string, form url.Values) (interface{}, error) {
+	principal, err := f.principal(principals, kind, form.Get(field))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, policy := range principal.Policies {
+		if policy.Name == form.Get("PolicyName") {
+			return xmlResult{{field, principal.Name}, {"PolicyName", policy.Name}, {"PolicyDocument", url.QueryEscape(policy.Document)}}, nil
+		}
+	}
+
+	return nil, noSuchEntity("policy", form.Get("PolicyName"))
+}
+
+func (f *fakeAWS) policyNames(principals []fakePrincipal, kind string, field string, form url.Values) (interface{}, error) {
+	principal, err := f.principal(principals, kind, form.Get(field))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []interface{}
+	for _, policy := range principal.Policies {
+		names = append(names, policy.Name)
+	}
+
+	return f.page("PolicyNames", names, form)
+}
+
+func (f *fakeAWS) attachedPolicies(principals []fakePrincipal, kind string, field string, form url.Values) (interface{}, error) {
+	principal, err := f.principal(principals, kind, form.Get(field))
+	if err != nil {
+		return nil, err
+	}
+
+	var attached []interface{}
+	for _, arn := range principal.AttachedPolicies {
+		attached = append(attached, xmlResult{{"PolicyName", arn[strings.LastIndex(arn, "/")+1:]}, {"PolicyArn", arn}})
+	}
+
+	return f.page("AttachedPolicies", attached, form)
+}
+
+// page returns the items after Marker, up to MaxItems or the fixture's page size, with the marker of the next page.
+func (f *fakeAWS) page(name string, items []interface{}, form url.Values) (interface{}, error) {
+	start := 0
+	if marker := form.Get("Marker"); marker != "" {
+		var err error
+		if start, err = strconv.Atoi(marker); err != nil || start < 0 || start > len(items) {
+			return nil, &fakeError{http.StatusBadRequest, "InvalidInput", "invalid marker " + marker}
+		}
+	}
+
+	size := f.account.PageSize
+	if maxItems, err := strconv.Atoi(form.Get("MaxItems")); err == nil && maxItems > 0 {
+		size = maxItems
+	}
+
+	if size <= 0 {
+		size = 100
+	}
+
+	end := min(start+size, len(items))
+	result := xmlResult{{name, xmlMembers{Members: items[start:end]}}, {"IsTruncated", end < len(items)}}
+
+	if end < len(items) {
+		result = append(result, xmlElement{"Marker", strconv.Itoa(end)})
+	}
+
+	return result, nil
+}
+
+func (f *fakeAWS) principal(principals []fakePrincipal, kind string, name string) (fakePrincipal, error) {
+	for _, principal := range principals {
+		if principal.Name == name {
+			return principal, nil
+		}
+	}
+
+	return fakePrincipal{}, noSuchEntity(kind, name)
+}
+
+func (f *fakeAWS) entity(kind string, principal fakePrincipal) xmlResult {
+	path := principal.Path
+	if path == "" {
+		path = "/"
+	}
+
+	title := strings.ToUpper(kind[:1]) + kind[1:]
+	entity := xmlResult{
+		{"Path", path},
+		{title + "Name", principal.Name},
+		{title + "Id", "AIDAFAKE" + strings.ToUpper(principal.Name)},
+		{"Arn", f.arn(kind, principal.Name)},
+		{"CreateDate", "2020-01-01T00:00:00Z"},
+	}
+
+	if principal.AssumeRolePolicyDocument != "" {
+		entity = append(entity, xmlElement{"AssumeRolePolicyDocument", url.QueryEscape(principal.AssumeRolePolicyDocument)})
+	}
+
+	if principal.PermissionsBoundary != "" {
+		entity = append(entity, xmlElement{"PermissionsBoundary", xmlResult{
+			{"PermissionsBoundaryType", "Policy"},
+			{"PermissionsBoundaryArn", principal.PermissionsBoundary},
+		}})
+	}
+
+	return entity
+}
+
+func (f *fakeAWS) managedPolicy(arn string) (fakeManagedPolicy, error) {
+	for _, policy := range f.account.Policies {
+		if policy.Arn == arn {
+			return policy, nil
+		}
+	}
+
+	return fakeManagedPolicy{}, noSuchEntity("policy", arn)
+}
+
+func (p fakeManagedPolicy) id() string {
+	if p.PolicyID != "" {
+		return p.PolicyID
+	}
+
+	return "ANPAFAKE"
+}
+
+func (p fakeManagedPolicy) defaultVersion() string {
+	if p.DefaultVersionID != "" {
+		return p.DefaultVersionID
+	}
+
+	return p.Versions[len(p.Versions)-1].VersionID
+}
+
+func (f *fakeAWS) arn(kind string, name string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:%s/%s", f.account.Account, kind, name)
+}
+
+func noSuchEntity(kind string, name string) *fakeError {
+	return &fakeError{http.StatusNotFound, "NoSuchEntity", fmt.Sprintf("The %s with name %s cannot be found.", kind, name)}
+}
+
+func TestFakeAWS(t *testing.T) {
+	useFakeAWS(t, "account.json")
+
+	ctx := context.Background()
+
+	ident, err := GetIam(ctx)
+	if err != nil {
+		t.Fatalf("GetIam() error = %v", err)
+	}
+
+	if ident.Ref() != "user/basic" || ident.Account != "680235478471" || len(ident.Policies) != 1 {
+		t.Errorf("GetIam() = %+v, want user/basic in 680235478471 with one policy", ident)
+	}
+
+	// the fixture's page size is 1, so both of the group's inline policies need a second page
+	group, err := GetPoliciesForGroup(ctx, IAM{Name: "multipolicygroup", IamType: GroupType, Account: "680235478471"})
+	if err != nil {
+		t.Fatalf("GetPoliciesForGroup() error = %v", err)
+	}
+
+	var names []string
+	for _, policy := range group.Policies {
+		names = append(names, policy.Source.Name)
+	}
+
+	if want := []string{"policya", "policyb"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("GetPoliciesForGroup() policies = %v, want %v", names, want)
+	}
+
+	trust, err := GetTrustPolicy(ctx, IAM{Name: "assume_role", IamType: RoleType, Account: "680235478471"})
+	if err != nil || !strings.Contains(string(trust), "user/basic") {
+		t.Errorf("GetTrustPolicy() = %s, %v, want the role's trust policy", trust, err)
+	}
+
+	var noSuchEntity *types.NoSuchEntityException
+	if _, err := GetRole(ctx, IAM{Name: "missing", Account: "680235478471"}); !errors.As(err, &noSuchEntity) {
+		t.Errorf("GetRole() error = %v, want NoSuchEntity", err)
+	}
+
+	var apiError smithy.APIError
+
+	// assume_role trusts the caller but only allows ec2:Describe* and s3:*
+	t.Setenv("IAM_ROLE_NAME", "assume_role")
+
+	if _, err := GetUserPolicies(ctx, IAM{Name: "basic", Account: "680235478471"}); !errors.As(err, &apiError) || apiError.ErrorCode() != "AccessDenied" {
+		t.Errorf("GetUserPolicies() through a role without iam:ListUserPolicies error = %v, want AccessDenied", err)
+	}
+
+	t.Setenv("IAM_ROLE_NAME", "missing")
+
+	if _, err := GetUserPolicies(ctx, IAM{Name: "basic", Account: "680235478471"}); !errors.As(err, &apiError) || apiError.ErrorCode() != "AccessDenied" {
+		t.Errorf("GetUserPolicies() through a role that does not exist error = %v, want AccessDenied", err)
+	}
+}
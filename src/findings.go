@@ -0,0 +1,318 @@
+package Identity
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Finding levels, named as SARIF names them.
+const (
+	ErrorLevel   = "error"
+	WarningLevel = "warning"
+	NoteLevel    = "note"
+)
+
+// Rule is a check with a stable ID, so that findings can be tracked and suppressed across runs.
+type Rule struct {
+	ID    string `json:"ID"`
+	Name  string `json:"Name"`
+	Level string `json:"Level"`
+	Short string `json:"Short"`
+	Help  string `json:"Help"`
+}
+
+// Finding is a problem a rule found in a policy. Statement is -1 when it concerns the whole policy.
+// File and Line locate the policy or statement when it was loaded from code.
+type Finding struct {
+	RuleID    string `json:"RuleID"`
+	Level     string `json:"Level"`
+	Message   string `json:"Message"`
+	Policy    string `json:"Policy"`
+	Statement int    `json:"Statement"`
+	File      string `json:"File,omitempty"`
+	Line      int    `json:"Line,omitempty"`
+}
+
+// Rules lists every lint and escalation rule, in ID order.
+func Rules() []Rule {
+	rules := append(append([]Rule{}, lintRules...), escalationRules()...)
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules
+}
+
+// RuleByID returns the rule with an ID.
+func RuleByID(id string) (Rule, bool) {
+	for _, rule := range Rules() {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// newFinding records a finding of a rule against a statement of a policy, locating it in code where known.
+func newFinding(rule Rule, policy Policy, statement int, message string) Finding {
+	finding := Finding{
+		RuleID:    rule.ID,
+		Level:     rule.Level,
+		Message:   message,
+		Policy:    policy.Source.String(),
+		Statement: statement,
+		File:      policy.Source.File,
+		Line:      policy.Source.Line,
+	}
+
+	if statement >= 0 && statement < len(policy.Source.Lines) {
+		finding.Line = policy.Source.Lines[statement]
+	}
+
+	return finding
+}
+
+// SortFindings orders findings by file, line, policy, statement and rule.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+
+		switch {
+		case a.File != b.File:
+			return a.File < b.File
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Policy != b.Policy:
+			return a.Policy < b.Policy
+		case a.Statement != b.Statement:
+			return a.Statement < b.Statement
+		}
+
+		return a.RuleID < b.RuleID
+	})
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	Name                 string       `json:"name"`
+	ShortDescription     sarifText    `json:"shortDescription"`
+	FullDescription      sarifText    `json:"fullDescription"`
+	Help                 sarifText    `json:"help"`
+	DefaultConfiguration sarifDefault `json:"defaultConfiguration"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifDefault struct {
+	Level string `json:"level"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysical `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogical `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogical struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes findings as a SARIF 2.1.0 log, for GitHub code scanning and other SARIF viewers.
+// Findings from policies loaded from code point at their file and line. Others name the policy as a
+// logical location and are placed at the top of artifact, such as the snapshot they were read from.
+// With no artifact, as for a live identity, they have only the logical location, which GitHub code
+// scanning does not show, so upload SARIF for files or snapshots rather than live identities.
+func WriteSARIF(w io.Writer, findings []Finding, artifact string) error {
+	rules := Rules()
+	index := map[string]int{}
+	driver := sarifDriver{Name: "identity", InformationURI: "https://github.com/jameswoolfenden/identity", Rules: []sarifRule{}}
+
+	for i, rule := range rules {
+		index[rule.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			Name:                 rule.Name,
+			ShortDescription:     sarifText{rule.Short},
+			FullDescription:      sarifText{rule.Help},
+			Help:                 sarifText{rule.Help},
+			DefaultConfiguration: sarifDefault{rule.Level},
+		})
+	}
+
+	results := []sarifResult{}
+
+	for _, finding := range findings {
+		location := sarifLocation{}
+
+		if finding.File != "" {
+			location.PhysicalLocation = &sarifPhysical{ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(finding.File)}}
+			if finding.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line}
+			}
+		} else {
+			location.LogicalLocations = []sarifLogical{{FullyQualifiedName: findingTarget(finding), Kind: "resource"}}
+
+			if artifact != "" {
+				location.PhysicalLocation = &sarifPhysical{
+					ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(artifact)},
+					Region:           &sarifRegion{StartLine: 1},
+				}
+			}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    finding.RuleID,
+			RuleIndex: index[finding.RuleID],
+			Level:     finding.Level,
+			Message:   sarifText{finding.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes findings as a JUnit XML report with a suite per rule. Each finding is a failed
+// test case, and a rule with no findings is a single passing case, so the report counts every check.
+func WriteJUnit(w io.Writer, findings []Finding) error {
+	report := junitSuites{Name: "identity"}
+
+	byRule := map[string][]Finding{}
+	for _, finding := range findings {
+		byRule[finding.RuleID] = append(byRule[finding.RuleID], finding)
+	}
+
+	for _, rule := range Rules() {
+		suite := junitSuite{Name: rule.ID + " " + rule.Name}
+
+		for _, finding := range byRule[rule.ID] {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      findingTarget(finding),
+				ClassName: rule.ID,
+				File:      filepath.ToSlash(finding.File),
+				Line:      finding.Line,
+				Failure:   &junitFailure{Message: finding.Message, Type: finding.Level, Text: rule.Help},
+			})
+		}
+
+		if len(suite.Cases) == 0 {
+			suite.Cases = []junitCase{{Name: rule.Short, ClassName: rule.ID}}
+		}
+
+		suite.Tests = len(suite.Cases)
+		suite.Failures = len(byRule[rule.ID])
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// findingTarget names what a finding is about: the policy, and the statement when there is one.
+func findingTarget(finding Finding) string {
+	if finding.Statement < 0 {
+		return finding.Policy
+	}
+
+	return fmt.Sprintf("%s[%d]", finding.Policy, finding.Statement)
+}
@@ -0,0 +1,98 @@
+package Identity
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+)
+
+var testFindings = []Finding{
+	{RuleID: AllActionsRule, Level: ErrorLevel, Message: "statement allows every action", Policy: "aws_iam_policy.admin", Statement: 0, File: "infra/main.tf", Line: 7},
+	{RuleID: "ESC004", Level: ErrorLevel, Message: "allows iam:CreateAccessKey", Policy: "group/dev > Admin", Statement: 2},
+}
+
+func TestRulesAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+
+	for _, rule := range Rules() {
+		if seen[rule.ID] {
+			t.Errorf("rule %s is defined more than once", rule.ID)
+		}
+
+		if rule.Name == "" || rule.Help == "" || rule.Short == "" {
+			t.Errorf("rule %s is missing a name, summary or help", rule.ID)
+		}
+
+		seen[rule.ID] = true
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buffer bytes.Buffer
+
+	if err := WriteSARIF(&buffer, testFindings, "snapshots/dev.json"); err != nil {
+		t.Fatalf("WriteSARIF() error = %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buffer.Bytes(), &log); err != nil {
+		t.Fatalf("WriteSARIF() wrote invalid JSON: %v", err)
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("WriteSARIF() results = %d, want 2", len(results))
+	}
+
+	physical := results[0].Locations[0].PhysicalLocation
+	if physical == nil || physical.ArtifactLocation.URI != "infra/main.tf" || physical.Region.StartLine != 7 {
+		t.Errorf("WriteSARIF() location = %+v, want infra/main.tf line 7", physical)
+	}
+
+	if got := results[1].Locations[0].LogicalLocations[0].FullyQualifiedName; got != "group/dev > Admin[2]" {
+		t.Errorf("WriteSARIF() logical location = %s, want group/dev > Admin[2]", got)
+	}
+
+	if snapshot := results[1].Locations[0].PhysicalLocation; snapshot == nil || snapshot.ArtifactLocation.URI != "snapshots/dev.json" {
+		t.Errorf("WriteSARIF() location = %+v, want the snapshot", snapshot)
+	}
+
+	if rule := log.Runs[0].Tool.Driver.Rules[results[1].RuleIndex]; rule.ID != "ESC004" {
+		t.Errorf("WriteSARIF() ruleIndex points at %s, want ESC004", rule.ID)
+	}
+}
+
+func TestWriteSARIF_Live(t *testing.T) {
+	var buffer bytes.Buffer
+
+	if err := WriteSARIF(&buffer, testFindings[1:], ""); err != nil {
+		t.Fatalf("WriteSARIF() error = %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buffer.Bytes(), &log); err != nil {
+		t.Fatalf("WriteSARIF() wrote invalid JSON: %v", err)
+	}
+
+	if location := log.Runs[0].Results[0].Locations[0]; location.PhysicalLocation != nil || len(location.LogicalLocations) != 1 {
+		t.Errorf("WriteSARIF() location = %+v, want only the logical location", location)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buffer bytes.Buffer
+
+	if err := WriteJUnit(&buffer, testFindings); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+
+	var report junitSuites
+	if err := xml.Unmarshal(buffer.Bytes(), &report); err != nil {
+		t.Fatalf("WriteJUnit() wrote invalid XML: %v", err)
+	}
+
+	if report.Failures != 2 || report.Tests != len(Rules()) {
+		t.Errorf("WriteJUnit() tests = %d, failures = %d, want %d and 2", report.Tests, report.Failures, len(Rules()))
+	}
+}
@@ -18,3 +18,8 @@ func GetIAMRoleName() string {
 func FormatRole(user IAM) (role string) {
 	return fmt.Sprintf("arn:aws:iam::%s:role/%s", user.Account, GetIAMRoleName())
 }
+
+// FormatArn returns the IAM ARN of a user, group or role.
+func FormatArn(ident IAM) string {
+	return fmt.Sprintf("arn:aws:iam::%s:%s/%s", ident.Account, ident.IamType, ident.Name)
+}
@@ -12,7 +12,7 @@ func TestFormatRole(t *testing.T) {
 		want string
 	}{
 		{"basic",
-			args{IAM{"identity", "680235478471", "", nil}},
+			args{IAM{"identity", "680235478471", "", nil, nil, nil}},
 			"arn:aws:iam::680235478471:role/identity"},
 	}
 	for _, tt := range tests {
@@ -23,3 +23,21 @@ func TestFormatRole(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatArn(t *testing.T) {
+	tests := []struct {
+		name  string
+		ident IAM
+		want  string
+	}{
+		{"user", IAM{Name: "basic", Account: "680235478471", IamType: UserType}, "arn:aws:iam::680235478471:user/basic"},
+		{"role", IAM{Name: "identity", Account: "680235478471", IamType: RoleType}, "arn:aws:iam::680235478471:role/identity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatArn(tt.ident); got != tt.want {
+				t.Errorf("FormatArn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
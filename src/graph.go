@@ -0,0 +1,389 @@
+package Identity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Graph formats, besides JSONFormat.
+const (
+	DOTFormat     = "dot"
+	MermaidFormat = "mermaid"
+)
+
+// Kinds of graph node that are not an identity type.
+const (
+	PolicyNode    = "policy"
+	PrincipalNode = "principal"
+	ServiceNode   = "service"
+)
+
+// Kinds of graph edge, besides the policy kinds that attach a policy.
+const (
+	MemberEdge  = "member"
+	AssumesEdge = "assumes"
+)
+
+// GraphNode is an identity, a policy or a principal that can assume a role.
+type GraphNode struct {
+	ID    string `json:"Id"`
+	Kind  string `json:"Kind"`
+	Label string `json:"Label"`
+	// PolicyKind is the Kind of the Source of a policy node.
+	PolicyKind string `json:"PolicyKind,omitempty"`
+	Arn        string `json:"Arn,omitempty"`
+	File       string `json:"File,omitempty"`
+	Line       int    `json:"Line,omitempty"`
+}
+
+// GraphEdge links a principal to a group it is in, a policy it has or a role it can assume.
+type GraphEdge struct {
+	From string `json:"From"`
+	To   string `json:"To"`
+	Kind string `json:"Kind"`
+	// Effect is what the policy does with the filtered action: Allow or Deny.
+	Effect string `json:"Effect,omitempty"`
+}
+
+// Graph shows how policies reach identities: through groups, as inline, managed or boundary policies,
+// and, across several identities, which principals can assume each role.
+type Graph struct {
+	Action string      `json:"Action,omitempty"`
+	Nodes  []GraphNode `json:"Nodes"`
+	Edges  []GraphEdge `json:"Edges"`
+
+	nodes map[string]bool
+	edges map[GraphEdge]bool
+}
+
+// NewGraph builds the graph of the identities. With an action, only the policies with a statement
+// naming it are kept, with the effect on each edge; permissions boundaries are always kept, as Deny
+// when they do not allow the action.
+func NewGraph(identities []IAM, action string) (Graph, error) {
+	graph := Graph{Action: action, nodes: map[string]bool{}, edges: map[GraphEdge]bool{}}
+	arns := map[string]string{}
+
+	// Policies loaded from code are only drawn from their directory when no principal in the code has them
+	for _, ident := range identities {
+		if ident.IamType != "" {
+			root := graphRoot(ident)
+			graph.addNode(GraphNode{ID: root, Kind: ident.IamType, Label: root})
+			arns[FormatArn(ident)] = root
+		}
+	}
+
+	for _, ident := range identities {
+		root := graphRoot(ident)
+
+		for i, policy := range ident.Policies {
+			// snapshots written without sources do not name their policies, so they are named by position
+			if policy.Source.Name == "" && policy.Source.Arn == "" {
+				policy.Source.Name = fmt.Sprintf("policy %d", i)
+			}
+
+			effect := ""
+
+			if action != "" {
+				if effect = policyEffect(policy, action); effect == "" {
+					continue
+				}
+			}
+
+			graph.addPolicy(root, policy, effect)
+		}
+
+		if ident.Boundary != nil {
+			effect := ""
+
+			if action != "" {
+				effect = Deny
+				if policyEffect(*ident.Boundary, action) == Allow {
+					effect = Allow
+				}
+			}
+
+			graph.addPolicy(root, *ident.Boundary, effect)
+		}
+	}
+
+	for _, ident := range identities {
+		if ident.IamType != RoleType || len(ident.Trust) == 0 {
+			continue
+		}
+
+		trust, err := Parse(string(ident.Trust))
+		if err != nil {
+			return Graph{}, fmt.Errorf("failed to parse trust policy of %s: %w", ident.Ref(), err)
+		}
+
+		for _, statement := range trust.Statements {
+			if statement.Effect != Allow || !assumesRole(statement) {
+				continue
+			}
+
+			for _, kind := range sortedKeys(statement.Principal) {
+				for _, principal := range statement.Principal[kind] {
+					id, ok := arns[principal]
+					if !ok {
+						id = principal
+						graph.addNode(GraphNode{ID: id, Kind: principalKind(kind), Label: principal})
+					}
+
+					graph.addEdge(GraphEdge{From: id, To: graphRoot(ident), Kind: AssumesEdge})
+				}
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+// Render writes the graph as Graphviz DOT, Mermaid or JSON.
+func (g Graph) Render(format string) (string, error) {
+	switch format {
+	case JSONFormat:
+		return marshalIndent(g)
+	case DOTFormat:
+		return g.dot(), nil
+	case MermaidFormat:
+		return g.mermaid(), nil
+	default:
+		return "", fmt.Errorf("unknown format %s", format)
+	}
+}
+
+// addPolicy adds a policy and the attachment path that brings it to root.
+func (g *Graph) addPolicy(root string, policy Policy, effect string) {
+	path := policy.Source.Via
+	if len(path) == 0 {
+		path = []string{root}
+	}
+
+	for i, step := range path {
+		g.addNode(GraphNode{ID: step, Kind: graphKind(step), Label: step})
+
+		if i > 0 {
+			g.addEdge(GraphEdge{From: path[i-1], To: step, Kind: MemberEdge})
+		}
+	}
+
+	owner := path[len(path)-1]
+
+	id := policy.Source.Arn
+	if id == "" {
+		id = owner + ":" + policy.Source.Name
+	}
+
+	g.addNode(GraphNode{
+		ID:         id,
+		Kind:       PolicyNode,
+		Label:      policy.Source.Name,
+		PolicyKind: policy.Source.Kind,
+		Arn:        policy.Source.Arn,
+		File:       policy.Source.File,
+		Line:       policy.Source.Line,
+	})
+	g.addEdge(GraphEdge{From: owner, To: id, Kind: policy.Source.Kind, Effect: effect})
+}
+
+func (g *Graph) addNode(node GraphNode) {
+	if g.nodes[node.ID] {
+		return
+	}
+
+	g.nodes[node.ID] = true
+	g.Nodes = append(g.Nodes, node)
+}
+
+func (g *Graph) addEdge(edge GraphEdge) {
+	if g.edges[edge] {
+		return
+	}
+
+	g.edges[edge] = true
+	g.Edges = append(g.Edges, edge)
+}
+
+func (g Graph) dot() string {
+	var b strings.Builder
+
+	b.WriteString("digraph identity {\n  rankdir=LR;\n")
+
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%s, shape=%s];\n", strconv.Quote(node.ID), strconv.Quote(node.label("\n")), dotShapes[node.shape()])
+	}
+
+	for _, edge := range g.Edges {
+		attributes := "label=" + strconv.Quote(edge.label())
+		if edge.Effect == Deny {
+			attributes += ", color=red"
+		}
+
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), attributes)
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func (g Graph) mermaid() string {
+	var b strings.Builder
+
+	// Mermaid IDs cannot hold ARNs, so nodes are numbered in order
+	ids := map[string]string{}
+
+	b.WriteString("flowchart LR\n")
+
+	for i, node := range g.Nodes {
+		ids[node.ID] = "n" + strconv.Itoa(i)
+		shape := mermaidShapes[node.shape()]
+
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", ids[node.ID], shape[0], mermaidEscape(node.label("<br/>")), shape[1])
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[edge.From], mermaidEscape(edge.label()), ids[edge.To])
+	}
+
+	return b.String()
+}
+
+// label is the node's name, with the policy kind and the file and line of policies from code.
+func (n GraphNode) label(newline string) string {
+	if n.Kind != PolicyNode {
+		return n.Label
+	}
+
+	label := n.Label + newline + "(" + n.PolicyKind + ")"
+	if n.File != "" {
+		label += newline + n.File + ":" + strconv.Itoa(n.Line)
+	}
+
+	return label
+}
+
+func (n GraphNode) shape() string {
+	if n.Kind == PolicyNode && n.PolicyKind == BoundaryKind {
+		return BoundaryKind
+	}
+
+	return n.Kind
+}
+
+func (e GraphEdge) label() string {
+	if e.Effect == "" {
+		return e.Kind
+	}
+
+	return e.Kind + " " + e.Effect
+}
+
+var dotShapes = map[string]string{
+	UserType:      "box",
+	GroupType:     "folder",
+	RoleType:      "box3d",
+	PolicyNode:    "note",
+	BoundaryKind:  "octagon",
+	PrincipalNode: "ellipse",
+	ServiceNode:   "ellipse",
+	"":            "box",
+}
+
+var mermaidShapes = map[string][2]string{
+	UserType:      {"[", "]"},
+	GroupType:     {"[[", "]]"},
+	RoleType:      {"[/", "/]"},
+	PolicyNode:    {"(", ")"},
+	BoundaryKind:  {"{{", "}}"},
+	PrincipalNode: {"([", "])"},
+	ServiceNode:   {"([", "])"},
+	"":            {"[", "]"},
+}
+
+func mermaidEscape(value string) string {
+	return strings.ReplaceAll(value, `"`, "#quot;")
+}
+
+// graphRoot returns the node ID of an identity: its reference, or its name when it has no type.
+func graphRoot(ident IAM) string {
+	if ident.IamType == "" {
+		return ident.Name
+	}
+
+	return ident.Ref()
+}
+
+// graphKind returns the identity type of a reference such as group/devs, or empty for other names.
+func graphKind(ref string) string {
+	for _, kind := range []string{UserType, GroupType, RoleType} {
+		if strings.HasPrefix(ref, kind+"/") {
+			return kind
+		}
+	}
+
+	return ""
+}
+
+func principalKind(kind string) string {
+	if kind == "Service" {
+		return ServiceNode
+	}
+
+	return PrincipalNode
+}
+
+// assumesRole reports whether a trust policy statement allows one of the sts:AssumeRole actions.
+func assumesRole(statement Statement) bool {
+	for _, pattern := range statement.Action {
+		for _, action := range []string{"sts:AssumeRole", "sts:AssumeRoleWithSAML", "sts:AssumeRoleWithWebIdentity"} {
+			if MatchAction(pattern, action) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// policyEffect returns Deny when a statement of the policy denies the action, Allow when one allows
+// it, and empty when none names it.
+func policyEffect(policy Policy, action string) string {
+	effect := ""
+
+	for _, statement := range policy.Statements {
+		if !statementNames(statement, action) {
+			continue
+		}
+
+		if statement.Effect == Deny {
+			return Deny
+		}
+
+		effect = Allow
+	}
+
+	return effect
+}
+
+// statementNames reports whether a statement's Action, or NotAction, covers an action.
+func statementNames(statement Statement, action string) bool {
+	for _, pattern := range statement.Action {
+		if MatchAction(pattern, action) {
+			return true
+		}
+	}
+
+	if len(statement.NotAction) == 0 {
+		return false
+	}
+
+	for _, pattern := range statement.NotAction {
+		if MatchAction(pattern, action) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,261 @@
+package Identity
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func graphIdentities(t *testing.T) []IAM {
+	t.Helper()
+
+	policy := func(document string, source Source) Policy {
+		parsed, err := Parse(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed.Source = source
+
+		return parsed
+	}
+
+	shared := "arn:aws:iam::680235478471:policy/shared"
+	boundary := policy(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+		Source{Name: "limit", Arn: "arn:aws:iam::680235478471:policy/limit", Kind: BoundaryKind, Via: []string{"user/basic"}})
+
+	user := IAM{
+		Name:    "basic",
+		Account: "680235478471",
+		IamType: UserType,
+		Policies: []Policy{
+			policy(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+				Source{Name: "read", Kind: InlineKind, Via: []string{"user/basic"}}),
+			policy(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"ec2:Describe*","Resource":"*"}]}`,
+				Source{Name: "shared", Arn: shared, Kind: ManagedKind, Via: []string{"user/basic", "group/devs"}}),
+			policy(`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","NotAction":"ec2:*","Resource":"*"}]}`,
+				Source{Name: "ec2-only", Kind: InlineKind, Via: []string{"user/basic", "group/devs"}}),
+		},
+		Boundary: &boundary,
+	}
+
+	role := IAM{
+		Name:    "deploy",
+		Account: "680235478471",
+		IamType: RoleType,
+		Policies: []Policy{
+			policy(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"ec2:Describe*","Resource":"*"}]}`,
+				Source{Name: "shared", Arn: shared, Kind: ManagedKind, Via: []string{"role/deploy"}}),
+		},
+		Trust: json.RawMessage(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws:iam::680235478471:user/basic","Service":"ec2.amazonaws.com"}}]}`),
+	}
+
+	return []IAM{user, role}
+}
+
+func TestNewGraph(t *testing.T) {
+	identities := graphIdentities(t)
+
+	tests := []struct {
+		name   string
+		action string
+		nodes  []string
+		edges  []string
+	}{
+		{
+			name: "all",
+			nodes: []string{
+				"user/basic", "role/deploy", "user/basic:read", "group/devs", "arn:aws:iam::680235478471:policy/shared",
+				"group/devs:ec2-only", "arn:aws:iam::680235478471:policy/limit", "ec2.amazonaws.com",
+			},
+			edges: []string{
+				"user/basic -inline-> user/basic:read",
+				"user/basic -member-> group/devs",
+				"group/devs -managed-> arn:aws:iam::680235478471:policy/shared",
+				"group/devs -inline-> group/devs:ec2-only",
+				"user/basic -boundary-> arn:aws:iam::680235478471:policy/limit",
+				"role/deploy -managed-> arn:aws:iam::680235478471:policy/shared",
+				"user/basic -assumes-> role/deploy",
+				"ec2.amazonaws.com -assumes-> role/deploy",
+			},
+		},
+		{
+			name:   "s3_action",
+			action: "s3:GetObject",
+			nodes: []string{
+				"user/basic", "role/deploy", "user/basic:read", "group/devs", "group/devs:ec2-only",
+				"arn:aws:iam::680235478471:policy/limit", "ec2.amazonaws.com",
+			},
+			edges: []string{
+				"user/basic -inline Allow-> user/basic:read",
+				"user/basic -member-> group/devs",
+				"group/devs -inline Deny-> group/devs:ec2-only",
+				"user/basic -boundary Allow-> arn:aws:iam::680235478471:policy/limit",
+				"user/basic -assumes-> role/deploy",
+				"ec2.amazonaws.com -assumes-> role/deploy",
+			},
+		},
+		{
+			name:   "ec2_action",
+			action: "ec2:DescribeInstances",
+			nodes: []string{
+				"user/basic", "role/deploy", "group/devs", "arn:aws:iam::680235478471:policy/shared",
+				"arn:aws:iam::680235478471:policy/limit", "ec2.amazonaws.com",
+			},
+			edges: []string{
+				"user/basic -member-> group/devs",
+				"group/devs -managed Allow-> arn:aws:iam::680235478471:policy/shared",
+				"user/basic -boundary Deny-> arn:aws:iam::680235478471:policy/limit",
+				"role/deploy -managed Allow-> arn:aws:iam::680235478471:policy/shared",
+				"user/basic -assumes-> role/deploy",
+				"ec2.amazonaws.com -assumes-> role/deploy",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := NewGraph(identities, tt.action)
+			if err != nil {
+				t.Fatalf("NewGraph() error = %v", err)
+			}
+
+			var nodes, edges []string
+
+			for _, node := range graph.Nodes {
+				nodes = append(nodes, node.ID)
+			}
+
+			for _, edge := range graph.Edges {
+				edges = append(edges, edge.From+" -"+edge.label()+"-> "+edge.To)
+			}
+
+			if !reflect.DeepEqual(nodes, tt.nodes) {
+				t.Errorf("NewGraph() nodes = %q, want %q", nodes, tt.nodes)
+			}
+
+			if !reflect.DeepEqual(edges, tt.edges) {
+				t.Errorf("NewGraph() edges = %q, want %q", edges, tt.edges)
+			}
+		})
+	}
+}
+
+func TestNewGraph_Code(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "template.yaml")
+
+	if err := os.WriteFile(path, []byte(cloudFormationFixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policies, err := LoadPolicies(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := NewGraph([]IAM{{Name: dir, Policies: policies}}, "")
+	if err != nil {
+		t.Fatalf("NewGraph() error = %v", err)
+	}
+
+	want := []GraphNode{
+		{ID: dir, Label: dir},
+		{ID: dir + ":Reader", Kind: PolicyNode, Label: "Reader", PolicyKind: ManagedKind, File: path, Line: 5},
+		{ID: "role/App", Kind: RoleType, Label: "role/App"},
+		{ID: "role/App:App.logs", Kind: PolicyNode, Label: "App.logs", PolicyKind: InlineKind, File: path, Line: 28},
+	}
+
+	if !reflect.DeepEqual(graph.Nodes, want) {
+		t.Errorf("NewGraph() nodes = %+v, want %+v", graph.Nodes, want)
+	}
+}
+
+func TestNewGraph_Unnamed(t *testing.T) {
+	document := Policy{Version: "2012-10-17", Statements: []Statement{{Effect: Allow, Action: []string{"s3:GetObject"}, Resource: []string{"*"}}}}
+
+	graph, err := NewGraph([]IAM{{Name: "bob", IamType: UserType, Policies: []Policy{document, document}}}, "")
+	if err != nil {
+		t.Fatalf("NewGraph() error = %v", err)
+	}
+
+	want := []GraphNode{
+		{ID: "user/bob", Kind: UserType, Label: "user/bob"},
+		{ID: "user/bob:policy 0", Kind: PolicyNode, Label: "policy 0"},
+		{ID: "user/bob:policy 1", Kind: PolicyNode, Label: "policy 1"},
+	}
+
+	if !reflect.DeepEqual(graph.Nodes, want) {
+		t.Errorf("NewGraph() nodes = %+v, want %+v", graph.Nodes, want)
+	}
+}
+
+func TestGraph_Render(t *testing.T) {
+	policy, err := Parse(`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":"s3:*","Resource":"*"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy.Source = Source{Name: `say "no"`, Kind: InlineKind, Via: []string{"role/app"}, File: "main.tf", Line: 12}
+
+	graph, err := NewGraph([]IAM{{Name: "app", IamType: RoleType, Account: "680235478471", Policies: []Policy{policy}}}, "s3:GetObject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{
+			format: DOTFormat,
+			want: `digraph identity {
+  rankdir=LR;
+  "role/app" [label="role/app", shape=box3d];
+  "role/app:say \"no\"" [label="say \"no\"\n(inline)\nmain.tf:12", shape=note];
+  "role/app" -> "role/app:say \"no\"" [label="inline Deny", color=red];
+}
+`,
+		},
+		{
+			format: MermaidFormat,
+			want: `flowchart LR
+  n0[/"role/app"/]
+  n1("say #quot;no#quot;<br/>(inline)<br/>main.tf:12")
+  n0 -->|inline Deny| n1
+`,
+		},
+		{format: "png", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := graph.Render(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Render() = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	rendered, err := graph.Render(JSONFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Graph
+	if err := json.Unmarshal([]byte(rendered), &decoded); err != nil {
+		t.Fatalf("Render(json) is not JSON: %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded.Nodes, graph.Nodes) || !reflect.DeepEqual(decoded.Edges, graph.Edges) || !strings.Contains(rendered, `"Action": "s3:GetObject"`) {
+		t.Errorf("Render(json) = %s", rendered)
+	}
+}
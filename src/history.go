@@ -0,0 +1,178 @@
+package Identity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// DefaultVersion selects the default version of a managed policy in FindPolicyVersion.
+const DefaultVersion = "default"
+
+// PolicyVersion is one version of a managed policy.
+type PolicyVersion struct {
+	VersionID string    `json:"VersionId"`
+	Created   time.Time `json:"Created"`
+	Default   bool      `json:"Default"`
+}
+
+// PolicyVersionDiff compares the effective permissions of two versions of a managed policy.
+type PolicyVersionDiff struct {
+	Arn  string         `json:"Arn"`
+	From PolicyVersion  `json:"From"`
+	To   PolicyVersion  `json:"To"`
+	Diff PermissionDiff `json:"Diff"`
+}
+
+// GetPolicyVersions lists the versions of a managed policy, newest first.
+func GetPolicyVersions(ctx context.Context, arn string, account IAM) ([]PolicyVersion, error) {
+	svc, err := NewIAMClient(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []PolicyVersion
+
+	paginator := iam.NewListPolicyVersionsPaginator(svc, &iam.ListPolicyVersionsInput{PolicyArn: aws.String(arn)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list policy versions: %w", apiError(err, account, "iam:ListPolicyVersions", arn))
+		}
+
+		for _, version := range page.Versions {
+			versions = append(versions, PolicyVersion{
+				VersionID: aws.ToString(version.VersionId),
+				Created:   aws.ToTime(version.CreateDate),
+				Default:   version.IsDefaultVersion,
+			})
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].Created.Equal(versions[j].Created) {
+			return versions[i].Created.After(versions[j].Created)
+		}
+
+		return versionNumber(versions[i].VersionID) > versionNumber(versions[j].VersionID)
+	})
+
+	return versions, nil
+}
+
+// GetPolicyVersion returns the document of one version of a managed policy, from the policy cache when it holds that version.
+func GetPolicyVersion(ctx context.Context, arn string, versionID string, account IAM) (*string, error) {
+	svc, err := NewIAMClient(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := currentPolicyCache()
+
+	// the policy ID keeps a policy created again under the same name from reading the old one's versions
+	lookup, err := lookupPolicy(ctx, svc, cache, arn, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPolicyVersion(ctx, svc, cache, arn, lookup.policyID, versionID, account)
+}
+
+// FindPolicyVersion picks a version from versions by its ID, DefaultVersion, or a time. For a time, RFC 3339
+// or a date, it is the newest version created at or before it, a date counting to its end in UTC. That is the
+// version that was current then, unless the default was later set back to an older version.
+func FindPolicyVersion(versions []PolicyVersion, ref string) (PolicyVersion, error) {
+	for _, version := range versions {
+		if strings.EqualFold(version.VersionID, ref) || (ref == DefaultVersion && version.Default) {
+			return version, nil
+		}
+	}
+
+	at, err := time.Parse(time.RFC3339, ref)
+	if err != nil {
+		day, dayErr := time.Parse(time.DateOnly, ref)
+		if dayErr != nil {
+			return PolicyVersion{}, fmt.Errorf("no policy version %s", ref)
+		}
+
+		at = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	var found *PolicyVersion
+
+	for i := range versions {
+		if !versions[i].Created.After(at) && (found == nil || versions[i].Created.After(found.Created)) {
+			found = &versions[i]
+		}
+	}
+
+	if found == nil {
+		return PolicyVersion{}, fmt.Errorf("no policy version was created by %s", ref)
+	}
+
+	return *found, nil
+}
+
+// DiffPolicyVersions compares the effective permissions two versions of a managed policy grant, each picked
+// as FindPolicyVersion does.
+func DiffPolicyVersions(ctx context.Context, arn string, from string, to string, account IAM) (PolicyVersionDiff, error) {
+	versions, err := GetPolicyVersions(ctx, arn, account)
+	if err != nil {
+		return PolicyVersionDiff{}, err
+	}
+
+	result := PolicyVersionDiff{Arn: arn}
+
+	if result.From, err = FindPolicyVersion(versions, from); err != nil {
+		return PolicyVersionDiff{}, err
+	}
+
+	if result.To, err = FindPolicyVersion(versions, to); err != nil {
+		return PolicyVersionDiff{}, err
+	}
+
+	before, err := policyVersionIdentity(ctx, arn, result.From, account)
+	if err != nil {
+		return PolicyVersionDiff{}, err
+	}
+
+	after, err := policyVersionIdentity(ctx, arn, result.To, account)
+	if err != nil {
+		return PolicyVersionDiff{}, err
+	}
+
+	result.Diff = Diff(before, after)
+
+	return result, nil
+}
+
+// policyVersionIdentity returns an identity granted only the version of the policy.
+func policyVersionIdentity(ctx context.Context, arn string, version PolicyVersion, account IAM) (IAM, error) {
+	document, err := GetPolicyVersion(ctx, arn, version.VersionID, account)
+	if err != nil {
+		return IAM{}, err
+	}
+
+	policy, err := Parse(*document)
+	if err != nil {
+		return IAM{}, fmt.Errorf("failed to parse policy version %s: %w", version.VersionID, err)
+	}
+
+	name := arn[strings.LastIndex(arn, "/")+1:]
+	policy.Source = Source{Name: name + "@" + version.VersionID, Arn: arn, Kind: ManagedKind}
+
+	return IAM{Name: name, Account: account.Account, Policies: []Policy{policy}}, nil
+}
+
+// versionNumber returns the number of a version ID such as v12, or zero.
+func versionNumber(versionID string) int {
+	number, _ := strconv.Atoi(strings.TrimPrefix(strings.ToLower(versionID), "v"))
+
+	return number
+}
@@ -0,0 +1,136 @@
+package Identity
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPolicyVersions(t *testing.T) {
+	useFakeAWS(t, "account.json")
+
+	ctx := context.Background()
+	account := IAM{Account: "680235478471"}
+
+	versions, err := GetPolicyVersions(ctx, "arn:aws:iam::680235478471:policy/deploy", account)
+	if err != nil {
+		t.Fatalf("GetPolicyVersions() error = %v", err)
+	}
+
+	want := []PolicyVersion{
+		{VersionID: "v3", Created: time.Date(2026, 10, 13, 16, 0, 0, 0, time.UTC), Default: true},
+		{VersionID: "v2", Created: time.Date(2026, 10, 6, 14, 30, 0, 0, time.UTC)},
+		{VersionID: "v1", Created: time.Date(2026, 9, 1, 9, 0, 0, 0, time.UTC)},
+	}
+
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("GetPolicyVersions() = %+v, want %+v", versions, want)
+	}
+
+	_, err = GetPolicyVersions(ctx, "arn:aws:iam::680235478471:policy/nothing", account)
+	if !errors.Is(err, ErrNoSuchEntity) {
+		t.Errorf("GetPolicyVersions() of a missing policy error = %v, want ErrNoSuchEntity", err)
+	}
+}
+
+func TestFindPolicyVersion(t *testing.T) {
+	versions := []PolicyVersion{
+		{VersionID: "v3", Created: time.Date(2026, 10, 13, 16, 0, 0, 0, time.UTC)},
+		{VersionID: "v2", Created: time.Date(2026, 10, 6, 14, 30, 0, 0, time.UTC), Default: true},
+		{VersionID: "v1", Created: time.Date(2026, 9, 1, 9, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"version_id", "v1", "v1", false},
+		{"version_id_case", "V3", "v3", false},
+		{"default", DefaultVersion, "v2", false},
+		{"day_of_change", "2026-10-06", "v2", false},
+		{"day_before_change", "2026-10-05", "v1", false},
+		{"time_before_change", "2026-10-06T14:00:00Z", "v1", false},
+		{"time_of_change", "2026-10-06T14:30:00Z", "v2", false},
+		{"after_last", "2027-01-01", "v3", false},
+		{"before_first", "2026-08-31", "", true},
+		{"unknown_version", "v9", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindPolicyVersion(versions, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindPolicyVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got.VersionID != tt.want {
+				t.Errorf("FindPolicyVersion() = %s, want %s", got.VersionID, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffPolicyVersions(t *testing.T) {
+	useFakeAWS(t, "account.json")
+
+	ctx := context.Background()
+	account := IAM{Account: "680235478471"}
+	arn := "arn:aws:iam::680235478471:policy/deploy"
+
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		versions [2]string
+		gained   []string
+		lost     []string
+		changed  []string
+	}{
+		{"by_id", "v1", "v2", [2]string{"v1", "v2"}, []string{"iam:PassRole"}, nil, nil},
+		{"last_tuesday", "2026-10-12", "2026-10-13", [2]string{"v2", "v3"}, nil, []string{"s3:PutObject"}, []string{"iam:PassRole"}},
+		{"to_default", "v1", DefaultVersion, [2]string{"v1", "v3"}, []string{"iam:PassRole"}, []string{"s3:PutObject"}, nil},
+		{"same_version", "v2", "v2", [2]string{"v2", "v2"}, nil, nil, nil},
+	}
+
+	actions := func(changes []ActionChange) []string {
+		var names []string
+		for _, change := range changes {
+			names = append(names, change.Action)
+		}
+
+		return names
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffPolicyVersions(ctx, arn, tt.from, tt.to, account)
+			if err != nil {
+				t.Fatalf("DiffPolicyVersions() error = %v", err)
+			}
+
+			if versions := [2]string{got.From.VersionID, got.To.VersionID}; versions != tt.versions {
+				t.Errorf("DiffPolicyVersions() compared %v, want %v", versions, tt.versions)
+			}
+
+			if !reflect.DeepEqual(actions(got.Diff.Gained), tt.gained) {
+				t.Errorf("Gained = %v, want %v", actions(got.Diff.Gained), tt.gained)
+			}
+
+			if !reflect.DeepEqual(actions(got.Diff.Lost), tt.lost) {
+				t.Errorf("Lost = %v, want %v", actions(got.Diff.Lost), tt.lost)
+			}
+
+			if !reflect.DeepEqual(actions(got.Diff.Changed), tt.changed) {
+				t.Errorf("Changed = %v, want %v", actions(got.Diff.Changed), tt.changed)
+			}
+		})
+	}
+
+	if _, err := DiffPolicyVersions(ctx, arn, "v1", "v9", account); err == nil {
+		t.Errorf("DiffPolicyVersions() to a missing version error = nil")
+	}
+}
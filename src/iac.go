@@ -0,0 +1,825 @@
+package Identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// terraformPolicyResources maps Terraform resources that carry a permission policy to the kind of policy.
+var terraformPolicyResources = map[string]string{
+	"aws_iam_policy":       ManagedKind,
+	"aws_iam_role_policy":  InlineKind,
+	"aws_iam_user_policy":  InlineKind,
+	"aws_iam_group_policy": InlineKind,
+}
+
+// cloudFormationPrincipals maps CloudFormation principal types to the identity type their inline policies attach to.
+var cloudFormationPrincipals = map[string]string{
+	"AWS::IAM::Role":  RoleType,
+	"AWS::IAM::User":  UserType,
+	"AWS::IAM::Group": GroupType,
+}
+
+// skippedDirectories are not searched for infrastructure code.
+var skippedDirectories = map[string]bool{
+	".git":         true,
+	".terraform":   true,
+	"node_modules": true,
+}
+
+// LoadError is a problem with one file found while loading infrastructure code. The file is skipped,
+// or, where the message says so, loaded in part; the rest of the walk carries on.
+type LoadError struct {
+	File string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadErrors is every problem found while loading infrastructure code, in walk order.
+type LoadErrors []*LoadError
+
+func (e LoadErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap lets errors.As find each *LoadError.
+func (e LoadErrors) Unwrap() []error {
+	unwrapped := make([]error, 0, len(e))
+	for _, err := range e {
+		unwrapped = append(unwrapped, err)
+	}
+
+	return unwrapped
+}
+
+// LoadPolicies loads the permission policies defined in infrastructure code: Terraform (.tf),
+// Terraform state, CloudFormation JSON or YAML templates, and synthesised CDK output. Directories
+// are searched. Trust and resource policies, which name a principal, are not loaded. Each policy
+// records its file and line, and the line of each of its statements where known. A file that
+// cannot be loaded does not stop the search: the policies of the other files are returned with
+// LoadErrors, which callers can report as warnings.
+func LoadPolicies(path string) ([]Policy, error) {
+	var policies []Policy
+	var problems LoadErrors
+
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if file != path && skippedDirectories[entry.Name()] {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		var found []Policy
+		var partial []error
+
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".tf":
+			found, partial, err = loadTerraform(file)
+		case ".tfstate", ".json", ".yaml", ".yml", ".template":
+			found, err = loadStructured(file)
+		default:
+			return nil
+		}
+
+		if err != nil {
+			problems = append(problems, &LoadError{File: file, Err: err})
+
+			return nil
+		}
+
+		for _, problem := range partial {
+			problems = append(problems, &LoadError{File: file, Err: problem})
+		}
+
+		policies = append(policies, found...)
+
+		return nil
+	})
+	if err != nil {
+		return policies, fmt.Errorf("failed to search %s: %w", path, err)
+	}
+
+	if len(problems) > 0 {
+		return policies, problems
+	}
+
+	return policies, nil
+}
+
+// loadTerraform loads the policies in a Terraform file, with the problems that left a policy only
+// partly loaded.
+func loadTerraform(path string) ([]Policy, []error, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	var policies []Policy
+	var partial []error
+
+	for _, block := range body.Blocks {
+		if len(block.Labels) != 2 {
+			continue
+		}
+
+		address := block.Labels[0] + "." + block.Labels[1]
+
+		switch {
+		case block.Type == "data" && block.Labels[0] == "aws_iam_policy_document":
+			policy, ok, err := terraformDocument(block, src)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", address, err)
+			}
+
+			for _, name := range []string{"source_policy_documents", "override_policy_documents"} {
+				if _, found := block.Body.Attributes[name]; found && ok {
+					partial = append(partial, fmt.Errorf("data.%s: %s is not supported, so only its own statements are loaded", address, name))
+				}
+			}
+
+			if ok {
+				policy.Source = Source{Name: "data." + address, Kind: DocumentKind, File: path, Line: block.DefRange().Start.Line, Lines: policy.Source.Lines}
+				policies = append(policies, policy)
+			}
+		case block.Type == "resource" && terraformPolicyResources[block.Labels[0]] != "":
+			policy, ok, err := terraformPolicyAttribute(block.Body.Attributes["policy"], src)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", address, err)
+			}
+
+			if ok {
+				policy.Source.Name = address
+				policy.Source.Kind = terraformPolicyResources[block.Labels[0]]
+				policy.Source.File = path
+				policies = append(policies, policy)
+			}
+		case block.Type == "resource" && block.Labels[0] == "aws_iam_role":
+			for _, inline := range block.Body.Blocks {
+				if inline.Type != "inline_policy" {
+					continue
+				}
+
+				policy, ok, err := terraformPolicyAttribute(inline.Body.Attributes["policy"], src)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %w", address, err)
+				}
+
+				if ok {
+					policy.Source.Name = address + "." + literalString(inline.Body.Attributes["name"], src)
+					policy.Source.Kind = InlineKind
+					policy.Source.File = path
+					policies = append(policies, policy)
+				}
+			}
+		}
+	}
+
+	return policies, partial, nil
+}
+
+// terraformDocumentFields maps the list attributes of an aws_iam_policy_document statement to policy fields.
+var terraformDocumentFields = map[string]string{
+	"sid":           SidField,
+	"effect":        EffectField,
+	"actions":       ActionField,
+	"not_actions":   NotActionField,
+	"resources":     ResourceField,
+	"not_resources": NotResourceField,
+}
+
+// terraformPrincipalBlocks maps the principal blocks of an aws_iam_policy_document statement to policy fields.
+var terraformPrincipalBlocks = map[string]string{
+	"principals":     PrincipalField,
+	"not_principals": NotPrincipalField,
+}
+
+// terraformDocument converts an aws_iam_policy_document block into a policy. Documents that name a
+// principal are trust or resource policies and are skipped.
+func terraformDocument(block *hclsyntax.Block, src []byte) (Policy, bool, error) {
+	var statements []interface{}
+	var lines []int
+
+	for _, child := range block.Body.Blocks {
+		if child.Type != "statement" {
+			continue
+		}
+
+		statements = append(statements, terraformStatement(child, src))
+		lines = append(lines, child.DefRange().Start.Line)
+	}
+
+	document := map[string]interface{}{VersionField: "2012-10-17", StatementField: statements}
+	if namesPrincipal(document) {
+		return Policy{}, false, nil
+	}
+
+	policy, err := parseDocument(document)
+	policy.Source.Lines = lines
+
+	// the data source renders a list of one value as a string, whatever the block wrote
+	for i := range policy.Statements {
+		policy.Statements[i].lists = nil
+	}
+
+	return policy, err == nil, err
+}
+
+// terraformStatement converts a statement block of an aws_iam_policy_document into the statement it encodes to.
+func terraformStatement(block *hclsyntax.Block, src []byte) map[string]interface{} {
+	statement := map[string]interface{}{EffectField: Allow}
+
+	for name, field := range terraformDocumentFields {
+		if attribute, ok := block.Body.Attributes[name]; ok {
+			statement[field] = hclExprValue(attribute.Expr, src)
+		}
+	}
+
+	principals := map[string]map[string]interface{}{PrincipalField: {}, NotPrincipalField: {}}
+	condition := map[string]interface{}{}
+
+	for _, nested := range block.Body.Blocks {
+		if field := terraformPrincipalBlocks[nested.Type]; field != "" {
+			kind := literalString(nested.Body.Attributes["type"], src)
+			if identifiers, ok := nested.Body.Attributes["identifiers"]; ok {
+				principals[field][kind] = appendValues(principals[field][kind], hclExprValue(identifiers.Expr, src))
+			}
+
+			continue
+		}
+
+		if nested.Type != "condition" {
+			continue
+		}
+
+		test := literalString(nested.Body.Attributes["test"], src)
+		variable := literalString(nested.Body.Attributes["variable"], src)
+
+		keys, ok := condition[test].(map[string]interface{})
+		if !ok {
+			keys = map[string]interface{}{}
+			condition[test] = keys
+		}
+
+		if values, ok := nested.Body.Attributes["values"]; ok {
+			keys[variable] = hclExprValue(values.Expr, src)
+		}
+	}
+
+	if len(condition) > 0 {
+		statement[ConditionField] = condition
+	}
+
+	for field, principal := range principals {
+		if len(principal) > 0 {
+			statement[field] = principal
+		}
+	}
+
+	return statement
+}
+
+// appendValues adds a value, or the items of a list value, to a list.
+func appendValues(list interface{}, value interface{}) []interface{} {
+	values, _ := list.([]interface{})
+
+	if items, ok := value.([]interface{}); ok {
+		return append(values, items...)
+	}
+
+	return append(values, value)
+}
+
+// terraformPolicyAttribute converts a policy attribute written with jsonencode or as a JSON string.
+// Policies that refer elsewhere, such as to a data source, are skipped.
+func terraformPolicyAttribute(attribute *hclsyntax.Attribute, src []byte) (Policy, bool, error) {
+	if attribute == nil {
+		return Policy{}, false, nil
+	}
+
+	var document interface{}
+	var lines []int
+
+	switch expr := attribute.Expr.(type) {
+	case *hclsyntax.FunctionCallExpr:
+		if expr.Name != "jsonencode" || len(expr.Args) != 1 {
+			return Policy{}, false, nil
+		}
+
+		document = hclExprValue(expr.Args[0], src)
+		lines = hclStatementLines(expr.Args[0], src)
+	case *hclsyntax.TemplateExpr:
+		text, ok := hclExprValue(expr, src).(string)
+		if !ok || json.Unmarshal([]byte(text), &document) != nil {
+			return Policy{}, false, nil
+		}
+	default:
+		return Policy{}, false, nil
+	}
+
+	if namesPrincipal(document) {
+		return Policy{}, false, nil
+	}
+
+	policy, err := parseDocument(document)
+	policy.Source.Line = attribute.SrcRange.Start.Line
+	policy.Source.Lines = lines
+
+	return policy, err == nil, err
+}
+
+// hclStatementLines returns the line of each statement in a jsonencode policy.
+func hclStatementLines(expr hclsyntax.Expression, src []byte) []int {
+	object, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil
+	}
+
+	for _, item := range object.Items {
+		if hclKey(item.KeyExpr, src) != StatementField {
+			continue
+		}
+
+		if tuple, ok := item.ValueExpr.(*hclsyntax.TupleConsExpr); ok {
+			var lines []int
+			for _, statement := range tuple.Exprs {
+				lines = append(lines, statement.Range().Start.Line)
+			}
+
+			return lines
+		}
+
+		return []int{item.ValueExpr.Range().Start.Line}
+	}
+
+	return nil
+}
+
+// hclExprValue converts an HCL expression to the value it would encode to; anything that
+// needs evaluating, such as a reference, is kept as its interpolation "${...}".
+func hclExprValue(expr hclsyntax.Expression, src []byte) interface{} {
+	return hclValueAt(expr, src, "", nil)
+}
+
+// hclValueAt converts an HCL expression as hclExprValue does and, when offsets is not nil, records
+// the byte offset of each value under its JSON path.
+func hclValueAt(expr hclsyntax.Expression, src []byte, path string, offsets map[string]int64) interface{} {
+	if offsets != nil {
+		offsets[path] = int64(expr.Range().Start.Byte)
+	}
+
+	switch typed := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		object := map[string]interface{}{}
+		for _, item := range typed.Items {
+			key := hclKey(item.KeyExpr, src)
+			object[key] = hclValueAt(item.ValueExpr, src, joinPath(path, key), offsets)
+		}
+
+		return object
+	case *hclsyntax.TupleConsExpr:
+		items := make([]interface{}, 0, len(typed.Exprs))
+		for i, item := range typed.Exprs {
+			items = append(items, hclValueAt(item, src, fmt.Sprintf("%s[%d]", path, i), offsets))
+		}
+
+		return items
+	case *hclsyntax.TemplateExpr:
+		var builder strings.Builder
+
+		for _, part := range typed.Parts {
+			if literal, ok := part.(*hclsyntax.LiteralValueExpr); ok && literal.Val.Type() == cty.String {
+				builder.WriteString(literal.Val.AsString())
+			} else {
+				builder.WriteString("${" + string(part.Range().SliceBytes(src)) + "}")
+			}
+		}
+
+		return builder.String()
+	case *hclsyntax.LiteralValueExpr:
+		switch {
+		case typed.Val.IsNull():
+			return nil
+		case typed.Val.Type() == cty.String:
+			return typed.Val.AsString()
+		case typed.Val.Type() == cty.Bool:
+			return typed.Val.True()
+		case typed.Val.Type() == cty.Number:
+			number, _ := typed.Val.AsBigFloat().Float64()
+
+			return number
+		}
+	case *hclsyntax.TemplateWrapExpr:
+		return hclValueAt(typed.Wrapped, src, path, offsets)
+	}
+
+	return "${" + string(expr.Range().SliceBytes(src)) + "}"
+}
+
+func hclKey(expr hclsyntax.Expression, src []byte) string {
+	if keyword := hcl.ExprAsKeyword(expr); keyword != "" {
+		return keyword
+	}
+
+	if key, ok := expr.(*hclsyntax.ObjectConsKeyExpr); ok {
+		expr = key.Wrapped
+	}
+
+	return fmt.Sprint(hclExprValue(expr, src))
+}
+
+func literalString(attribute *hclsyntax.Attribute, src []byte) string {
+	if attribute == nil {
+		return ""
+	}
+
+	return fmt.Sprint(hclExprValue(attribute.Expr, src))
+}
+
+// loadStructured loads policies from a JSON or YAML file that is a CloudFormation template or Terraform state.
+// Other JSON and YAML files are ignored.
+func loadStructured(path string) ([]Policy, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+
+	if err := yaml.Unmarshal(src, &root); err != nil {
+		return nil, err
+	}
+
+	if err := checkYAMLAliases(&root); err != nil {
+		return nil, err
+	}
+
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	document := root.Content[0]
+
+	switch {
+	case mappingValue(document, "Resources") != nil:
+		return loadCloudFormation(path, mappingValue(document, "Resources"))
+	case mappingValue(document, "resources") != nil && mappingValue(document, "terraform_version") != nil:
+		return loadState(path, mappingValue(document, "resources"))
+	}
+
+	return nil, nil
+}
+
+func loadCloudFormation(path string, resources *yaml.Node) ([]Policy, error) {
+	var policies []Policy
+
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		logicalID, resource := resources.Content[i].Value, resources.Content[i+1]
+		resourceType := mappingValue(resource, "Type")
+		properties := mappingValue(resource, "Properties")
+
+		if resourceType == nil || properties == nil {
+			continue
+		}
+
+		switch resourceType.Value {
+		case "AWS::IAM::Policy", "AWS::IAM::ManagedPolicy":
+			kind := InlineKind
+			if resourceType.Value == "AWS::IAM::ManagedPolicy" {
+				kind = ManagedKind
+			}
+
+			policy, ok, err := yamlDocument(mappingValue(properties, "PolicyDocument"))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", logicalID, err)
+			}
+
+			if ok {
+				policy.Source = Source{Name: logicalID, Kind: kind, File: path, Line: resources.Content[i].Line, Lines: policy.Source.Lines}
+				policies = append(policies, policy)
+			}
+		case "AWS::IAM::Role", "AWS::IAM::User", "AWS::IAM::Group":
+			inline := mappingValue(properties, "Policies")
+			if inline == nil {
+				continue
+			}
+
+			for _, entry := range inline.Content {
+				policy, ok, err := yamlDocument(mappingValue(entry, "PolicyDocument"))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", logicalID, err)
+				}
+
+				if !ok {
+					continue
+				}
+
+				name := logicalID
+				if policyName := mappingValue(entry, "PolicyName"); policyName != nil {
+					name += "." + fmt.Sprint(yamlValue(policyName))
+				}
+
+				policy.Source = Source{
+					Name:  name,
+					Kind:  InlineKind,
+					Via:   []string{cloudFormationPrincipals[resourceType.Value] + "/" + logicalID},
+					File:  path,
+					Line:  entry.Line,
+					Lines: policy.Source.Lines,
+				}
+				policies = append(policies, policy)
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+func loadState(path string, resources *yaml.Node) ([]Policy, error) {
+	var policies []Policy
+
+	for _, resource := range resources.Content {
+		resourceType := fmt.Sprint(yamlValue(mappingValue(resource, "type")))
+		address := resourceType + "." + fmt.Sprint(yamlValue(mappingValue(resource, "name")))
+
+		if module := mappingValue(resource, "module"); module != nil {
+			address = module.Value + "." + address
+		}
+
+		kind, attribute := terraformPolicyResources[resourceType], "policy"
+
+		switch {
+		case resourceType == "aws_iam_policy_document":
+			kind, attribute, address = DocumentKind, "json", "data."+address
+		case resourceType == "aws_iam_role":
+			kind, attribute = InlineKind, ""
+		case kind == "":
+			continue
+		}
+
+		instances := mappingValue(resource, "instances")
+		if instances == nil {
+			continue
+		}
+
+		for _, instance := range instances.Content {
+			attributes := mappingValue(instance, "attributes")
+			if attributes == nil {
+				continue
+			}
+
+			documents := map[string]*yaml.Node{}
+
+			if attribute != "" {
+				documents[address] = mappingValue(attributes, attribute)
+			} else if inline := mappingValue(attributes, "inline_policy"); inline != nil {
+				for _, entry := range inline.Content {
+					documents[address+"."+fmt.Sprint(yamlValue(mappingValue(entry, "name")))] = mappingValue(entry, "policy")
+				}
+			}
+
+			for _, name := range sortedKeys(documents) {
+				node := documents[name]
+				if node == nil || node.Value == "" {
+					continue
+				}
+
+				var document interface{}
+
+				if err := json.Unmarshal([]byte(node.Value), &document); err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+
+				if namesPrincipal(document) {
+					continue
+				}
+
+				policy, err := parseDocument(document)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+
+				policy.Source = Source{Name: name, Kind: kind, File: path, Line: node.Line}
+				policies = append(policies, policy)
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+// yamlDocument converts a policy document node, recording the line of each statement.
+func yamlDocument(node *yaml.Node) (Policy, bool, error) {
+	if node == nil {
+		return Policy{}, false, nil
+	}
+
+	document := yamlValue(node)
+	if namesPrincipal(document) {
+		return Policy{}, false, nil
+	}
+
+	policy, err := parseDocument(document)
+	if err != nil {
+		return Policy{}, false, err
+	}
+
+	if statements := mappingValue(node, StatementField); statements != nil {
+		if statements.Kind == yaml.SequenceNode {
+			for _, statement := range statements.Content {
+				policy.Source.Lines = append(policy.Source.Lines, statement.Line)
+			}
+		} else {
+			policy.Source.Lines = []int{statements.Line}
+		}
+	}
+
+	return policy, true, nil
+}
+
+// yamlValue converts a node to plain values. Scalars stay strings, as written, and CloudFormation
+// intrinsic functions such as !Ref, !Sub and Fn::GetAtt become "${...}" interpolations.
+func yamlValue(node *yaml.Node) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			return yamlValue(node.Content[0])
+		}
+
+		return nil
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.MappingNode:
+		object := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			object[node.Content[i].Value] = yamlValue(node.Content[i+1])
+		}
+
+		if len(object) == 1 {
+			for key, value := range object {
+				if key == "Ref" || strings.HasPrefix(key, "Fn::") {
+					return intrinsic(strings.TrimPrefix(key, "Fn::"), value)
+				}
+			}
+		}
+
+		return object
+	case yaml.SequenceNode:
+		items := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			items = append(items, yamlValue(item))
+		}
+
+		if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+			return intrinsic(strings.TrimPrefix(node.Tag, "!"), items)
+		}
+
+		return items
+	}
+
+	if node.Tag == "!!null" {
+		return nil
+	}
+
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		return intrinsic(strings.TrimPrefix(node.Tag, "!"), node.Value)
+	}
+
+	return node.Value
+}
+
+// intrinsic renders a CloudFormation intrinsic function as a string.
+func intrinsic(name string, value interface{}) string {
+	items, _ := value.([]interface{})
+
+	switch name {
+	case "Sub":
+		if len(items) > 0 {
+			return fmt.Sprint(items[0])
+		}
+
+		return fmt.Sprint(value)
+	case "Join":
+		if len(items) == 2 {
+			parts, _ := items[1].([]interface{})
+			texts := make([]string, 0, len(parts))
+
+			for _, part := range parts {
+				texts = append(texts, fmt.Sprint(part))
+			}
+
+			return strings.Join(texts, fmt.Sprint(items[0]))
+		}
+	case "GetAtt":
+		if text, ok := value.(string); ok {
+			return "${" + text + "}"
+		}
+
+		texts := make([]string, 0, len(items))
+		for _, item := range items {
+			texts = append(texts, fmt.Sprint(item))
+		}
+
+		return "${" + strings.Join(texts, ".") + "}"
+	case "Ref":
+		return "${" + fmt.Sprint(value) + "}"
+	}
+
+	encoded, _ := json.Marshal(value)
+
+	return "${" + name + "(" + string(encoded) + ")}"
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// namesPrincipal reports whether a document is a trust or resource policy rather than a permission policy.
+func namesPrincipal(document interface{}) bool {
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	statements, ok := object[StatementField].([]interface{})
+	if !ok {
+		statements = []interface{}{object[StatementField]}
+	}
+
+	for _, statement := range statements {
+		fields, ok := statement.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := fields[PrincipalField]; ok {
+			return true
+		}
+
+		if _, ok := fields[NotPrincipalField]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseDocument parses a policy held as plain values.
+func parseDocument(document interface{}) (Policy, error) {
+	raw, err := json.Marshal(document)
+	if err != nil {
+		return NewPolicy(), err
+	}
+
+	return Parse(string(raw))
+}
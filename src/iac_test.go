@@ -0,0 +1,398 @@
+package Identity
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+const terraformFixture = `data "aws_iam_policy_document" "read" {
+  statement {
+    sid       = "Read"
+    actions   = ["s3:GetObject"]
+    resources = ["${aws_s3_bucket.logs.arn}/*"]
+
+    condition {
+      test     = "Bool"
+      variable = "aws:SecureTransport"
+      values   = ["true"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "trust" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+  }
+}
+
+resource "aws_iam_policy" "write" {
+  name = "write"
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect   = "Allow"
+        Action   = ["sqs:SendMessage"]
+        Resource = aws_sqs_queue.jobs.arn
+      },
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "from_data" {
+  role   = aws_iam_role.app.id
+  policy = data.aws_iam_policy_document.read.json
+}
+
+resource "aws_iam_role" "app" {
+  name               = "app"
+  assume_role_policy = data.aws_iam_policy_document.trust.json
+
+  inline_policy {
+    name   = "logs"
+    policy = <<EOF
+{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Action": "logs:PutLogEvents", "Resource": "*"}}
+EOF
+  }
+}
+`
+
+const cloudFormationFixture = `AWSTemplateFormatVersion: "2010-09-09"
+Resources:
+  Queue:
+    Type: AWS::SQS::Queue
+  Reader:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      PolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Effect: Allow
+            Action: sqs:ReceiveMessage
+            Resource: !GetAtt Queue.Arn
+          - Effect: Allow
+            Action:
+              - s3:GetObject
+            Resource: !Sub "arn:aws:s3:::${Bucket}/*"
+  App:
+    Type: AWS::IAM::Role
+    Properties:
+      AssumeRolePolicyDocument:
+        Statement:
+          - Effect: Allow
+            Principal:
+              Service: lambda.amazonaws.com
+            Action: sts:AssumeRole
+      Policies:
+        - PolicyName: logs
+          PolicyDocument:
+            Version: "2012-10-17"
+            Statement:
+              - Effect: Allow
+                Action: logs:PutLogEvents
+                Resource: !Join [":", ["arn:aws:logs", !Ref "AWS::Region", "*"]]
+`
+
+const stateFixture = `{
+  "version": 4,
+  "terraform_version": "1.9.0",
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_iam_policy",
+      "name": "write",
+      "instances": [
+        {"attributes": {"policy": "{\"Version\":\"2012-10-17\",\"Statement\":[{\"Effect\":\"Allow\",\"Action\":\"sqs:SendMessage\",\"Resource\":\"*\"}]}"}}
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_sqs_queue",
+      "name": "jobs",
+      "instances": [{"attributes": {"policy": ""}}]
+    }
+  ]
+}`
+
+func TestLoadPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []Policy
+	}{
+		{
+			name:    "terraform",
+			file:    "main.tf",
+			content: terraformFixture,
+			want: []Policy{
+				{
+					Version: "2012-10-17",
+					Statements: []Statement{{
+						Sid:       "Read",
+						Effect:    "Allow",
+						Action:    []string{"s3:GetObject"},
+						Resource:  []string{"${aws_s3_bucket.logs.arn}/*"},
+						Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+					}},
+					Source: Source{Name: "data.aws_iam_policy_document.read", Kind: DocumentKind, Line: 1, Lines: []int{2}},
+				},
+				{
+					Version:    "2012-10-17",
+					Statements: []Statement{{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"${aws_sqs_queue.jobs.arn}"}, lists: map[string]bool{ActionField: true}}},
+					Source:     Source{Name: "aws_iam_policy.write", Kind: ManagedKind, Line: 28, Lines: []int{31}},
+				},
+				{
+					Version:       "2012-10-17",
+					Statements:    []Statement{{Effect: "Allow", Action: []string{"logs:PutLogEvents"}, Resource: []string{"*"}}},
+					Source:        Source{Name: "aws_iam_role.app.logs", Kind: InlineKind, Line: 51},
+					loneStatement: true,
+				},
+			},
+		},
+		{
+			name:    "cloudformation",
+			file:    "template.yaml",
+			content: cloudFormationFixture,
+			want: []Policy{
+				{
+					Version: "2012-10-17",
+					Statements: []Statement{
+						{Effect: "Allow", Action: []string{"sqs:ReceiveMessage"}, Resource: []string{"${Queue.Arn}"}},
+						{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::${Bucket}/*"}, lists: map[string]bool{ActionField: true}},
+					},
+					Source: Source{Name: "Reader", Kind: ManagedKind, Line: 5, Lines: []int{11, 14}},
+				},
+				{
+					Version:    "2012-10-17",
+					Statements: []Statement{{Effect: "Allow", Action: []string{"logs:PutLogEvents"}, Resource: []string{"arn:aws:logs:${AWS::Region}:*"}}},
+					Source:     Source{Name: "App.logs", Kind: InlineKind, Via: []string{"role/App"}, Line: 28, Lines: []int{32}},
+				},
+			},
+		},
+		{
+			name: "cloudformation_alias",
+			file: "template.yaml",
+			content: `Resources:
+  Worker:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      PolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Effect: Allow
+            Action: sqs:ReceiveMessage
+            Resource: &queue arn:aws:sqs:eu-west-2:123456789012:jobs
+          - Effect: Allow
+            Action: sqs:DeleteMessage
+            Resource: *queue
+`,
+			want: []Policy{{
+				Version: "2012-10-17",
+				Statements: []Statement{
+					{Effect: "Allow", Action: []string{"sqs:ReceiveMessage"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:jobs"}},
+					{Effect: "Allow", Action: []string{"sqs:DeleteMessage"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:jobs"}},
+				},
+				Source: Source{Name: "Worker", Kind: ManagedKind, Line: 2, Lines: []int{8, 11}},
+			}},
+		},
+		{
+			name:    "state",
+			file:    "terraform.tfstate",
+			content: stateFixture,
+			want: []Policy{{
+				Version:    "2012-10-17",
+				Statements: []Statement{{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"*"}}},
+				Source:     Source{Name: "aws_iam_policy.write", Kind: ManagedKind, Line: 10},
+			}},
+		},
+		{
+			name: "terraform_not_fields",
+			file: "guard.tf",
+			content: `data "aws_iam_policy_document" "guard" {
+  statement {
+    effect        = "Deny"
+    not_actions   = ["iam:*"]
+    not_resources = ["arn:aws:s3:::logs/*"]
+
+    condition {
+      test     = "BoolIfExists"
+      variable = "aws:MultiFactorAuthPresent"
+      values   = ["false"]
+    }
+  }
+}
+
+data "aws_iam_policy_document" "trust" {
+  statement {
+    actions = ["sts:AssumeRole"]
+
+    principals {
+      type        = "Service"
+      identifiers = ["ec2.amazonaws.com"]
+    }
+  }
+}
+`,
+			want: []Policy{{
+				Version: "2012-10-17",
+				Statements: []Statement{{
+					Effect:      "Deny",
+					NotAction:   []string{"iam:*"},
+					NotResource: []string{"arn:aws:s3:::logs/*"},
+					Condition:   Condition{"BoolIfExists": {"aws:MultiFactorAuthPresent": {"false"}}},
+				}},
+				Source: Source{Name: "data.aws_iam_policy_document.guard", Kind: DocumentKind, Line: 1, Lines: []int{2}},
+			}},
+		},
+		{
+			name:    "unrelated_json",
+			file:    "package.json",
+			content: `{"name": "app"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadPolicies(dir)
+			if err != nil {
+				t.Fatalf("LoadPolicies() error = %v", err)
+			}
+
+			for i := range tt.want {
+				tt.want[i].Source.File = path
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadPolicies() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerraformStatement(t *testing.T) {
+	src := []byte(`statement {
+  actions   = ["s3:GetObject"]
+  resources = ["*"]
+
+  principals {
+    type        = "AWS"
+    identifiers = ["arn:aws:iam::123456789012:root"]
+  }
+
+  principals {
+    type        = "AWS"
+    identifiers = ["arn:aws:iam::210987654321:root"]
+  }
+
+  not_principals {
+    type        = "Service"
+    identifiers = ["ec2.amazonaws.com"]
+  }
+}
+`)
+
+	file, diags := hclsyntax.ParseConfig(src, "bucket.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	got := terraformStatement(file.Body.(*hclsyntax.Body).Blocks[0], src)
+
+	want := map[string]interface{}{
+		EffectField:       Allow,
+		ActionField:       []interface{}{"s3:GetObject"},
+		ResourceField:     []interface{}{"*"},
+		PrincipalField:    map[string]interface{}{"AWS": []interface{}{"arn:aws:iam::123456789012:root", "arn:aws:iam::210987654321:root"}},
+		NotPrincipalField: map[string]interface{}{"Service": []interface{}{"ec2.amazonaws.com"}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terraformStatement() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadPoliciesPartial(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"main.tf":     terraformFixture,
+		"broken.yaml": "Resources: [unclosed",
+		"bomb.yaml":   yamlAliasBomb() + "Resources: {}\n",
+		"broken.json": `{"Resources": `,
+		"merged.tf": `data "aws_iam_policy_document" "merged" {
+  source_policy_documents = [data.aws_iam_policy_document.read.json]
+
+  statement {
+    actions   = ["sqs:SendMessage"]
+    resources = ["*"]
+  }
+}
+`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policies, err := LoadPolicies(dir)
+
+	var problems LoadErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("LoadPolicies() error = %v, want LoadErrors", err)
+	}
+
+	var problemFiles []string
+	for _, problem := range problems {
+		problemFiles = append(problemFiles, filepath.Base(problem.File))
+	}
+
+	if want := []string{"bomb.yaml", "broken.json", "broken.yaml", "merged.tf"}; !reflect.DeepEqual(problemFiles, want) {
+		t.Errorf("LoadErrors files = %v, want %v", problemFiles, want)
+	}
+
+	if !strings.Contains(problems[0].Error(), "YAML aliases expand to more than") {
+		t.Errorf("LoadErrors[0] = %v, want the alias expansion reported", problems[0])
+	}
+
+	if !strings.Contains(problems[3].Error(), "source_policy_documents is not supported") {
+		t.Errorf("LoadErrors[3] = %v, want source_policy_documents reported", problems[2])
+	}
+
+	// the three policies of main.tf and the merged document's own statement
+	if len(policies) != 4 {
+		t.Errorf("LoadPolicies() = %d policies, want 4", len(policies))
+	}
+}
+
+func TestLoadPoliciesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(path, []byte(`resource "aws_iam_policy" "broken" {`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadPolicies(path); err == nil {
+		t.Error("LoadPolicies() expected an error for invalid HCL")
+	}
+}
@@ -2,36 +2,72 @@ package Identity
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/aws/smithy-go"
-	"github.com/rs/zerolog/log"
 )
 
 type IAM struct {
-	Name     string   `json:"Name"`
-	Account  string   `json:"Account"`
-	IamType  string   `json:"IamType"`
-	Policies []Policy `json:"Policies"`
+	Name     string          `json:"Name"`
+	Account  string          `json:"Account"`
+	IamType  string          `json:"IamType"`
+	Policies []Policy        `json:"Policies"`
+	Boundary *Policy         `json:"Boundary,omitempty"`
+	Trust    json.RawMessage `json:"Trust,omitempty"`
 }
 
+// Policy is an IAM policy document. It marshals to JSON that AWS accepts, in the order AWS writes it.
 type Policy struct {
 	Version    string      `json:"Version"`
+	ID         string      `json:"Id,omitempty"`
 	Statements []Statement `json:"Statement"`
+	Source     Source      `json:"-"`
+
+	// loneStatement records that the document wrote its one statement as an object rather than a list
+	loneStatement bool
+}
+
+// Source records where a policy came from and the attachment path that brings it to the identity.
+// Policies loaded from code also record their file, line and the line of each statement.
+type Source struct {
+	Name  string   `json:"Name,omitempty"`
+	Arn   string   `json:"Arn,omitempty"`
+	Kind  string   `json:"Kind,omitempty"`
+	Via   []string `json:"Via,omitempty"`
+	File  string   `json:"File,omitempty"`
+	Line  int      `json:"Line,omitempty"`
+	Lines []int    `json:"Lines,omitempty"`
 }
 
 // Statement is the core of an IAM policy.
 type Statement struct {
-	Sid      string   `json:"Sid"`
-	Effect   string   `json:"Effect"`
-	Action   []string `json:"Action"`
-	Resource []string `json:"Resource"`
+	Sid          string    `json:"Sid,omitempty"`
+	Effect       string    `json:"Effect"`
+	Principal    Principal `json:"Principal,omitempty"`
+	NotPrincipal Principal `json:"NotPrincipal,omitempty"`
+	Action       []string  `json:"Action"`
+	NotAction    []string  `json:"NotAction,omitempty"`
+	Resource     []string  `json:"Resource"`
+	NotResource  []string  `json:"NotResource,omitempty"`
+	Condition    Condition `json:"Condition,omitempty"`
+
+	// lists holds the fields, such as Action or Condition.StringEquals.aws:SourceVpc, whose one value
+	// the document wrote as a list rather than a string
+	lists map[string]bool
 }
 
+// Principal maps a principal type, such as AWS or Service, to its identifiers. The anonymous
+// principal "*" is held as {"*": ["*"]}.
+type Principal map[string][]string
+
+// Condition maps a condition operator to its keys and their values.
+type Condition map[string]map[string][]string
+
 func SetIamType(result *sts.GetCallerIdentityOutput) (IAM, error) {
 	var myIdentity IAM
 
@@ -57,7 +93,7 @@ func SetIamType(result *sts.GetCallerIdentityOutput) (IAM, error) {
 }
 
 func GetIam(ctx context.Context) (IAM, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(GetAWSProfile()))
+	cfg, err := loadConfig(ctx)
 	if err != nil {
 		return IAM{}, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -67,11 +103,7 @@ func GetIam(ctx context.Context) (IAM, error) {
 
 	result, err := svc.GetCallerIdentity(ctx, input)
 	if err != nil {
-		var apiErr smithy.APIError
-		if errors.As(err, &apiErr) {
-			log.Error().Msgf("API error: %s - %s", apiErr.ErrorCode(), apiErr.ErrorMessage())
-		}
-		return IAM{}, fmt.Errorf("failed to get caller identity: %w", err)
+		return IAM{}, fmt.Errorf("failed to get caller identity: %w", apiError(err, IAM{}, "sts:GetCallerIdentity", ""))
 	}
 
 	iamIdentity, err := SetIamType(result)
@@ -102,6 +134,8 @@ func GetIam(ctx context.Context) (IAM, error) {
 				return IAM{}, fmt.Errorf("failed to parse policy document: %w", err)
 			}
 
+			Parsed.Source = Source{Name: v, Kind: InlineKind, Via: []string{iamIdentity.Ref()}}
+
 			iamIdentity.Policies = append(iamIdentity.Policies, Parsed)
 		}
 
@@ -122,6 +156,8 @@ func GetIam(ctx context.Context) (IAM, error) {
 			if err != nil {
 				return IAM{}, fmt.Errorf("failed to parse: %w", err)
 			}
+
+			Parsed.Source = Source{Name: *v.PolicyName, Arn: *v.PolicyArn, Kind: ManagedKind, Via: []string{iamIdentity.Ref()}}
 			iamIdentity.Policies = append(iamIdentity.Policies, Parsed)
 		}
 
@@ -145,6 +181,10 @@ func GetIam(ctx context.Context) (IAM, error) {
 				return IAM{}, fmt.Errorf("failed to get user policies from group: %w", err)
 			}
 
+			for i := range groupPolicies.Policies {
+				groupPolicies.Policies[i].Source.Via = append([]string{iamIdentity.Ref()}, groupPolicies.Policies[i].Source.Via...)
+			}
+
 			iamIdentity.Policies = append(iamIdentity.Policies, groupPolicies.Policies...)
 		}
 
@@ -173,9 +213,27 @@ func GetIam(ctx context.Context) (IAM, error) {
 				return IAM{}, fmt.Errorf("failed to parse policies: %w", err)
 			}
 
+			Parsed.Source = Source{Name: v, Kind: InlineKind, Via: []string{iamIdentity.Ref()}}
+
 			iamIdentity.Policies = append(iamIdentity.Policies, Parsed)
 		}
 
+		// the role is fetched once for both its trust policy and its permissions boundary
+		role, err := GetRole(ctx, iamIdentity)
+		if err != nil {
+			return IAM{}, fmt.Errorf("failed to get role: %w", err)
+		}
+
+		iamIdentity.Trust, err = trustPolicy(role.Role)
+		if err != nil {
+			return IAM{}, fmt.Errorf("failed to get trust policy: %w", err)
+		}
+
+		iamIdentity.Boundary, err = boundaryPolicy(ctx, iamIdentity, role.Role.PermissionsBoundary)
+		if err != nil {
+			return IAM{}, fmt.Errorf("failed to get permissions boundary: %w", err)
+		}
+
 		MoreRolePolicies, err := GetAttachedRolePolicies(ctx, iamIdentity)
 		if err != nil {
 			return IAM{}, fmt.Errorf("failed to get attached role policies: %w", err)
@@ -194,14 +252,90 @@ func GetIam(ctx context.Context) (IAM, error) {
 				return IAM{}, fmt.Errorf("failed to parse policies: %w", err)
 			}
 
+			Parsed.Source = Source{Name: *v.PolicyName, Arn: *v.PolicyArn, Kind: ManagedKind, Via: []string{iamIdentity.Ref()}}
+
 			iamIdentity.Policies = append(iamIdentity.Policies, Parsed)
 		}
 	default:
 		return IAM{}, fmt.Errorf("failed to determine iam")
 	}
+
+	if iamIdentity.IamType != RoleType {
+		iamIdentity.Boundary, err = GetBoundary(ctx, iamIdentity)
+		if err != nil {
+			return IAM{}, fmt.Errorf("failed to get permissions boundary: %w", err)
+		}
+	}
+
 	return iamIdentity, nil
 }
 
+// LoadIAM reads an identity snapshot previously written as JSON, or an encoded IAM.
+func LoadIAM(path string) (IAM, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return IAM{}, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	identity, err := unmarshalSnapshot(raw)
+	if err != nil {
+		return IAM{}, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+
+	return identity, nil
+}
+
+// Ref names the identity as type/name, the form used in policy attachment paths.
+func (i IAM) Ref() string {
+	return i.IamType + "/" + i.Name
+}
+
+// GetBoundary returns the permissions boundary of a user or role, or nil when none is set.
+func GetBoundary(ctx context.Context, ident IAM) (*Policy, error) {
+	var boundary *types.AttachedPermissionsBoundary
+
+	switch ident.IamType {
+	case UserType:
+		user, err := GetUser(ctx, ident)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		boundary = user.User.PermissionsBoundary
+	case RoleType:
+		role, err := GetRole(ctx, ident)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get role: %w", err)
+		}
+
+		boundary = role.Role.PermissionsBoundary
+	}
+
+	return boundaryPolicy(ctx, ident, boundary)
+}
+
+// boundaryPolicy fetches and parses the permissions boundary attached to a user or role, if any.
+func boundaryPolicy(ctx context.Context, ident IAM, boundary *types.AttachedPermissionsBoundary) (*Policy, error) {
+	if boundary == nil || boundary.PermissionsBoundaryArn == nil {
+		return nil, nil
+	}
+
+	raw, err := GetPolicy(ctx, *boundary.PermissionsBoundaryArn, ident)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get boundary policy: %w", err)
+	}
+
+	Parsed, err := Parse(*raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse boundary policy: %w", err)
+	}
+
+	arn := *boundary.PermissionsBoundaryArn
+	Parsed.Source = Source{Name: arn[strings.LastIndex(arn, "/")+1:], Arn: arn, Kind: BoundaryKind, Via: []string{ident.Ref()}}
+
+	return &Parsed, nil
+}
+
 func GetPoliciesForGroup(ctx context.Context, iamIdentity IAM) (IAM, error) {
 	GroupPolicies, err := GetAttachedGroupPolicies(ctx, iamIdentity)
 
@@ -222,6 +356,8 @@ func GetPoliciesForGroup(ctx context.Context, iamIdentity IAM) (IAM, error) {
 			return IAM{}, fmt.Errorf("failed to parse policies: %w", err)
 		}
 
+		Parsed.Source = Source{Name: *v.PolicyName, Arn: *v.PolicyArn, Kind: ManagedKind, Via: []string{iamIdentity.Ref()}}
+
 		iamIdentity.Policies = append(iamIdentity.Policies, Parsed)
 	}
 
@@ -244,8 +380,34 @@ func GetPoliciesForGroup(ctx context.Context, iamIdentity IAM) (IAM, error) {
 			return IAM{}, fmt.Errorf("failed to parse policies: %w", err)
 		}
 
+		Parsed.Source = Source{Name: v, Kind: InlineKind, Via: []string{iamIdentity.Ref()}}
+
 		iamIdentity.Policies = append(iamIdentity.Policies, Parsed)
 	}
 
 	return iamIdentity, nil
 }
+
+// GetTrustPolicy returns the assume role policy document of a role.
+func GetTrustPolicy(ctx context.Context, ident IAM) (json.RawMessage, error) {
+	role, err := GetRole(ctx, ident)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	return trustPolicy(role.Role)
+}
+
+// trustPolicy returns the unescaped assume role policy document of a role.
+func trustPolicy(role *types.Role) (json.RawMessage, error) {
+	if role == nil || role.AssumeRolePolicyDocument == nil {
+		return nil, nil
+	}
+
+	document, err := url.QueryUnescape(*role.AssumeRolePolicyDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unescape trust policy: %w", err)
+	}
+
+	return json.RawMessage(document), nil
+}
@@ -20,6 +20,7 @@ func TestGetIam(t *testing.T) {
 					Resource: []string{"*"},
 				},
 			},
+			Source: Source{Name: "basic", Kind: InlineKind, Via: []string{"user/basic"}},
 		},
 	}
 
@@ -88,8 +89,10 @@ func TestGetPoliciesForGroup(t *testing.T) {
 								Effect:   "Allow",
 								Action:   []string{"s3:ListBucket"},
 								Resource: []string{"*"},
+								lists:    map[string]bool{ActionField: true},
 							},
 						},
+						Source: Source{Name: "policya", Kind: InlineKind, Via: []string{"group/multipolicygroup"}},
 					},
 					{
 						Version: "2012-10-17",
@@ -98,8 +101,10 @@ func TestGetPoliciesForGroup(t *testing.T) {
 								Effect:   "Deny",
 								Action:   []string{"iam:*"},
 								Resource: []string{"*"},
+								lists:    map[string]bool{ActionField: true},
 							},
 						},
+						Source: Source{Name: "policyb", Kind: InlineKind, Via: []string{"group/multipolicygroup"}},
 					},
 				},
 			},
@@ -1,6 +1,9 @@
 package Identity
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -67,3 +70,72 @@ func TestSetIamType(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadIAM(t *testing.T) {
+	managed, err := Parse(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	managed.Source = Source{Name: "ReadOnly", Arn: "arn:aws:iam::aws:policy/ReadOnly", Kind: ManagedKind, Via: []string{"user/bob", "group/devs"}}
+
+	boundary := managed
+	boundary.Source = Source{Name: "limit", Arn: "arn:aws:iam::680235478471:policy/limit", Kind: BoundaryKind, Via: []string{"user/bob"}}
+
+	want := IAM{Name: "bob", Account: "680235478471", IamType: UserType, Policies: []Policy{managed}, Boundary: &boundary}
+
+	raw, err := json.Marshal(NewSnapshot(want))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	encoded, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	// an encoded IAM holds the documents alone, as it always has
+	documents := IAM{Name: want.Name, Account: want.Account, IamType: want.IamType, Policies: []Policy{{Version: managed.Version, Statements: managed.Statements}}}
+	documents.Boundary = &Policy{Version: managed.Version, Statements: managed.Statements}
+
+	tests := []struct {
+		name     string
+		snapshot string
+		want     IAM
+	}{
+		{"with_sources", string(raw), want},
+		{"encoded_iam", string(encoded), documents},
+		{
+			name:     "bare_documents",
+			snapshot: `{"Name":"bob","Account":"680235478471","IamType":"user","Policies":[{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}]}`,
+			want:     IAM{Name: "bob", Account: "680235478471", IamType: UserType, Policies: []Policy{{Version: managed.Version, Statements: managed.Statements}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.json")
+			if err := os.WriteFile(path, []byte(tt.snapshot), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadIAM(path)
+			if err != nil {
+				t.Fatalf("LoadIAM() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadIAM() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var decoded IAM
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, documents) {
+		t.Errorf("json.Unmarshal() = %+v, want %+v", decoded, documents)
+	}
+}
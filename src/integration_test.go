@@ -0,0 +1,47 @@
+//go:build integration
+
+package Identity
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// liveAWSEnv, when set, runs the integration tests against the AWS account of the current profile
+// rather than the fake endpoint seeded from testdata/fake/account.json.
+const liveAWSEnv = "IDENTITY_LIVE_AWS"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(liveAWSEnv) != "" {
+		os.Exit(m.Run())
+	}
+
+	os.Exit(runWithFakeAWS(m))
+}
+
+func runWithFakeAWS(m *testing.M) int {
+	account, err := readFakeAccount("account.json")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	fake := startFakeAWS(account)
+	defer fake.server.Close()
+
+	dir, err := os.MkdirTemp("", "identity-fake-aws")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	for key, value := range fake.environment(dir) {
+		os.Setenv(key, value)
+	}
+
+	return m.Run()
+}
@@ -0,0 +1,229 @@
+package Identity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// lastAccessedPoll is how long to wait between checks on a service last accessed job.
+var lastAccessedPoll = 2 * time.Second
+
+// UnusedService is a granted service, and the granted actions in it, not used within the window.
+type UnusedService struct {
+	Service      string     `json:"Service"`
+	LastAccessed *time.Time `json:"LastAccessed,omitempty"`
+	Actions      []string   `json:"Actions"`
+}
+
+// Removal suggests dropping actions from a statement, or the whole statement when nothing in it was used.
+type Removal struct {
+	Policy    string   `json:"Policy"`
+	Statement int      `json:"Statement"`
+	Sid       string   `json:"Sid,omitempty"`
+	Actions   []string `json:"Actions"`
+	Whole     bool     `json:"Whole"`
+}
+
+// UnusedReport lists what an identity was granted but did not use in the last Days days.
+type UnusedReport struct {
+	Days     int             `json:"Days"`
+	Services []UnusedService `json:"Services"`
+	Removals []Removal       `json:"Removals"`
+}
+
+// GetServiceLastAccessed runs a service last accessed job at action level for an identity and returns its results.
+func GetServiceLastAccessed(ctx context.Context, ident IAM) ([]types.ServiceLastAccessed, error) {
+	svc, err := NewIAMClient(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := svc.GenerateServiceLastAccessedDetails(ctx, &iam.GenerateServiceLastAccessedDetailsInput{
+		Arn:         aws.String(FormatArn(ident)),
+		Granularity: types.AccessAdvisorUsageGranularityTypeActionLevel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate service last accessed details: %w",
+			apiError(err, ident, "iam:GenerateServiceLastAccessedDetails", FormatArn(ident)))
+	}
+
+	var services []types.ServiceLastAccessed
+	var marker *string
+
+	for {
+		result, err := svc.GetServiceLastAccessedDetails(ctx, &iam.GetServiceLastAccessedDetailsInput{
+			JobId:  job.JobId,
+			Marker: marker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get service last accessed details: %w",
+				apiError(err, ident, "iam:GetServiceLastAccessedDetails", "job/"+aws.ToString(job.JobId)))
+		}
+
+		switch result.JobStatus {
+		case types.JobStatusTypeFailed:
+			message := "unknown error"
+			if result.Error != nil && result.Error.Message != nil {
+				message = *result.Error.Message
+			}
+
+			return nil, fmt.Errorf("service last accessed job failed: %s", message)
+		case types.JobStatusTypeInProgress:
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(lastAccessedPoll):
+			}
+
+			continue
+		}
+
+		services = append(services, result.ServicesLastAccessed...)
+
+		if !result.IsTruncated {
+			return services, nil
+		}
+
+		marker = result.Marker
+	}
+}
+
+// Unused combines service last accessed data with an identity's policies and reports the services
+// and actions that are allowed but were not used in the last days days, as of now.
+func Unused(ident IAM, accessed []types.ServiceLastAccessed, days int, now time.Time) UnusedReport {
+	cutoff := now.AddDate(0, 0, -days)
+	report := UnusedReport{Days: days, Services: []UnusedService{}, Removals: []Removal{}}
+
+	byService := map[string]types.ServiceLastAccessed{}
+	for _, service := range accessed {
+		if service.ServiceNamespace != nil {
+			byService[strings.ToLower(*service.ServiceNamespace)] = service
+		}
+	}
+
+	unused := map[string]bool{}
+	unusedServices := map[string]bool{}
+	matrix := Effective(ident)
+
+	for _, service := range matrix.Services() {
+		record, tracked := byService[service]
+		if !tracked {
+			continue
+		}
+
+		serviceUnused := record.LastAuthenticated == nil || record.LastAuthenticated.Before(cutoff)
+		if serviceUnused {
+			unusedServices[service] = true
+		}
+
+		actionTimes := map[string]*time.Time{}
+		for _, action := range record.TrackedActionsLastAccessed {
+			if action.ActionName != nil {
+				actionTimes[strings.ToLower(*action.ActionName)] = action.LastAccessedTime
+			}
+		}
+
+		entry := UnusedService{Service: service, LastAccessed: record.LastAuthenticated}
+
+		for _, permission := range matrix[service] {
+			if permission.Effect != Allow {
+				continue
+			}
+
+			_, name := SplitAction(permission.Action)
+			last, actionTracked := actionTimes[strings.ToLower(name)]
+
+			if serviceUnused || (actionTracked && (last == nil || last.Before(cutoff))) {
+				entry.Actions = append(entry.Actions, permission.Action)
+				unused[strings.ToLower(permission.Action)] = true
+			}
+		}
+
+		if len(entry.Actions) > 0 {
+			report.Services = append(report.Services, entry)
+		}
+	}
+
+	for _, policy := range ident.Policies {
+		for index, statement := range policy.Statements {
+			if statement.Effect == Deny {
+				continue
+			}
+
+			removal := Removal{Policy: policy.Source.String(), Statement: index, Sid: statement.Sid}
+
+			// a NotAction statement has no patterns to drop, so it can only go as a whole
+			if len(statement.NotAction) > 0 {
+				if notActionUnused(statement, unused) {
+					removal.Actions = []string{}
+					removal.Whole = true
+					report.Removals = append(report.Removals, removal)
+				}
+
+				continue
+			}
+
+			for _, pattern := range statement.Action {
+				if patternUnused(pattern, unused, unusedServices) {
+					removal.Actions = append(removal.Actions, pattern)
+				}
+			}
+
+			if len(removal.Actions) > 0 {
+				removal.Whole = len(removal.Actions) == len(statement.Action)
+				report.Removals = append(report.Removals, removal)
+			}
+		}
+	}
+
+	sort.SliceStable(report.Removals, func(i, j int) bool {
+		return report.Removals[i].Policy < report.Removals[j].Policy
+	})
+
+	return report
+}
+
+// patternUnused reports whether every action an action pattern grants went unused.
+func patternUnused(pattern string, unused map[string]bool, unusedServices map[string]bool) bool {
+	service, _ := SplitAction(pattern)
+
+	if !KnownService(service) {
+		return unusedServices[service]
+	}
+
+	actions := ExpandAction(pattern)
+	if len(actions) == 0 {
+		return false
+	}
+
+	for _, action := range actions {
+		if !unused[strings.ToLower(action)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// notActionUnused reports whether every catalogue action a NotAction statement grants went unused.
+func notActionUnused(statement Statement, unused map[string]bool) bool {
+	actions := statementActions(statement)
+	if len(actions) == 0 {
+		return false
+	}
+
+	for _, action := range actions {
+		if !unused[strings.ToLower(action)] {
+			return false
+		}
+	}
+
+	return true
+}
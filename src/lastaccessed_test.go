@@ -0,0 +1,118 @@
+package Identity
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// fakeLastAccessed answers the service last accessed calls, reporting the job in progress once and paging its results.
+type fakeLastAccessed struct {
+	IAMClient
+	arn   string
+	calls int
+	pages [][]types.ServiceLastAccessed
+}
+
+func (f *fakeLastAccessed) GenerateServiceLastAccessedDetails(_ context.Context, params *iam.GenerateServiceLastAccessedDetailsInput, _ ...func(*iam.Options)) (*iam.GenerateServiceLastAccessedDetailsOutput, error) {
+	f.arn = *params.Arn
+
+	return &iam.GenerateServiceLastAccessedDetailsOutput{JobId: aws.String("job")}, nil
+}
+
+func (f *fakeLastAccessed) GetServiceLastAccessedDetails(_ context.Context, params *iam.GetServiceLastAccessedDetailsInput, _ ...func(*iam.Options)) (*iam.GetServiceLastAccessedDetailsOutput, error) {
+	f.calls++
+
+	if f.calls == 1 {
+		return &iam.GetServiceLastAccessedDetailsOutput{JobStatus: types.JobStatusTypeInProgress}, nil
+	}
+
+	page := 0
+	if params.Marker != nil {
+		page = 1
+	}
+
+	return &iam.GetServiceLastAccessedDetailsOutput{
+		JobStatus:            types.JobStatusTypeCompleted,
+		ServicesLastAccessed: f.pages[page],
+		IsTruncated:          page == 0,
+		Marker:               aws.String("next"),
+	}, nil
+}
+
+func TestGetServiceLastAccessed(t *testing.T) {
+	fake := &fakeLastAccessed{pages: [][]types.ServiceLastAccessed{
+		{{ServiceNamespace: aws.String("s3")}},
+		{{ServiceNamespace: aws.String("sqs")}},
+	}}
+
+	defer func(original func(context.Context, IAM) (IAMClient, error), poll time.Duration) {
+		NewIAMClient = original
+		lastAccessedPoll = poll
+	}(NewIAMClient, lastAccessedPoll)
+
+	NewIAMClient = func(context.Context, IAM) (IAMClient, error) { return fake, nil }
+	lastAccessedPoll = 0
+
+	got, err := GetServiceLastAccessed(context.Background(), IAM{Name: "deploy", Account: "123456789012", IamType: RoleType})
+	if err != nil {
+		t.Fatalf("GetServiceLastAccessed() error = %v", err)
+	}
+
+	if len(got) != 2 || *got[0].ServiceNamespace != "s3" || *got[1].ServiceNamespace != "sqs" {
+		t.Errorf("GetServiceLastAccessed() = %v, want s3 then sqs", got)
+	}
+
+	if fake.arn != "arn:aws:iam::123456789012:role/deploy" {
+		t.Errorf("GetServiceLastAccessed() asked about %s", fake.arn)
+	}
+}
+
+func TestUnused(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, 0, -5)
+	old := now.AddDate(0, 0, -200)
+
+	ident := IAM{Name: "deploy", IamType: RoleType, Policies: []Policy{{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Sid: "Queues", Effect: "Allow", Action: []string{"sqs:SendMessage", "sqs:ReceiveMessage"}, Resource: []string{"*"}},
+			{Sid: "Secrets", Effect: "Allow", Action: []string{"secretsmanager:GetSecretValue"}, Resource: []string{"*"}},
+			{Sid: "Untracked", Effect: "Allow", Action: []string{"sts:GetCallerIdentity"}, Resource: []string{"*"}},
+		},
+		Source: Source{Name: "deploy", Kind: InlineKind, Via: []string{"role/deploy"}},
+	}}}
+
+	accessed := []types.ServiceLastAccessed{
+		{
+			ServiceNamespace:  aws.String("sqs"),
+			LastAuthenticated: &recent,
+			TrackedActionsLastAccessed: []types.TrackedActionLastAccessed{
+				{ActionName: aws.String("SendMessage"), LastAccessedTime: &recent},
+				{ActionName: aws.String("ReceiveMessage"), LastAccessedTime: &old},
+			},
+		},
+		{ServiceNamespace: aws.String("secretsmanager")},
+	}
+
+	want := UnusedReport{
+		Days: 90,
+		Services: []UnusedService{
+			{Service: "secretsmanager", Actions: []string{"secretsmanager:GetSecretValue"}},
+			{Service: "sqs", LastAccessed: &recent, Actions: []string{"sqs:ReceiveMessage"}},
+		},
+		Removals: []Removal{
+			{Policy: "role/deploy > deploy", Statement: 0, Sid: "Queues", Actions: []string{"sqs:ReceiveMessage"}},
+			{Policy: "role/deploy > deploy", Statement: 1, Sid: "Secrets", Actions: []string{"secretsmanager:GetSecretValue"}, Whole: true},
+		},
+	}
+
+	if got := Unused(ident, accessed, 90, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unused() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+package Identity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// currentVersion is the policy language version that supports policy variables.
+const currentVersion = "2012-10-17"
+
+// Lint rule IDs.
+const (
+	AllActionsRule       = "IAM001"
+	ServiceWildcardRule  = "IAM002"
+	WriteAnyResourceRule = "IAM003"
+	UnknownActionRule    = "IAM004"
+	PolicyVersionRule    = "IAM005"
+)
+
+var lintRules = []Rule{
+	{
+		ID:    AllActionsRule,
+		Name:  "all-actions",
+		Level: ErrorLevel,
+		Short: "Statement allows every action",
+		Help:  "Action \"*\" grants full administrator access. List the actions the identity needs instead.",
+	},
+	{
+		ID:    ServiceWildcardRule,
+		Name:  "service-wildcard",
+		Level: WarningLevel,
+		Short: "Statement allows every action in a service",
+		Help:  "An action such as \"s3:*\" includes permissions management and delete actions. Grant the actions that are used, or a narrower wildcard such as \"s3:Get*\".",
+	},
+	{
+		ID:    WriteAnyResourceRule,
+		Name:  "write-any-resource",
+		Level: WarningLevel,
+		Short: "Write or permissions management action allowed on every resource",
+		Help:  "Unconditional write and permissions management actions on Resource \"*\" reach every resource in the account. Scope the statement to ARNs, or add a condition.",
+	},
+	{
+		ID:    UnknownActionRule,
+		Name:  "unknown-action",
+		Level: WarningLevel,
+		Short: "Action wildcard matches no known action",
+		Help:  "The wildcard matches nothing in the action catalogue for its service, so it is probably misspelt and grants nothing. Concrete actions the catalogue does not list are not reported, since the catalogue is not complete.",
+	},
+	{
+		ID:    PolicyVersionRule,
+		Name:  "policy-version",
+		Level: WarningLevel,
+		Short: "Policy does not use version 2012-10-17",
+		Help:  "Policies without Version \"2012-10-17\" do not support policy variables such as ${aws:username}, which are then matched literally. Set the version.",
+	},
+}
+
+// Lint checks the policies of an identity against the lint rules. The permissions boundary is not linted.
+func Lint(ident IAM) []Finding {
+	findings := []Finding{}
+
+	for _, policy := range ident.Policies {
+		findings = append(findings, LintPolicy(policy)...)
+	}
+
+	SortFindings(findings)
+
+	return findings
+}
+
+// LintPolicy checks one policy against the lint rules.
+func LintPolicy(policy Policy) []Finding {
+	findings := []Finding{}
+
+	if policy.Version != currentVersion {
+		findings = append(findings, newFinding(lintRule(PolicyVersionRule), policy, -1,
+			fmt.Sprintf("policy version is %q, not %q", policy.Version, currentVersion)))
+	}
+
+	for index, statement := range policy.Statements {
+		for _, action := range append(append([]string{}, statement.Action...), statement.NotAction...) {
+			if unknownAction(action) {
+				findings = append(findings, newFinding(lintRule(UnknownActionRule), policy, index,
+					fmt.Sprintf("%s matches no known action", action)))
+			}
+		}
+
+		if statement.Effect != Allow {
+			continue
+		}
+
+		for _, action := range statement.Action {
+			service, name := SplitAction(action)
+
+			switch {
+			case action == "*":
+				findings = append(findings, newFinding(lintRule(AllActionsRule), policy, index, "statement allows every action"))
+			case name == "*":
+				findings = append(findings, newFinding(lintRule(ServiceWildcardRule), policy, index,
+					fmt.Sprintf("statement allows every %s action", service)))
+			}
+		}
+
+		if len(statement.Condition) == 0 && (containsString(statement.Resource, "*") || len(statement.NotResource) > 0) {
+			if risky := riskyActions(statement); len(risky) > 0 {
+				findings = append(findings, newFinding(lintRule(WriteAnyResourceRule), policy, index,
+					fmt.Sprintf("statement allows %s on every resource", strings.Join(risky, ", "))))
+			}
+		}
+	}
+
+	return findings
+}
+
+func lintRule(id string) Rule {
+	for _, rule := range lintRules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+
+	return Rule{ID: id}
+}
+
+// unknownAction reports whether an action wildcard of a catalogued service matches nothing in the catalogue.
+func unknownAction(action string) bool {
+	service, _ := SplitAction(action)
+
+	return action != "*" && KnownService(service) && len(ExpandAction(action)) == 0
+}
+
+// riskyActions returns the patterns of a statement that grant a write or permissions management action.
+// A pattern that allows every action is reported by its own rule and is left out here, and a NotAction
+// statement that grants one is described by its NotAction list.
+func riskyActions(statement Statement) []string {
+	var risky []string
+
+	if len(statement.NotAction) > 0 {
+		for _, action := range statementActions(statement) {
+			if level := AccessLevel(action); level == WriteLevel || level == PermissionsLevel {
+				return []string{"every action but " + strings.Join(statement.NotAction, ", ")}
+			}
+		}
+
+		return nil
+	}
+
+	for _, pattern := range statement.Action {
+		if pattern == "*" {
+			continue
+		}
+
+		for _, action := range ExpandAction(pattern) {
+			if level := AccessLevel(action); level == WriteLevel || level == PermissionsLevel {
+				risky = append(risky, pattern)
+
+				break
+			}
+		}
+	}
+
+	return risky
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
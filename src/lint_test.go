@@ -0,0 +1,96 @@
+package Identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{
+		{
+			name: "clean",
+			policy: Policy{Version: "2012-10-17", Statements: []Statement{
+				{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"arn:aws:s3:::logs/*"}},
+			}},
+			want: []string{},
+		},
+		{
+			name: "admin",
+			policy: Policy{Version: "2012-10-17", Statements: []Statement{
+				{Effect: "Allow", Action: []string{"*"}, Resource: []string{"*"}},
+			}},
+			want: []string{AllActionsRule},
+		},
+		{
+			name: "service_wildcard_on_any_resource",
+			policy: Policy{Version: "2012-10-17", Statements: []Statement{
+				{Effect: "Allow", Action: []string{"s3:*", "sqs:Get*"}, Resource: []string{"*"}},
+			}},
+			want: []string{ServiceWildcardRule, WriteAnyResourceRule},
+		},
+		{
+			name: "conditional_write",
+			policy: Policy{Version: "2012-10-17", Statements: []Statement{
+				{Effect: "Allow", Action: []string{"ec2:StartInstances"}, Resource: []string{"*"},
+					Condition: Condition{"StringEquals": {"aws:ResourceTag/team": {"web"}}}},
+			}},
+			want: []string{},
+		},
+		{
+			name: "not_action_on_every_resource",
+			policy: Policy{Version: "2012-10-17", Statements: []Statement{
+				{Effect: "Allow", NotAction: []string{"iam:*"}, Resource: []string{"*"}},
+			}},
+			want: []string{WriteAnyResourceRule},
+		},
+		{
+			name: "misspelt_and_old_version",
+			policy: Policy{Version: "2008-10-17", Statements: []Statement{
+				{Effect: "Deny", Action: []string{"s3:GetObjekt*", "ec2:ModifyInstanceMetadataOptions"}, Resource: []string{"*"}},
+			}},
+			want: []string{PolicyVersionRule, UnknownActionRule},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, finding := range LintPolicy(tt.policy) {
+				got = append(got, finding.RuleID)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LintPolicy() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintLocatesStatements(t *testing.T) {
+	policy := Policy{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"*"}, Resource: []string{"*"}},
+		},
+		Source: Source{Name: "aws_iam_policy.admin", Kind: ManagedKind, File: "main.tf", Line: 3, Lines: []int{6, 11}},
+	}
+
+	want := []Finding{{
+		RuleID:    AllActionsRule,
+		Level:     ErrorLevel,
+		Message:   "statement allows every action",
+		Policy:    "aws_iam_policy.admin",
+		Statement: 1,
+		File:      "main.tf",
+		Line:      11,
+	}}
+
+	if got := Lint(IAM{Policies: []Policy{policy}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package Identity
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// MarshalJSON writes a policy as AWS does: Version, Id and Statement, in that order.
+func (p Policy) MarshalJSON() ([]byte, error) {
+	var fields orderedFields
+
+	fields.add(VersionField, p.Version)
+
+	if p.ID != "" {
+		fields.add(IDField, p.ID)
+	}
+
+	switch {
+	case p.Statements == nil:
+		fields.add(StatementField, []Statement{})
+	case p.loneStatement && len(p.Statements) == 1:
+		fields.add(StatementField, p.Statements[0])
+	default:
+		fields.add(StatementField, p.Statements)
+	}
+
+	return fields.marshal()
+}
+
+// UnmarshalJSON reads a policy with the lenient parser, so that the single strings MarshalJSON writes read back.
+func (p *Policy) UnmarshalJSON(raw []byte) error {
+	policy, err := Parse(string(raw))
+	if err != nil {
+		return err
+	}
+
+	*p = policy
+
+	return nil
+}
+
+// Snapshot is the JSON form of an identity that the snapshot command writes. Encoding an IAM
+// directly gives only the policy documents, which lose their names, ARNs and attachment paths;
+// a snapshot keeps the source of every policy next to its document.
+type Snapshot struct {
+	Name     string           `json:"Name"`
+	Account  string           `json:"Account"`
+	IamType  string           `json:"IamType"`
+	Policies []SnapshotPolicy `json:"Policies"`
+	Boundary *SnapshotPolicy  `json:"Boundary,omitempty"`
+	Trust    json.RawMessage  `json:"Trust,omitempty"`
+}
+
+// SnapshotPolicy is a policy as a snapshot holds it: the document, and where it came from.
+type SnapshotPolicy struct {
+	Source   Source `json:"Source"`
+	Document Policy `json:"Document"`
+}
+
+// NewSnapshot returns the snapshot of an identity.
+func NewSnapshot(identity IAM) Snapshot {
+	snapshot := Snapshot{Name: identity.Name, Account: identity.Account, IamType: identity.IamType, Policies: []SnapshotPolicy{}, Trust: identity.Trust}
+
+	for _, policy := range identity.Policies {
+		snapshot.Policies = append(snapshot.Policies, SnapshotPolicy{Source: policy.Source, Document: policy})
+	}
+
+	if identity.Boundary != nil {
+		snapshot.Boundary = &SnapshotPolicy{Source: identity.Boundary.Source, Document: *identity.Boundary}
+	}
+
+	return snapshot
+}
+
+// unmarshalSnapshot reads a snapshot into an identity. Policies written as bare documents, as an
+// encoded IAM and older snapshots hold them, are read without a source.
+func unmarshalSnapshot(raw []byte) (IAM, error) {
+	var snapshot struct {
+		Name     string            `json:"Name"`
+		Account  string            `json:"Account"`
+		IamType  string            `json:"IamType"`
+		Policies []json.RawMessage `json:"Policies"`
+		Boundary json.RawMessage   `json:"Boundary"`
+		Trust    json.RawMessage   `json:"Trust"`
+	}
+
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return IAM{}, err
+	}
+
+	identity := IAM{Name: snapshot.Name, Account: snapshot.Account, IamType: snapshot.IamType, Trust: snapshot.Trust}
+
+	for _, entry := range snapshot.Policies {
+		policy, err := unmarshalSnapshotPolicy(entry)
+		if err != nil {
+			return IAM{}, err
+		}
+
+		identity.Policies = append(identity.Policies, policy)
+	}
+
+	if len(snapshot.Boundary) > 0 && string(snapshot.Boundary) != "null" {
+		boundary, err := unmarshalSnapshotPolicy(snapshot.Boundary)
+		if err != nil {
+			return IAM{}, err
+		}
+
+		identity.Boundary = &boundary
+	}
+
+	return identity, nil
+}
+
+func unmarshalSnapshotPolicy(raw json.RawMessage) (Policy, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return Policy{}, err
+	}
+
+	if _, ok := fields["Document"]; !ok {
+		var policy Policy
+		err := json.Unmarshal(raw, &policy)
+
+		return policy, err
+	}
+
+	var entry SnapshotPolicy
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return Policy{}, err
+	}
+
+	entry.Document.Source = entry.Source
+
+	return entry.Document, nil
+}
+
+// MarshalJSON writes a statement's fields in the order AWS uses, leaving out empty ones other than an
+// empty principal, Action or Resource, which decide whether the statement parses. As in the IAM console and AWS managed
+// policies, a list of one value is written as a string, unless the parsed document wrote it as a list,
+// and the anonymous principal as "*", so a document written in AWS order marshals back to the same bytes.
+func (s Statement) MarshalJSON() ([]byte, error) {
+	var fields orderedFields
+
+	if s.Sid != "" {
+		fields.add(SidField, s.Sid)
+	}
+
+	if s.Effect != "" {
+		fields.add(EffectField, s.Effect)
+	}
+
+	if s.Principal != nil {
+		fields.add(PrincipalField, s.principalValue(PrincipalField, s.Principal))
+	}
+
+	if s.NotPrincipal != nil {
+		fields.add(NotPrincipalField, s.principalValue(NotPrincipalField, s.NotPrincipal))
+	}
+
+	for _, list := range []struct {
+		field  string
+		values []string
+	}{{ActionField, s.Action}, {NotActionField, s.NotAction}, {ResourceField, s.Resource}, {NotResourceField, s.NotResource}} {
+		if len(list.values) > 0 || (list.values != nil && (list.field == ActionField || list.field == ResourceField)) {
+			fields.add(list.field, s.listValue(list.field, list.values))
+		}
+	}
+
+	if len(s.Condition) > 0 {
+		condition := map[string]map[string]interface{}{}
+
+		for operator, keys := range s.Condition {
+			condition[operator] = map[string]interface{}{}
+
+			for key, values := range keys {
+				condition[operator][key] = s.listValue(ConditionField+"."+operator+"."+key, values)
+			}
+		}
+
+		fields.add(ConditionField, condition)
+	}
+
+	return fields.marshal()
+}
+
+// UnmarshalJSON reads a statement with the lenient parser.
+func (s *Statement) UnmarshalJSON(raw []byte) error {
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return err
+	}
+
+	parser := policyParser{offsets: jsonOffsets(raw)}
+	statement := parser.statement(0, StatementField, document)
+
+	if len(parser.errors) > 0 {
+		return parser.errors
+	}
+
+	*s = statement
+
+	return nil
+}
+
+func (s Statement) principalValue(field string, principal Principal) interface{} {
+	if identifiers := principal[anonymousPrincipal]; len(principal) == 1 && len(identifiers) == 1 && identifiers[0] == anonymousPrincipal {
+		return anonymousPrincipal
+	}
+
+	object := map[string]interface{}{}
+	for kind, identifiers := range principal {
+		object[kind] = s.listValue(field+"."+kind, identifiers)
+	}
+
+	return object
+}
+
+// listValue returns the single value of a list of one, unless the parsed document wrote the field as a
+// list, and otherwise the list.
+func (s Statement) listValue(field string, values []string) interface{} {
+	if len(values) == 1 && !s.lists[field] {
+		return values[0]
+	}
+
+	return values
+}
+
+type orderedField struct {
+	name  string
+	value interface{}
+}
+
+// orderedFields is a JSON object that keeps its keys in the order they were added.
+type orderedFields []orderedField
+
+func (f *orderedFields) add(name string, value interface{}) {
+	*f = append(*f, orderedField{name: name, value: value})
+}
+
+func (f orderedFields) marshal() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+
+	buffer.WriteByte('{')
+
+	for i, field := range f {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		// Encode ends each value with a newline, which is dropped
+		for j, value := range []interface{}{field.name, field.value} {
+			if j > 0 {
+				buffer.WriteByte(':')
+			}
+
+			if err := encoder.Encode(value); err != nil {
+				return nil, err
+			}
+
+			buffer.Truncate(buffer.Len() - 1)
+		}
+	}
+
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package Identity
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicy_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "aws_order_and_original_forms",
+			raw:  `{"Statement": [{"Resource": "*", "Action": ["s3:GetObject"], "Effect": "Allow", "Sid": ""}], "Version": "2012-10-17"}`,
+			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":"*"}]}`,
+		},
+		{
+			name: "empty_lists",
+			raw:  `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": [], "Resource": []}]}`,
+			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":[],"Resource":[]}]}`,
+		},
+		{
+			name: "empty_principal",
+			raw:  `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": {}, "Action": "sts:AssumeRole"}]}`,
+			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{},"Action":"sts:AssumeRole"}]}`,
+		},
+		{
+			name: "id_principals_and_conditions",
+			raw:  `{"Version": "2012-10-17", "Id": "bucket", "Statement": {"Sid": "Deny", "Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": ["arn:aws:s3:::logs", "arn:aws:s3:::logs/*"], "Condition": {"Bool": {"aws:SecureTransport": false}, "StringNotEquals": {"aws:PrincipalAccount": ["111122223333", "444455556666"]}}}}`,
+			want: `{"Version":"2012-10-17","Id":"bucket","Statement":{"Sid":"Deny","Effect":"Deny","Principal":"*","Action":"s3:*","Resource":["arn:aws:s3:::logs","arn:aws:s3:::logs/*"],"Condition":{"Bool":{"aws:SecureTransport":"false"},"StringNotEquals":{"aws:PrincipalAccount":["111122223333","444455556666"]}}}}`,
+		},
+		{
+			name: "negations",
+			raw:  `{"Version": "2012-10-17", "Statement": [{"Effect": "Deny", "NotPrincipal": {"AWS": ["arn:aws:iam::111122223333:root"], "Service": ["ec2.amazonaws.com", "lambda.amazonaws.com"]}, "NotAction": "iam:*", "NotResource": "arn:aws:s3:::logs/*"}]}`,
+			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","NotPrincipal":{"AWS":["arn:aws:iam::111122223333:root"],"Service":["ec2.amazonaws.com","lambda.amazonaws.com"]},"NotAction":"iam:*","NotResource":"arn:aws:s3:::logs/*"}]}`,
+		},
+		{
+			name: "no_html_escaping",
+			raw:  `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::a&b/<key>"}]}`,
+			want: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::a&b/<key>"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			got, err := policy.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+
+			if _, err := Parse(string(got)); err != nil {
+				t.Errorf("Parse() of the output error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicy_MarshalJSONEmpty(t *testing.T) {
+	got, err := json.Marshal(NewPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"Version":"","Statement":[]}`; string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+}
+
+// TestMarshalRoundTrip parses and marshals each AWS managed policy in testdata/managed. The output must
+// read back to the same policy, be stable and be the original byte for byte, less white space.
+func TestMarshalRoundTrip(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "managed", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no managed policies found: %v", err)
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			policy, err := ParseStrict(string(raw))
+			if err != nil {
+				t.Fatalf("ParseStrict() error = %v", err)
+			}
+
+			got, err := json.Marshal(policy)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+
+			var again Policy
+			if err := json.Unmarshal(got, &again); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(again, policy) {
+				t.Errorf("round trip = %+v, want %+v", again, policy)
+			}
+
+			if stable, _ := json.Marshal(again); !bytes.Equal(stable, got) {
+				t.Errorf("second marshal = %s, want %s", stable, got)
+			}
+
+			var compact bytes.Buffer
+			_ = json.Compact(&compact, raw)
+
+			if !bytes.Equal(got, compact.Bytes()) {
+				t.Errorf("json.Marshal() = %s, want %s", got, compact.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package Identity
+
+import (
+	"sort"
+	"strings"
+)
+
+// Minimized is a policy with its action lists compressed, and its size before and after.
+type Minimized struct {
+	Policy Policy `json:"Policy"`
+	Before int    `json:"Before"`
+	After  int    `json:"After"`
+}
+
+// Minimize rewrites the action lists of a policy with wildcards that allow exactly the same actions.
+// Only services whose catalogue is complete are compressed, into the fewest patterns that match just the
+// listed actions: the whole service, or prefix wildcards such as s3:GetObject* whose every action the
+// statement already allows. A wildcard for any other service could match actions the catalogue does not
+// list. Everything else, including existing wildcards and NotAction lists, is kept as written, and a
+// statement whose expansion would change is left untouched.
+func Minimize(policy Policy) Minimized {
+	result := Minimized{Policy: policy, Before: PolicySize(policy)}
+	result.Policy.Statements = make([]Statement, 0, len(policy.Statements))
+
+	for _, statement := range policy.Statements {
+		minimized := statement
+		minimized.Action = minimizeActions(statement.Action)
+
+		if !sameExpansion(statement.Action, minimized.Action) {
+			minimized.Action = statement.Action
+		}
+
+		result.Policy.Statements = append(result.Policy.Statements, minimized)
+	}
+
+	result.After = PolicySize(result.Policy)
+
+	return result
+}
+
+// minimizeActions compresses the concrete actions of complete services in a list, service by service,
+// and drops those already covered by a wildcard in the list.
+func minimizeActions(actions []string) []string {
+	if len(actions) == 0 {
+		return actions
+	}
+
+	var patterns []string
+	var concrete []string
+	byService := map[string][]string{}
+
+	for _, action := range actions {
+		service, _ := SplitAction(action)
+
+		switch {
+		case strings.ContainsAny(action, "*?"):
+			patterns = append(patterns, action)
+		case completeService(service) && AccessLevel(action) != UnknownLevel:
+			byService[service] = append(byService[service], action)
+		default:
+			concrete = append(concrete, action)
+		}
+	}
+
+	for _, service := range sortedKeys(byService) {
+		concrete = append(concrete, compressService(service, byService[service])...)
+	}
+
+	kept := patterns
+	for _, action := range concrete {
+		if !coveredByPattern(action, patterns) {
+			kept = append(kept, action)
+		}
+	}
+
+	return uniqueSorted(kept)
+}
+
+// compressService replaces the listed actions of a complete service with the fewest patterns that
+// match exactly those actions: the service wildcard, prefix wildcards such as s3:GetObject* and the
+// actions no wildcard can stand for.
+func compressService(service string, actions []string) []string {
+	wildcards := prefixWildcards(service, actions)
+	result := wildcards
+
+	for _, action := range actions {
+		if !coveredByPattern(action, wildcards) {
+			result = append(result, action)
+		}
+	}
+
+	return result
+}
+
+// prefixWildcards returns the prefix wildcards of a complete service that each match two or more of the
+// listed actions and no other catalogue action, the shortest prefix for each action so that no wildcard
+// is covered by another. Each wildcard is written with the longest prefix its actions share, such as
+// s3:GetObject* rather than s3:GetO*, so it is readable and least likely to match actions AWS adds later.
+func prefixWildcards(service string, actions []string) []string {
+	if !completeService(service) {
+		return nil
+	}
+
+	listed := map[string]bool{}
+	for _, action := range actions {
+		if actionService, name := SplitAction(action); actionService == service && AccessLevel(action) != UnknownLevel {
+			listed[strings.ToLower(name)] = true
+		}
+	}
+
+	var names []string
+	for name := range catalogue[service] {
+		names = append(names, name)
+	}
+
+	wildcards := map[string]bool{}
+
+	for _, listedName := range sortedKeys(listed) {
+		for length := 0; length <= len(listedName); length++ {
+			matched, safe := prefixMatches(names, listedName[:length], listed)
+			if !safe {
+				continue
+			}
+
+			if len(matched) == len(names) {
+				return []string{service + ":*"}
+			}
+
+			if len(matched) > 1 {
+				wildcards[service+":"+commonPrefix(matched)+"*"] = true
+			}
+
+			break
+		}
+	}
+
+	return sortedKeys(wildcards)
+}
+
+// prefixMatches returns the catalogue names that start with a lower-cased prefix, and whether every one
+// of them is listed.
+func prefixMatches(names []string, prefix string, listed map[string]bool) ([]string, bool) {
+	var matched []string
+
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), prefix) {
+			if !listed[strings.ToLower(name)] {
+				return nil, false
+			}
+
+			matched = append(matched, name)
+		}
+	}
+
+	return matched, true
+}
+
+// commonPrefix returns the longest prefix that names share, in the casing of the first.
+func commonPrefix(names []string) string {
+	sort.Strings(names)
+
+	prefix := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+func coveredByPattern(action string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if MatchAction(pattern, action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameExpansion reports whether two action lists allow the same catalogue actions, and the same
+// patterns for services the catalogue does not list.
+func sameExpansion(before []string, after []string) bool {
+	expand := func(actions []string) []string {
+		var all []string
+		for _, action := range actions {
+			for _, expanded := range ExpandAction(action) {
+				all = append(all, strings.ToLower(expanded))
+			}
+		}
+
+		return uniqueSorted(all)
+	}
+
+	a, b := expand(before), expand(after)
+
+	return len(a) == len(b) && strings.Join(a, "\n") == strings.Join(b, "\n")
+}
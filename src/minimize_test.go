@@ -0,0 +1,187 @@
+package Identity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	allSqs := ExpandAction("sqs:*")
+
+	tests := []struct {
+		name    string
+		actions []string
+		want    []string
+	}{
+		{
+			name:    "verb_family",
+			actions: []string{"sqs:ListQueues", "sqs:ListQueueTags", "sqs:ListDeadLetterSourceQueues", "sqs:ListMessageMoveTasks", "sqs:SendMessage"},
+			want:    []string{"sqs:List*", "sqs:SendMessage"},
+		},
+		{
+			name:    "partial_family",
+			actions: []string{"sqs:GetQueueUrl", "sqs:ListQueues", "sqs:ListQueueTags"},
+			want:    []string{"sqs:GetQueueUrl", "sqs:ListQueue*"},
+		},
+		{
+			name:    "not_widened",
+			actions: []string{"sqs:GetQueueUrl", "sqs:ListQueues", "sqs:SendMessage"},
+			want:    []string{"sqs:GetQueueUrl", "sqs:ListQueues", "sqs:SendMessage"},
+		},
+		{
+			name:    "whole_service",
+			actions: allSqs,
+			want:    []string{"sqs:*"},
+		},
+		{
+			name:    "incomplete_service",
+			actions: []string{"s3:GetObject", "s3:GetObjectAcl", "s3:PutObject"},
+			want:    []string{"s3:GetObject", "s3:GetObjectAcl", "s3:PutObject"},
+		},
+		{
+			name:    "kept_as_written",
+			actions: []string{"s3:Get*", "s3:GetObject", "made-up:DoThing", "sqs:NotAnAction"},
+			want:    []string{"made-up:DoThing", "s3:Get*", "sqs:NotAnAction"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkMinimize(t, tt.actions, tt.want)
+		})
+	}
+}
+
+// TestMinimizeS3 treats the catalogue's s3 actions as the whole service, as they would be once generated
+// from the service authorization reference, to check the prefix search on a large service.
+func TestMinimizeS3(t *testing.T) {
+	completeServices["s3"] = true
+	t.Cleanup(func() { delete(completeServices, "s3") })
+
+	tests := []struct {
+		name    string
+		actions []string
+		want    []string
+	}{
+		{
+			name: "object_family",
+			actions: []string{
+				"s3:GetObject", "s3:GetObjectAcl", "s3:GetObjectAttributes", "s3:GetObjectLegalHold", "s3:GetObjectRetention",
+				"s3:GetObjectTagging", "s3:GetObjectTorrent", "s3:GetObjectVersion", "s3:GetObjectVersionAcl",
+				"s3:GetObjectVersionAttributes", "s3:GetObjectVersionTagging", "s3:PutObject",
+			},
+			want: []string{"s3:GetObject*", "s3:PutObject"},
+		},
+		{
+			name: "nested_family",
+			actions: []string{
+				"s3:GetObject", "s3:GetObjectVersion", "s3:GetObjectVersionAcl", "s3:GetObjectVersionAttributes",
+				"s3:GetObjectVersionTagging",
+			},
+			want: []string{"s3:GetObject", "s3:GetObjectVersion*"},
+		},
+		{
+			name:    "shortest_set",
+			actions: []string{"s3:ListAllMyBuckets", "s3:ListBucket", "s3:ListBucketMultipartUploads", "s3:ListBucketVersions", "s3:GetBucketPolicy", "s3:GetBucketPolicyStatus"},
+			want:    []string{"s3:GetBucketPolicy*", "s3:ListAllMyBuckets", "s3:ListBucket*"},
+		},
+		{
+			name:    "prefix_of_unlisted",
+			actions: []string{"s3:GetObject", "s3:GetObjectAcl", "s3:PutObject"},
+			want:    []string{"s3:GetObject", "s3:GetObjectAcl", "s3:PutObject"},
+		},
+		{
+			name:    "whole_service",
+			actions: ExpandAction("s3:*"),
+			want:    []string{"s3:*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkMinimize(t, tt.actions, tt.want)
+		})
+	}
+}
+
+func checkMinimize(t *testing.T, actions []string, want []string) {
+	t.Helper()
+
+	policy := Policy{Version: "2012-10-17", Statements: []Statement{{Effect: "Allow", Action: actions, Resource: []string{"*"}}}}
+
+	got := Minimize(policy)
+
+	if !reflect.DeepEqual(got.Policy.Statements[0].Action, want) {
+		t.Errorf("Minimize() actions = %v, want %v", got.Policy.Statements[0].Action, want)
+	}
+
+	if !sameExpansion(actions, got.Policy.Statements[0].Action) {
+		t.Errorf("Minimize() changed the allowed actions")
+	}
+
+	if got.Before != PolicySize(policy) || got.After != PolicySize(got.Policy) || got.After > got.Before {
+		t.Errorf("Minimize() sizes = %d -> %d", got.Before, got.After)
+	}
+}
+
+func TestMinimizeKeepsEveryCatalogueAction(t *testing.T) {
+	for service := range catalogue {
+		actions := ExpandAction(service + ":*")
+
+		// every other action, so that wildcards must stop short of the missing ones
+		var half []string
+		for i, action := range actions {
+			if i%2 == 0 {
+				half = append(half, action)
+			}
+		}
+
+		got := minimizeActions(half)
+		if !sameExpansion(half, got) {
+			t.Errorf("minimizeActions(%s) = %v, which allows different actions", service, got)
+		}
+
+		for _, action := range got {
+			if !strings.HasPrefix(action, service+":") {
+				t.Errorf("minimizeActions(%s) produced %s", service, action)
+			}
+
+			if strings.Contains(action, "*") && !completeService(service) {
+				t.Errorf("minimizeActions(%s) produced the wildcard %s for an incomplete service", service, action)
+			}
+		}
+	}
+}
+
+// TestMinimizeEveryServiceAsComplete runs the prefix search over every catalogued service, as if each were
+// complete, and checks that the result allows the same actions and is never longer.
+func TestMinimizeEveryServiceAsComplete(t *testing.T) {
+	for service := range catalogue {
+		if completeService(service) {
+			continue
+		}
+
+		completeServices[service] = true
+
+		actions := ExpandAction(service + ":*")
+
+		var half []string
+		for i, action := range actions {
+			if i%3 != 0 {
+				half = append(half, action)
+			}
+		}
+
+		got := minimizeActions(half)
+		if !sameExpansion(half, got) {
+			t.Errorf("minimizeActions(%s) = %v, which allows different actions", service, got)
+		}
+
+		if len(got) > len(half) {
+			t.Errorf("minimizeActions(%s) = %d actions, want at most %d", service, len(got), len(half))
+		}
+
+		delete(completeServices, service)
+	}
+}
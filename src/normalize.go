@@ -0,0 +1,186 @@
+package Identity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Normalize returns the canonical form of a policy, so that policies that mean the same thing compare,
+// diff and hash the same. Service prefixes are lower-cased and known actions take their catalogue
+// casing; every list is sorted and de-duplicated; statements with the same effect, principals,
+// resources and conditions are merged; statements are sorted; and Sids, which carry no meaning, are
+// dropped. The policy Id is kept, as it is the document's own identifier. The policy passed in is not changed.
+func Normalize(policy Policy) Policy {
+	normal := NewPolicy()
+	normal.Version = policy.Version
+	normal.ID = policy.ID
+	normal.Source = policy.Source
+
+	merged := map[string]int{}
+
+	for _, statement := range policy.Statements {
+		canonical := Statement{
+			Effect:       statement.Effect,
+			Principal:    normalizePrincipal(statement.Principal),
+			NotPrincipal: normalizePrincipal(statement.NotPrincipal),
+			Action:       normalizeActions(statement.Action),
+			NotAction:    normalizeActions(statement.NotAction),
+			Resource:     uniqueSortedOrNil(statement.Resource),
+			NotResource:  uniqueSortedOrNil(statement.NotResource),
+			Condition:    normalizeCondition(statement.Condition),
+		}
+
+		// a union of NotAction lists would allow less, not more, so only Action statements merge
+		if canonical.NotAction == nil {
+			key := statementKey(canonical)
+
+			if index, ok := merged[key]; ok {
+				normal.Statements[index].Action = normalizeActions(append(normal.Statements[index].Action, canonical.Action...))
+
+				continue
+			}
+
+			merged[key] = len(normal.Statements)
+		}
+
+		normal.Statements = append(normal.Statements, canonical)
+	}
+
+	sort.SliceStable(normal.Statements, func(i, j int) bool {
+		a, b := normal.Statements[i], normal.Statements[j]
+
+		if a.Effect != b.Effect {
+			return a.Effect < b.Effect
+		}
+
+		actionsA := strings.Join(a.Action, ",") + strings.Join(a.NotAction, ",")
+		actionsB := strings.Join(b.Action, ",") + strings.Join(b.NotAction, ",")
+		if actionsA != actionsB {
+			return actionsA < actionsB
+		}
+
+		return statementKey(a) < statementKey(b)
+	})
+
+	return normal
+}
+
+// CanonicalJSON encodes the canonical form of a policy. The encoding is stable: equal policies give equal bytes.
+func CanonicalJSON(policy Policy) ([]byte, error) {
+	return json.Marshal(Normalize(policy))
+}
+
+// Fingerprint returns the SHA-256 of a policy's canonical JSON, as hex.
+func Fingerprint(policy Policy) (string, error) {
+	raw, err := CanonicalJSON(policy)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(raw)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// statementKey identifies what a statement applies to, leaving out its actions.
+func statementKey(statement Statement) string {
+	raw, _ := json.Marshal(Statement{
+		Effect:       statement.Effect,
+		Principal:    statement.Principal,
+		NotPrincipal: statement.NotPrincipal,
+		NotAction:    statement.NotAction,
+		Resource:     statement.Resource,
+		NotResource:  statement.NotResource,
+		Condition:    statement.Condition,
+	})
+
+	return string(raw)
+}
+
+// normalizeActions canonicalises, sorts and de-duplicates actions, ignoring case.
+func normalizeActions(actions []string) []string {
+	if actions == nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	result := []string{}
+
+	for _, action := range actions {
+		canonical := canonicalAction(action)
+
+		if key := strings.ToLower(canonical); !seen[key] {
+			seen[key] = true
+			result = append(result, canonical)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// canonicalAction lower-cases the service prefix of an action and gives a known action its catalogue casing.
+func canonicalAction(action string) string {
+	service, name := SplitAction(action)
+	if service == "" {
+		return action
+	}
+
+	if !strings.ContainsAny(name, "*?") {
+		for known := range catalogue[service] {
+			if strings.EqualFold(known, name) {
+				return service + ":" + known
+			}
+		}
+	}
+
+	return service + ":" + name
+}
+
+func normalizePrincipal(principal Principal) Principal {
+	if principal == nil {
+		return nil
+	}
+
+	normal := Principal{}
+	for kind, identifiers := range principal {
+		normal[kind] = uniqueSortedOrNil(identifiers)
+	}
+
+	return normal
+}
+
+func normalizeCondition(condition Condition) Condition {
+	if len(condition) == 0 {
+		return nil
+	}
+
+	normal := Condition{}
+
+	for operator, keys := range condition {
+		normal[operator] = map[string][]string{}
+
+		for key, values := range keys {
+			normal[operator][key] = uniqueSortedOrNil(values)
+		}
+	}
+
+	return normal
+}
+
+// uniqueSortedOrNil sorts and de-duplicates values, keeping a nil list nil so that absent fields stay absent.
+func uniqueSortedOrNil(values []string) []string {
+	if values == nil {
+		return nil
+	}
+
+	if unique := uniqueSorted(values); unique != nil {
+		return unique
+	}
+
+	return []string{}
+}
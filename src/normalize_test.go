@@ -0,0 +1,71 @@
+package Identity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	policy := Policy{
+		Version: "2012-10-17",
+		ID:      "ReadBuckets",
+		Statements: []Statement{
+			{Sid: "Deny", Effect: "Deny", Action: []string{"S3:DeleteBucket"}, Resource: []string{"*"}},
+			{Sid: "Read", Effect: "Allow", Action: []string{"s3:getobject", "S3:List*"}, Resource: []string{"arn:aws:s3:::b/*", "arn:aws:s3:::a/*"}},
+			{Effect: "Allow", Action: []string{"s3:GetObject", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::a/*", "arn:aws:s3:::b/*", "arn:aws:s3:::a/*"}},
+			{Effect: "Allow", NotAction: []string{"iam:*"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"*"},
+				Condition: Condition{"StringEquals": {"aws:SourceVpc": {"vpc-2", "vpc-1", "vpc-2"}}}},
+		},
+	}
+
+	want := Policy{
+		Version: "2012-10-17",
+		ID:      "ReadBuckets",
+		Statements: []Statement{
+			{Effect: "Allow", NotAction: []string{"iam:*"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"s3:GetObject", "s3:List*", "s3:PutObject"}, Resource: []string{"arn:aws:s3:::a/*", "arn:aws:s3:::b/*"}},
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"*"},
+				Condition: Condition{"StringEquals": {"aws:SourceVpc": {"vpc-1", "vpc-2"}}}},
+			{Effect: "Deny", Action: []string{"s3:DeleteBucket"}, Resource: []string{"*"}},
+		},
+	}
+
+	got := Normalize(policy)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize() = %+v, want %+v", got, want)
+	}
+
+	if again := Normalize(got); !reflect.DeepEqual(again, got) {
+		t.Errorf("Normalize() is not idempotent: %+v", again)
+	}
+
+	if policy.Statements[0].Action[0] != "S3:DeleteBucket" {
+		t.Errorf("Normalize() changed its input")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a, _ := Parse(`{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}}`)
+	b, _ := Parse(`{"Version": "2012-10-17", "Statement": [
+		{"Sid": "One", "Effect": "Allow", "Action": ["S3:GETOBJECT"], "Resource": ["*"]},
+		{"Sid": "Two", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}
+	]}`)
+	c, _ := Parse(`{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Action": "s3:PutObject", "Resource": "*"}}`)
+
+	fingerprintA, err := Fingerprint(a)
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+
+	fingerprintB, _ := Fingerprint(b)
+	fingerprintC, _ := Fingerprint(c)
+
+	if fingerprintA != fingerprintB {
+		t.Errorf("Fingerprint() differs for equivalent policies: %s and %s", fingerprintA, fingerprintB)
+	}
+
+	if fingerprintA == fingerprintC {
+		t.Errorf("Fingerprint() is the same for different policies")
+	}
+}
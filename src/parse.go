@@ -1,25 +1,102 @@
 package Identity
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"reflect"
+	"sort"
+	"strings"
 )
 
 type EmptyParseError struct{}
 
 const (
-	UserType       = "user"
-	GroupType      = "group"
-	RoleType       = "role"
-	VersionField   = "Version"
-	StatementField = "Statement"
-	SidField       = "Sid"
-	EffectField    = "Effect"
-	ResourceField  = "Resource"
-	ActionField    = "Action"
+	UserType           = "user"
+	GroupType          = "group"
+	RoleType           = "role"
+	InlineKind         = "inline"
+	ManagedKind        = "managed"
+	BoundaryKind       = "boundary"
+	DocumentKind       = "document"
+	VersionField       = "Version"
+	IDField            = "Id"
+	StatementField     = "Statement"
+	SidField           = "Sid"
+	EffectField        = "Effect"
+	PrincipalField     = "Principal"
+	NotPrincipalField  = "NotPrincipal"
+	ResourceField      = "Resource"
+	NotResourceField   = "NotResource"
+	ActionField        = "Action"
+	NotActionField     = "NotAction"
+	ConditionField     = "Condition"
+	anonymousPrincipal = "*"
 )
 
+// policyFields and statementFields are the keys the policy language allows.
+var (
+	policyFields    = []string{VersionField, IDField, StatementField}
+	statementFields = []string{
+		SidField, EffectField, PrincipalField, NotPrincipalField, ActionField, NotActionField,
+		ResourceField, NotResourceField, ConditionField,
+	}
+)
+
+// ParseOptions controls how a policy document is read and how strictly it is checked.
+type ParseOptions struct {
+	// Strict rejects unknown keys and keys in the wrong case. Effect values other than Allow and Deny
+	// are rejected either way.
+	Strict bool
+	// Format is JSONFormat, the default, YAMLFormat, HCLFormat or AutoFormat to detect it.
+	Format string
+}
+
+// ParseError is one problem with a policy document. Statement is the index of the statement, or -1
+// when the problem is outside any statement, Path is the JSON path of the field, such as
+// Statement[1].Effect, and Offset is the byte offset of its value, or -1 when it is missing.
+type ParseError struct {
+	Statement int
+	Path      string
+	Offset    int64
+	Expected  string
+	Actual    string
+}
+
+func (e *ParseError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "policy"
+	}
+
+	if e.Offset >= 0 {
+		path = fmt.Sprintf("%s (offset %d)", path, e.Offset)
+	}
+
+	return fmt.Sprintf("%s: expected %s, got %s", path, e.Expected, e.Actual)
+}
+
+// ParseErrors is every problem found in a policy document, in document order.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap lets errors.As find each *ParseError.
+func (e ParseErrors) Unwrap() []error {
+	unwrapped := make([]error, 0, len(e))
+	for _, err := range e {
+		unwrapped = append(unwrapped, err)
+	}
+
+	return unwrapped
+}
+
 func NewPolicy() Policy {
 	return Policy{
 		Version:    "",
@@ -31,78 +108,400 @@ func (m *EmptyParseError) Error() string {
 	return "cannot parse such empty"
 }
 
+// Parse parses a policy document. Every problem found is returned, as ParseErrors.
 func Parse(raw string) (Policy, error) {
+	return ParseWithOptions(raw, ParseOptions{})
+}
+
+// ParseStrict parses a policy document in strict mode.
+func ParseStrict(raw string) (Policy, error) {
+	return ParseWithOptions(raw, ParseOptions{Strict: true})
+}
+
+// ParseWithOptions parses a policy document, collecting every problem rather than stopping at the first.
+func ParseWithOptions(raw string, options ParseOptions) (Policy, error) {
 	if raw == "" {
 		return NewPolicy(), &EmptyParseError{}
 	}
 
-	var aJSON map[string]interface{}
-
-	err := json.Unmarshal([]byte(raw), &aJSON)
-
+	document, offsets, err := decodeDocument(raw, options.Format)
 	if err != nil {
 		return NewPolicy(), err
 	}
 
-	var myPolicy Policy
+	parser := policyParser{options: options, offsets: offsets}
+	policy := parser.policy(document)
+
+	if len(parser.errors) > 0 {
+		sort.SliceStable(parser.errors, func(i, j int) bool {
+			return parser.errors[i].Offset < parser.errors[j].Offset
+		})
+
+		return NewPolicy(), parser.errors
+	}
+
+	return policy, nil
+}
+
+type policyParser struct {
+	options ParseOptions
+	offsets map[string]int64
+	errors  ParseErrors
+}
+
+func (p *policyParser) fail(statement int, path string, expected string, actual string) {
+	offset, ok := p.offsets[path]
+	if !ok {
+		offset = -1
+	}
+
+	p.errors = append(p.errors, &ParseError{Statement: statement, Path: path, Offset: offset, Expected: expected, Actual: actual})
+}
+
+func (p *policyParser) policy(document interface{}) Policy {
+	fields, ok := document.(map[string]interface{})
+	if !ok {
+		p.fail(-1, "", "object", jsonType(document))
+
+		return NewPolicy()
+	}
+
+	p.checkKeys(-1, "", fields, policyFields)
+
+	policy := NewPolicy()
 
-	if version, ok := aJSON[VersionField].(string); ok {
-		myPolicy.Version = version
+	if version, ok := fields[VersionField].(string); ok {
+		policy.Version = version
 	} else {
-		return NewPolicy(), fmt.Errorf("invalid Version format")
+		p.fail(-1, VersionField, "string", jsonType(fields[VersionField]))
 	}
 
-	if statements, ok := aJSON[StatementField].([]interface{}); ok {
-		for _, statement := range statements {
-			myPolicy, err = parseIamStatement(statement, myPolicy)
+	if _, ok := fields[IDField]; ok {
+		policy.ID = p.str(-1, IDField, fields[IDField])
+	}
+
+	switch statements := fields[StatementField].(type) {
+	case []interface{}:
+		for index, statement := range statements {
+			policy.Statements = append(policy.Statements, p.statement(index, fmt.Sprintf("%s[%d]", StatementField, index), statement))
 		}
-	} else {
-		myPolicy, err = parseIamStatement(aJSON[StatementField], myPolicy)
+	case map[string]interface{}:
+		policy.Statements = append(policy.Statements, p.statement(0, StatementField, statements))
+		policy.loneStatement = true
+	default:
+		p.fail(-1, StatementField, "object or array", jsonType(statements))
 	}
 
-	return myPolicy, err
+	return policy
 }
 
-func parseIamStatement(statement interface{}, myPolicy Policy) (Policy, error) {
-	myStatement := Statement{}
+func (p *policyParser) statement(index int, path string, raw interface{}) Statement {
+	var statement Statement
 
-	if sid, ok := statement.(map[string]interface{})[SidField].(string); ok {
-		myStatement.Sid = sid
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		p.fail(index, path, "object", jsonType(raw))
+
+		return statement
 	}
 
-	if effect, ok := statement.(map[string]interface{})[EffectField].(string); ok {
-		myStatement.Effect = effect
-	} else {
-		return NewPolicy(), fmt.Errorf("invalid Effect format")
+	p.checkKeys(index, path, fields, statementFields)
+
+	if _, ok := fields[SidField]; ok {
+		statement.Sid = p.str(index, path+"."+SidField, fields[SidField])
+	}
+
+	// any other Effect, even "deny", would be taken as Allow, so it is rejected whether or not parsing is strict
+	statement.Effect = p.str(index, path+"."+EffectField, fields[EffectField])
+	if _, ok := fields[EffectField].(string); ok && statement.Effect != Allow && statement.Effect != Deny {
+		p.fail(index, path+"."+EffectField, `"Allow" or "Deny"`, fmt.Sprintf("%q", statement.Effect))
+	}
+
+	statement.Principal = p.principal(index, path, PrincipalField, fields)
+	statement.NotPrincipal = p.principal(index, path, NotPrincipalField, fields)
+	statement.Action, statement.NotAction = p.either(index, path, ActionField, NotActionField, fields, true)
+
+	hasPrincipal := statement.Principal != nil || statement.NotPrincipal != nil
+	statement.Resource, statement.NotResource = p.either(index, path, ResourceField, NotResourceField, fields, !hasPrincipal)
+
+	if raw, ok := fields[ConditionField]; ok {
+		statement.Condition = p.condition(index, path+"."+ConditionField, raw)
 	}
 
-	rawResource := statement.(map[string]interface{})[ResourceField]
+	statement.recordLists(fields)
 
-	if isSlice(rawResource) {
-		for _, v := range rawResource.([]interface{}) {
-			myStatement.Resource = append(myStatement.Resource, v.(string))
+	return statement
+}
+
+// recordLists notes the fields whose one value is written as a list, so that MarshalJSON writes them back
+// the same way.
+func (s *Statement) recordLists(fields map[string]interface{}) {
+	record := func(field string, raw interface{}) {
+		if items, ok := raw.([]interface{}); ok && len(items) == 1 {
+			if s.lists == nil {
+				s.lists = map[string]bool{}
+			}
+
+			s.lists[field] = true
 		}
-	} else {
-		myStatement.Resource = append(myStatement.Resource, rawResource.(string))
 	}
 
-	if isSlice(statement.(map[string]interface{})[ActionField]) {
-		for _, v := range statement.(map[string]interface{})[ActionField].([]interface{}) {
-			myStatement.Action = append(myStatement.Action, v.(string))
+	for _, field := range []string{ActionField, NotActionField, ResourceField, NotResourceField} {
+		record(field, fields[field])
+	}
+
+	for _, field := range []string{PrincipalField, NotPrincipalField} {
+		if kinds, ok := fields[field].(map[string]interface{}); ok {
+			for kind, raw := range kinds {
+				record(field+"."+kind, raw)
+			}
 		}
-	} else {
-		myStatement.Action = append(myStatement.Action, statement.(map[string]interface{})[ActionField].(string))
 	}
 
-	myPolicy.Statements = append(myPolicy.Statements, myStatement)
+	if operators, ok := fields[ConditionField].(map[string]interface{}); ok {
+		for operator, raw := range operators {
+			if keys, ok := raw.(map[string]interface{}); ok {
+				for key, value := range keys {
+					record(ConditionField+"."+operator+"."+key, value)
+				}
+			}
+		}
+	}
+}
+
+// either reads a pair such as Action and NotAction, of which a statement may have only one.
+func (p *policyParser) either(index int, path string, field string, notField string, fields map[string]interface{}, required bool) ([]string, []string) {
+	raw, has := fields[field]
+	notRaw, hasNot := fields[notField]
+
+	switch {
+	case has && hasNot:
+		p.fail(index, path+"."+notField, field+" or "+notField, "both")
+	case !has && !hasNot && required:
+		p.fail(index, path+"."+field, "string or array of strings", jsonType(nil))
+	}
+
+	var values, notValues []string
+
+	if has {
+		values = p.strings(index, path+"."+field, raw)
+	}
+
+	if hasNot {
+		notValues = p.strings(index, path+"."+notField, notRaw)
+	}
+
+	return values, notValues
+}
+
+func (p *policyParser) principal(index int, path string, field string, fields map[string]interface{}) Principal {
+	raw, ok := fields[field]
+	if !ok {
+		return nil
+	}
+
+	path += "." + field
+
+	if raw == anonymousPrincipal {
+		return Principal{anonymousPrincipal: {anonymousPrincipal}}
+	}
+
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		p.fail(index, path, `"*" or object`, jsonType(raw))
+
+		return nil
+	}
+
+	principal := Principal{}
+	for _, kind := range sortedKeys(object) {
+		principal[kind] = p.strings(index, path+"."+kind, object[kind])
+	}
+
+	return principal
+}
+
+// condition flattens condition values, which AWS allows as strings, numbers, booleans or lists, into strings.
+func (p *policyParser) condition(index int, path string, raw interface{}) Condition {
+	operators, ok := raw.(map[string]interface{})
+	if !ok {
+		p.fail(index, path, "object", jsonType(raw))
+
+		return nil
+	}
+
+	condition := Condition{}
+
+	for _, operator := range sortedKeys(operators) {
+		keys, ok := operators[operator].(map[string]interface{})
+		if !ok {
+			p.fail(index, path+"."+operator, "object", jsonType(operators[operator]))
+
+			continue
+		}
+
+		condition[operator] = map[string][]string{}
+
+		for _, key := range sortedKeys(keys) {
+			values, isList := keys[key].([]interface{})
+			if !isList {
+				values = []interface{}{keys[key]}
+			}
+
+			for i, value := range values {
+				switch value.(type) {
+				case string, float64, bool:
+					condition[operator][key] = append(condition[operator][key], fmt.Sprint(value))
+				default:
+					valuePath := path + "." + operator + "." + key
+					if isList {
+						valuePath = fmt.Sprintf("%s[%d]", valuePath, i)
+					}
+
+					p.fail(index, valuePath, "string, number or boolean", jsonType(value))
+				}
+			}
+		}
+	}
+
+	return condition
+}
+
+func (p *policyParser) str(index int, path string, raw interface{}) string {
+	value, ok := raw.(string)
+	if !ok {
+		p.fail(index, path, "string", jsonType(raw))
+	}
+
+	return value
+}
+
+// strings reads a field that AWS allows as a single string or a list of strings.
+func (p *policyParser) strings(index int, path string, raw interface{}) []string {
+	if value, ok := raw.(string); ok {
+		return []string{value}
+	}
+
+	items, ok := raw.([]interface{})
+	if !ok {
+		p.fail(index, path, "string or array of strings", jsonType(raw))
+
+		return nil
+	}
+
+	values := make([]string, 0, len(items))
 
-	return myPolicy, nil
+	for i, item := range items {
+		values = append(values, p.str(index, fmt.Sprintf("%s[%d]", path, i), item))
+	}
+
+	return values
 }
 
-func isSlice(arr interface{}) bool {
-	// Get the type of the variable using reflection
-	t := reflect.TypeOf(arr)
+// checkKeys rejects, in strict mode, keys that are not allowed or are in the wrong case.
+func (p *policyParser) checkKeys(index int, path string, fields map[string]interface{}, allowed []string) {
+	if !p.options.Strict {
+		return
+	}
+
+	for _, key := range sortedKeys(fields) {
+		expected := "one of " + strings.Join(allowed, ", ")
+
+		known := false
+		for _, name := range allowed {
+			if key == name {
+				known = true
+			} else if strings.EqualFold(key, name) {
+				expected = name
+			}
+		}
+
+		if !known {
+			p.fail(index, joinPath(path, key), expected, fmt.Sprintf("key %q", key))
+		}
+	}
+}
+
+// jsonType names the JSON type of a decoded value, or "nothing" when the value is missing.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// jsonOffsets maps the JSON path of every value in a document to the byte offset where the value starts.
+func jsonOffsets(raw []byte) map[string]int64 {
+	offsets := map[string]int64{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+
+	var walk func(path string) error
+
+	walk = func(path string) error {
+		offsets[path] = valueStart(raw, decoder.InputOffset())
+
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+
+				if err := walk(joinPath(path, fmt.Sprint(key))); err != nil {
+					return err
+				}
+			}
+
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+
+			_, err = decoder.Token()
+		}
+
+		return err
+	}
+
+	_ = walk("")
+
+	return offsets
+}
+
+// valueStart skips the whitespace and separators between the end of one token and the next value.
+func valueStart(raw []byte, offset int64) int64 {
+	for offset < int64(len(raw)) && strings.IndexByte(" \t\r\n,:", raw[offset]) >= 0 {
+		offset++
+	}
 
-	// Check if the type is an array
-	return t.Kind() == reflect.Slice
+	return offset
 }
@@ -1,6 +1,10 @@
 package Identity
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -15,7 +19,7 @@ func TestParse(t *testing.T) {
 		want Policy
 	}{
 		{"pass", args{"{\n    \"Version\": \"2012-10-17\",\n    \"Statement\": [\n        {\n            \"Effect\": \"Allow\",\n            \"Action\": [\"s3:*\",\"s3-object-lambda:*\"],\n            \"Resource\": [\"*\"]\n        }\n    ]\n}"},
-			Policy{Version: "2012-10-17", Statements: []Statement{{Sid: "", Effect: "Allow", Action: []string{"s3:*", "s3-object-lambda:*"}, Resource: []string{"*"}}}}},
+			Policy{Version: "2012-10-17", Statements: []Statement{{Sid: "", Effect: "Allow", Action: []string{"s3:*", "s3-object-lambda:*"}, Resource: []string{"*"}, lists: map[string]bool{ResourceField: true}}}}},
 		{"fail", args{"guff"}, NewPolicy()},
 	}
 	for _, tt := range tests {
@@ -27,27 +31,6 @@ func TestParse(t *testing.T) {
 	}
 }
 
-func Test_isSlice(t *testing.T) {
-	type args struct {
-		arr interface{}
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{"array", args{[]string{"1", "2", "3", "4", "5"}}, true},
-		{"string", args{"a"}, false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := isSlice(tt.args.arr); got != tt.want {
-				t.Errorf("isSlice() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestEmptyParseError_Error(t *testing.T) {
 	tests := []struct {
 		name string
@@ -120,6 +103,7 @@ func TestParseEdgeCases(t *testing.T) {
 					Action:   []string{"s3:*"},
 					Resource: []string{"*"},
 				}},
+				loneStatement: true,
 			},
 			wantErr: false,
 		},
@@ -139,11 +123,13 @@ func TestParseEdgeCases(t *testing.T) {
 						Effect:   "Allow",
 						Action:   []string{"s3:Get*"},
 						Resource: []string{"arn:aws:s3:::bucket/*"},
+						lists:    map[string]bool{ActionField: true, ResourceField: true},
 					},
 					{
 						Effect:   "Deny",
 						Action:   []string{"s3:Delete*"},
 						Resource: []string{"*"},
+						lists:    map[string]bool{ActionField: true, ResourceField: true},
 					},
 				},
 			},
@@ -167,6 +153,32 @@ func TestParseEdgeCases(t *testing.T) {
 					Effect:   "Allow",
 					Action:   []string{"s3:*"},
 					Resource: []string{"*"},
+					lists:    map[string]bool{ActionField: true, ResourceField: true},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with_condition",
+			args: args{raw: `{
+				"Version": "2012-10-17",
+				"Statement": [{
+					"Effect": "Allow",
+					"Action": "s3:GetObject",
+					"Resource": "*",
+					"Condition": {"Bool": {"aws:SecureTransport": true}, "StringEquals": {"aws:PrincipalTag/team": ["a", "b"]}}
+				}]
+			}`},
+			want: Policy{
+				Version: "2012-10-17",
+				Statements: []Statement{{
+					Effect:   "Allow",
+					Action:   []string{"s3:GetObject"},
+					Resource: []string{"*"},
+					Condition: Condition{
+						"Bool":         {"aws:SecureTransport": {"true"}},
+						"StringEquals": {"aws:PrincipalTag/team": {"a", "b"}},
+					},
 				}},
 			},
 			wantErr: false,
@@ -185,3 +197,215 @@ func TestParseEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		strict bool
+		want   []ParseError
+	}{
+		{
+			name: "collects_every_problem",
+			raw:  `{"Version": 1, "Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", 5], "Resource": "*"}, "x"]}`,
+			want: []ParseError{
+				{Statement: -1, Path: "Version", Offset: 12, Expected: "string", Actual: "number"},
+				{Statement: 0, Path: "Statement[0].Action[1]", Offset: 76, Expected: "string", Actual: "number"},
+				{Statement: 1, Path: "Statement[1]", Offset: 98, Expected: "object", Actual: "string"},
+			},
+		},
+		{
+			name: "missing_action",
+			raw:  `{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Resource": "*"}}`,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement.Action", Offset: -1, Expected: "string or array of strings", Actual: "nothing"},
+			},
+		},
+		{
+			name: "action_and_not_action",
+			raw:  `{"Version": "2012-10-17", "Statement": {"Effect": "Deny", "Action": "s3:*", "NotAction": "s3:Get*", "Resource": "*"}}`,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement.NotAction", Offset: 89, Expected: "Action or NotAction", Actual: "both"},
+			},
+		},
+		{
+			name:   "strict",
+			raw:    `{"Version": "2012-10-17", "Statement": [{"effect": "Allow", "Effect": "Permit", "Action": "s3:*", "Resource": "*", "Comment": "x"}]}`,
+			strict: true,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement[0].effect", Offset: 51, Expected: "Effect", Actual: `key "effect"`},
+				{Statement: 0, Path: "Statement[0].Effect", Offset: 70, Expected: `"Allow" or "Deny"`, Actual: `"Permit"`},
+				{Statement: 0, Path: "Statement[0].Comment", Offset: 126, Expected: "one of Sid, Effect, Principal, NotPrincipal, Action, NotAction, Resource, NotResource, Condition", Actual: `key "Comment"`},
+			},
+		},
+		{
+			name: "lenient",
+			raw:  `{"Version": "2012-10-17", "Statement": [{"effect": "Allow", "Effect": "Deny", "Action": "s3:*", "Resource": "*", "Comment": "x"}]}`,
+		},
+		{
+			name: "lenient_effect",
+			raw:  `{"Version": "2012-10-17", "Statement": [{"Effect": "deny", "Action": "s3:*", "Resource": "*"}, {"Effect": "Alow", "Action": "s3:*", "Resource": "*"}]}`,
+			want: []ParseError{
+				{Statement: 0, Path: "Statement[0].Effect", Offset: 51, Expected: `"Allow" or "Deny"`, Actual: `"deny"`},
+				{Statement: 1, Path: "Statement[1].Effect", Offset: 106, Expected: `"Allow" or "Deny"`, Actual: `"Alow"`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWithOptions(tt.raw, ParseOptions{Strict: tt.strict})
+
+			var got []ParseError
+
+			var parseErrors ParseErrors
+			if errors.As(err, &parseErrors) {
+				for _, parseError := range parseErrors {
+					got = append(got, *parseError)
+				}
+			} else if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v, want ParseErrors", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWithOptions() errors = %+v, want %+v", got, tt.want)
+			}
+
+			var parseError *ParseError
+			if len(tt.want) > 0 && !errors.As(err, &parseError) {
+				t.Errorf("errors.As() did not find a *ParseError in %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePrincipalsAndNegations(t *testing.T) {
+	raw := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Principal": {"Service": "lambda.amazonaws.com"}, "Action": "sts:AssumeRole"},
+			{"Effect": "Deny", "NotPrincipal": "*", "NotAction": ["iam:*"], "NotResource": "arn:aws:s3:::logs/*"}
+		]
+	}`
+
+	want := Policy{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Effect: "Allow", Principal: Principal{"Service": {"lambda.amazonaws.com"}}, Action: []string{"sts:AssumeRole"}},
+			{Effect: "Deny", NotPrincipal: Principal{"*": {"*"}}, NotAction: []string{"iam:*"}, NotResource: []string{"arn:aws:s3:::logs/*"},
+				lists: map[string]bool{NotActionField: true}},
+		},
+	}
+
+	got, err := ParseStrict(raw)
+	if err != nil {
+		t.Fatalf("ParseStrict() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStrict() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseCorpus(t *testing.T) {
+	// wantErr lists the hand-written documents in testdata/policies and whether Parse rejects them
+	wantErr := map[string]bool{
+		"condition-shapes.json":     true,
+		"deeply-nested.json":        true,
+		"duplicate-keys.json":       false,
+		"empty-statement-list.json": false,
+		"lowercase-keys.json":       true,
+		"missing-action.json":       true,
+		"nested-lists.json":         true,
+		"not-everything.json":       false,
+		"null-values.json":          true,
+		"numeric-values.json":       true,
+		"principal-shapes.json":     true,
+		"statement-number.json":     true,
+		"statement-string.json":     true,
+		"top-level-array.json":      true,
+		"trailing-garbage.json":     true,
+		"truncated.json":            true,
+		"unicode.json":              false,
+	}
+
+	paths, _ := filepath.Glob(filepath.Join("testdata", "policies", "*.json"))
+	managed, _ := filepath.Glob(filepath.Join("testdata", "managed", "*.json"))
+
+	if len(paths) != len(wantErr) {
+		t.Errorf("testdata/policies has %d documents, want %d", len(paths), len(wantErr))
+	}
+
+	for _, path := range append(paths, managed...) {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Parse(string(raw))
+			if (err != nil) != wantErr[filepath.Base(path)] {
+				t.Errorf("Parse() error = %v, wantErr %v", err, wantErr[filepath.Base(path)])
+			}
+
+			var parseErrors ParseErrors
+			if err != nil && !errors.As(err, &parseErrors) {
+				t.Errorf("Parse() error = %v, want ParseErrors", err)
+			}
+		})
+	}
+}
+
+// FuzzParse checks that parsing any input, in any format, returns a policy or typed errors and never
+// panics, and that a parsed policy marshals to JSON that parses back to the same bytes.
+func FuzzParse(f *testing.F) {
+	for _, pattern := range []string{"policies", "managed"} {
+		paths, _ := filepath.Glob(filepath.Join("testdata", pattern, "*.json"))
+
+		for _, path := range paths {
+			if raw, err := os.ReadFile(path); err == nil {
+				f.Add(string(raw))
+			}
+		}
+	}
+
+	f.Add("")
+	f.Add("guff")
+	f.Add("Version: 2012-10-17\nStatement:\n  - Effect: Allow\n    Action: !Sub '${AWS::Region}'\n")
+	f.Add(`{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Principal": {}, "Action": []}}`)
+	f.Add(`jsonencode({ Version = "2012-10-17", Statement = [{ Effect = "Allow", Action = "s3:*", Resource = var.arn }] })`)
+	f.Add(yamlAliasBomb())
+	f.Add("Statement: &s\n  - *s\n")
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		for _, format := range []string{JSONFormat, YAMLFormat, HCLFormat, AutoFormat} {
+			for _, strict := range []bool{false, true} {
+				policy, err := ParseWithOptions(raw, ParseOptions{Strict: strict, Format: format})
+				if err != nil {
+					var parseErrors ParseErrors
+					var emptyError *EmptyParseError
+
+					if !errors.As(err, &parseErrors) && !errors.As(err, &emptyError) {
+						t.Fatalf("ParseWithOptions(%s) error = %v, want ParseErrors", format, err)
+					}
+
+					continue
+				}
+
+				marshalled, err := json.Marshal(policy)
+				if err != nil {
+					t.Fatalf("json.Marshal() error = %v", err)
+				}
+
+				again, err := Parse(string(marshalled))
+				if err != nil {
+					t.Fatalf("Parse(%s) error = %v, want the marshalled policy to parse", marshalled, err)
+				}
+
+				if remarshalled, _ := json.Marshal(again); string(remarshalled) != string(marshalled) {
+					t.Fatalf("json.Marshal() = %s after a round trip, want %s", remarshalled, marshalled)
+				}
+			}
+		}
+	})
+}
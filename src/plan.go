@@ -0,0 +1,197 @@
+package Identity
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Terraform change actions as they appear in plan JSON.
+const (
+	CreateChange = "create"
+	ReadChange   = "read"
+	UpdateChange = "update"
+	DeleteChange = "delete"
+	NoOpChange   = "no-op"
+)
+
+//go:embed data/resources.json
+var rawResourceActions []byte
+
+// resourceActions maps a Terraform resource type to the IAM actions each kind of change needs.
+var resourceActions = loadResourceActions(rawResourceActions)
+
+func loadResourceActions(raw []byte) map[string]map[string][]string {
+	var actions map[string]map[string][]string
+
+	if err := json.Unmarshal(raw, &actions); err != nil {
+		panic("invalid embedded resource actions: " + err.Error())
+	}
+
+	return actions
+}
+
+// PlanGap is an IAM action a planned change needs that the identity is not allowed.
+type PlanGap struct {
+	Address  string `json:"Address"`
+	Change   string `json:"Change"`
+	Action   string `json:"Action"`
+	Resource string `json:"Resource"`
+}
+
+// PlanReport lists the missing permissions for a plan, and the resources whose needs are not known.
+type PlanReport struct {
+	Missing  []PlanGap `json:"Missing"`
+	Unmapped []string  `json:"Unmapped"`
+}
+
+type terraformPlan struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions []string               `json:"actions"`
+			Before  map[string]interface{} `json:"before"`
+			After   map[string]interface{} `json:"after"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// RequiredActions returns the IAM actions a change to a Terraform resource type needs, and
+// whether the type is known. Every change also needs the actions to refresh the resource.
+func RequiredActions(resourceType string, change string) ([]string, bool) {
+	needs, ok := resourceActions[resourceType]
+	if !ok {
+		return nil, false
+	}
+
+	actions := append([]string{}, needs[ReadChange]...)
+	if change != NoOpChange && change != ReadChange {
+		actions = append(actions, needs[change]...)
+	}
+
+	return uniqueSorted(actions), true
+}
+
+// CheckPlan reads `terraform show -json` output and reports the actions its changes need that
+// the identity does not have, linked to the address of the resource that needs them.
+func CheckPlan(path string, ident IAM) (PlanReport, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return PlanReport{}, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan terraformPlan
+
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return PlanReport{}, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+
+	report := PlanReport{Missing: []PlanGap{}, Unmapped: []string{}}
+	matrix := Effective(ident)
+
+	for _, resource := range plan.ResourceChanges {
+		target := resourceArn(resource.Change.After, resource.Change.Before)
+
+		for _, change := range resource.Change.Actions {
+			actions, known := RequiredActions(resource.Type, change)
+			if !known {
+				report.Unmapped = append(report.Unmapped, resource.Address)
+				break
+			}
+
+			for _, action := range actions {
+				if !matrix.AllowedOn(action, target) {
+					report.Missing = append(report.Missing, PlanGap{
+						Address:  resource.Address,
+						Change:   change,
+						Action:   action,
+						Resource: target,
+					})
+				}
+			}
+		}
+	}
+
+	report.Unmapped = uniqueSorted(report.Unmapped)
+	if report.Unmapped == nil {
+		report.Unmapped = []string{}
+	}
+
+	sort.SliceStable(report.Missing, func(i, j int) bool {
+		return report.Missing[i].Address < report.Missing[j].Address
+	})
+
+	return report, nil
+}
+
+// AllowedOn reports whether an action is allowed on a resource, ignoring conditions on allows
+// and treating conditional denies as not applying. A resource of * stands for one whose ARN is
+// not known yet, and is taken as allowed by any grant of the action.
+func (e EffectivePermissions) AllowedOn(action string, resource string) bool {
+	permission, ok := e.Lookup(action)
+	if !ok {
+		// a catalogue action no statement names is not allowed; any other may be, through a wildcard
+		if service, _ := SplitAction(action); completeService(service) || AccessLevel(action) != UnknownLevel {
+			return false
+		}
+
+		return e.allowedByPattern(action, resource)
+	}
+
+	for _, deny := range permission.Deny {
+		if len(deny.Condition) == 0 && grantCovers(deny, resource) {
+			return false
+		}
+	}
+
+	for _, allow := range permission.Allow {
+		if grantCovers(allow, resource) || (resource == "*" && len(allow.Resources) > 0) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedByPattern checks actions the catalogue does not list against the action patterns as written.
+func (e EffectivePermissions) allowedByPattern(action string, resource string) bool {
+	service, _ := SplitAction(action)
+
+	allowed := false
+
+	candidates := append(append([]Permission{}, e[service]...), e[""]...)
+
+	for _, permission := range candidates {
+		if !MatchAction(permission.Action, action) {
+			continue
+		}
+
+		for _, deny := range permission.Deny {
+			if len(deny.Condition) == 0 && grantCovers(deny, resource) {
+				return false
+			}
+		}
+
+		for _, allow := range permission.Allow {
+			if grantCovers(allow, resource) || resource == "*" {
+				allowed = true
+			}
+		}
+	}
+
+	return allowed
+}
+
+// resourceArn returns the ARN a plan knows for a resource, or * when it will only be known after apply.
+func resourceArn(states ...map[string]interface{}) string {
+	for _, state := range states {
+		if arn, ok := state["arn"].(string); ok && arn != "" {
+			return arn
+		}
+	}
+
+	return "*"
+}
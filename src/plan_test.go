@@ -0,0 +1,68 @@
+package Identity
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const planFixture = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "type": "aws_s3_bucket",
+     "change": {"actions": ["create"], "before": null, "after": {"bucket": "logs"}}},
+    {"address": "aws_sqs_queue.jobs", "type": "aws_sqs_queue",
+     "change": {"actions": ["no-op"], "before": {"arn": "arn:aws:sqs:eu-west-2:123456789012:jobs"}, "after": {"arn": "arn:aws:sqs:eu-west-2:123456789012:jobs"}}},
+    {"address": "aws_iam_role.app", "type": "aws_iam_role",
+     "change": {"actions": ["delete"], "before": {"arn": "arn:aws:iam::123456789012:role/app"}, "after": null}},
+    {"address": "aws_made_up.thing", "type": "aws_made_up",
+     "change": {"actions": ["create"], "before": null, "after": {}}}
+  ]
+}`
+
+func TestCheckPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(path, []byte(planFixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deploy := IAM{Name: "deploy", IamType: RoleType, Policies: []Policy{{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Effect: "Allow", Action: []string{"s3:*", "sqs:*"}, Resource: []string{"*"}},
+			{Effect: "Deny", Action: []string{"s3:PutBucketTagging"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"iam:Get*", "iam:List*"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"iam:DeleteRole"}, Resource: []string{"arn:aws:iam::123456789012:role/other"}},
+		},
+	}}}
+
+	want := PlanReport{
+		Missing: []PlanGap{
+			{Address: "aws_iam_role.app", Change: DeleteChange, Action: "iam:DeleteRole", Resource: "arn:aws:iam::123456789012:role/app"},
+			{Address: "aws_s3_bucket.logs", Change: CreateChange, Action: "s3:PutBucketTagging", Resource: "*"},
+		},
+		Unmapped: []string{"aws_made_up.thing"},
+	}
+
+	got, err := CheckPlan(path, deploy)
+	if err != nil {
+		t.Fatalf("CheckPlan() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckPlan() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResourceActionsInCatalogue(t *testing.T) {
+	for resourceType, changes := range resourceActions {
+		for change, actions := range changes {
+			for _, action := range actions {
+				if AccessLevel(action) == UnknownLevel {
+					t.Errorf("%s %s needs %s, which is not in the action catalogue", resourceType, change, action)
+				}
+			}
+		}
+	}
+}
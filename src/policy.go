@@ -2,18 +2,14 @@ package Identity
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/url"
 	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
-	"github.com/aws/aws-sdk-go-v2/service/iam/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/rs/zerolog/log"
 )
 
 const defaultProfile = "basic"
@@ -29,7 +25,7 @@ func GetAWSProfile() string {
 // getConfigWithAssumedRole returns an AWS config with assumed role credentials
 func getConfigWithAssumedRole(ctx context.Context, account IAM) (aws.Config, error) {
 	// Load base config with profile
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(GetAWSProfile()))
+	cfg, err := loadConfig(ctx)
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("failed to load config: %w", err)
 	}
@@ -48,171 +44,187 @@ func getConfigWithAssumedRole(ctx context.Context, account IAM) (aws.Config, err
 }
 
 func GetAttachedGroupPolicies(ctx context.Context, group IAM) (*iam.ListAttachedGroupPoliciesOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, group)
+	svc, err := NewIAMClient(ctx, group)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListAttachedGroupPoliciesInput{
 		GroupName: aws.String(group.Name),
 	}
 
-	result, err := svc.ListAttachedGroupPolicies(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("Exception type: NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("Exception type: ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Err(err)
+	result := &iam.ListAttachedGroupPoliciesOutput{}
+
+	paginator := iam.NewListAttachedGroupPoliciesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, group, "iam:ListAttachedGroupPolicies", group.Ref())
 		}
-		return nil, err
+
+		result.AttachedPolicies = append(result.AttachedPolicies, page.AttachedPolicies...)
 	}
 
 	return result, nil
 }
 
 func GetGroupPolicies(ctx context.Context, group IAM) (*iam.ListGroupPoliciesOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, group)
+	svc, err := NewIAMClient(ctx, group)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListGroupPoliciesInput{
 		GroupName: aws.String(group.Name),
 	}
 
-	result, err := svc.ListGroupPolicies(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("iam exception NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("iam exception ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Msg(err.Error())
+	result := &iam.ListGroupPoliciesOutput{}
+
+	paginator := iam.NewListGroupPoliciesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, group, "iam:ListGroupPolicies", group.Ref())
 		}
-		return nil, err
+
+		result.PolicyNames = append(result.PolicyNames, page.PolicyNames...)
 	}
 
 	return result, nil
 }
 
 func GetUserPolicies(ctx context.Context, user IAM) (*iam.ListUserPoliciesOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, user)
+	svc, err := NewIAMClient(ctx, user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListUserPoliciesInput{
 		UserName: aws.String(user.Name),
 	}
 
-	result, err := svc.ListUserPolicies(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("iam exception NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("iam exception ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Msgf("Please deploy the identity role %s", err)
+	result := &iam.ListUserPoliciesOutput{}
+
+	paginator := iam.NewListUserPoliciesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, user, "iam:ListUserPolicies", user.Ref())
 		}
-		return nil, err
+
+		result.PolicyNames = append(result.PolicyNames, page.PolicyNames...)
 	}
 
 	return result, nil
 }
 
 func GetAttachedUserPolicies(ctx context.Context, user IAM) (*iam.ListAttachedUserPoliciesOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, user)
+	svc, err := NewIAMClient(ctx, user)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListAttachedUserPoliciesInput{
 		UserName: aws.String(user.Name),
 	}
 
-	result, err := svc.ListAttachedUserPolicies(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("iam exception NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("iam exception ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Err(err)
+	result := &iam.ListAttachedUserPoliciesOutput{}
+
+	paginator := iam.NewListAttachedUserPoliciesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, user, "iam:ListAttachedUserPolicies", user.Ref())
 		}
-		return nil, err
+
+		result.AttachedPolicies = append(result.AttachedPolicies, page.AttachedPolicies...)
 	}
 
 	return result, nil
 }
 
+// GetPolicy returns the document of the default version of a managed policy, from the policy cache
+// when it holds that version.
 func GetPolicy(ctx context.Context, arn string, account IAM) (*string, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, account)
+	svc, err := NewIAMClient(ctx, account)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
+	cache := currentPolicyCache()
+
+	lookup, err := lookupPolicy(ctx, svc, cache, arn, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPolicyVersion(ctx, svc, cache, arn, lookup.policyID, lookup.versionID, account)
+}
+
+// lookupPolicy returns the ID and default version of a managed policy, asking IAM unless the cache
+// looked them up within lookupTTL.
+func lookupPolicy(ctx context.Context, svc IAMClient, cache *PolicyCache, arn string, account IAM) (policyLookup, error) {
+	if lookup, ok := cache.lookup(arn); ok {
+		return lookup, nil
+	}
 
 	result, err := svc.GetPolicy(ctx, &iam.GetPolicyInput{
 		PolicyArn: &arn,
 	})
 
 	if err != nil {
-		log.Error().Err(err)
-		return nil, fmt.Errorf("failed to get policies: %w", err)
+		return policyLookup{}, fmt.Errorf("failed to get policies: %w", apiError(err, account, "iam:GetPolicy", arn))
+	}
+
+	lookup := policyLookup{
+		policyID:  aws.ToString(result.Policy.PolicyId),
+		versionID: aws.ToString(result.Policy.DefaultVersionId),
+	}
+
+	cache.setLookup(arn, lookup)
+
+	return lookup, nil
+}
+
+// getPolicyVersion returns the document of a version of a managed policy, from the cache when it holds it.
+func getPolicyVersion(ctx context.Context, svc IAMClient, cache *PolicyCache, arn string, policyID string, versionID string, account IAM) (*string, error) {
+	if document, ok := cache.Get(arn, policyID, versionID); ok {
+		return &document, nil
 	}
 
 	version, err := svc.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
-		VersionId: result.Policy.DefaultVersionId,
-		PolicyArn: result.Policy.Arn,
+		VersionId: &versionID,
+		PolicyArn: &arn,
 	})
 
 	if err != nil {
-		log.Error().Err(err)
-		return nil, fmt.Errorf("failed to get policy version: %w", err)
+		return nil, fmt.Errorf("failed to get policy version: %w", apiError(err, account, "iam:GetPolicyVersion", arn))
 	}
 
 	temp, err := url.QueryUnescape(*version.PolicyVersion.Document)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unescape policy document: %w", err)
 	}
+
+	// A policy that cannot be cached is still returned
+	_ = cache.Put(arn, policyID, versionID, temp)
+
 	return &temp, nil
 }
 
 func GetUserPolicy(ctx context.Context, policy string, ident IAM) (*string, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, ident)
+	svc, err := NewIAMClient(ctx, ident)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	result, err := svc.GetUserPolicy(ctx, &iam.GetUserPolicyInput{
 		PolicyName: &policy,
 		UserName:   &ident.Name,
 	})
 
 	if err != nil {
-		log.Error().Err(err)
-		return nil, fmt.Errorf("failed to get user policies: %w", err)
+		return nil, fmt.Errorf("failed to get user policies: %w", apiError(err, ident, "iam:GetUserPolicy", ident.Ref()+":"+policy))
 	}
 
 	temp, err := url.QueryUnescape(*result.PolicyDocument)
@@ -223,21 +235,18 @@ func GetUserPolicy(ctx context.Context, policy string, ident IAM) (*string, erro
 }
 
 func GetRolePolicy(ctx context.Context, policy string, ident IAM) (*string, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, ident)
+	svc, err := NewIAMClient(ctx, ident)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	result, err := svc.GetRolePolicy(ctx, &iam.GetRolePolicyInput{
 		PolicyName: &policy,
 		RoleName:   &ident.Name,
 	})
 
 	if err != nil {
-		log.Error().Err(err)
-		return nil, fmt.Errorf("failed to get role policies: %w", err)
+		return nil, fmt.Errorf("failed to get role policies: %w", apiError(err, ident, "iam:GetRolePolicy", ident.Ref()+":"+policy))
 	}
 
 	temp, err := url.QueryUnescape(*result.PolicyDocument)
@@ -248,21 +257,18 @@ func GetRolePolicy(ctx context.Context, policy string, ident IAM) (*string, erro
 }
 
 func GetGroupPolicy(ctx context.Context, policy string, group IAM) (*string, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, group)
+	svc, err := NewIAMClient(ctx, group)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	result, err := svc.GetGroupPolicy(ctx, &iam.GetGroupPolicyInput{
 		PolicyName: &policy,
 		GroupName:  &group.Name,
 	})
 
 	if err != nil {
-		log.Error().Err(err)
-		return nil, fmt.Errorf("failed to get group policies: %w", err)
+		return nil, fmt.Errorf("failed to get group policies: %w", apiError(err, group, "iam:GetGroupPolicy", group.Ref()+":"+policy))
 	}
 
 	temp, err := url.QueryUnescape(*result.PolicyDocument)
@@ -273,88 +279,108 @@ func GetGroupPolicy(ctx context.Context, policy string, group IAM) (*string, err
 }
 
 func GetRolePolicies(ctx context.Context, ident IAM) (*iam.ListRolePoliciesOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, ident)
+	svc, err := NewIAMClient(ctx, ident)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListRolePoliciesInput{
 		RoleName: aws.String(ident.Name),
 	}
 
-	result, err := svc.ListRolePolicies(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("iam exception NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("iam exception ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Err(err)
+	result := &iam.ListRolePoliciesOutput{}
+
+	paginator := iam.NewListRolePoliciesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, ident, "iam:ListRolePolicies", ident.Ref())
 		}
-		return nil, err
+
+		result.PolicyNames = append(result.PolicyNames, page.PolicyNames...)
 	}
 
 	return result, nil
 }
 
 func GetUserGroups(ctx context.Context, ident IAM) (*iam.ListGroupsForUserOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, ident)
+	svc, err := NewIAMClient(ctx, ident)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListGroupsForUserInput{
 		UserName: aws.String(ident.Name),
 	}
 
-	result, err := svc.ListGroupsForUser(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("iam exception NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("iam exception ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Err(err)
+	result := &iam.ListGroupsForUserOutput{}
+
+	paginator := iam.NewListGroupsForUserPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, ident, "iam:ListGroupsForUser", ident.Ref())
 		}
-		return nil, err
+
+		result.Groups = append(result.Groups, page.Groups...)
 	}
 
 	return result, nil
 }
 
 func GetAttachedRolePolicies(ctx context.Context, ident IAM) (*iam.ListAttachedRolePoliciesOutput, error) {
-	cfg, err := getConfigWithAssumedRole(ctx, ident)
+	svc, err := NewIAMClient(ctx, ident)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config with assumed role: %w", err)
+		return nil, err
 	}
 
-	svc := iam.NewFromConfig(cfg)
-
 	input := &iam.ListAttachedRolePoliciesInput{
 		RoleName: aws.String(ident.Name),
 	}
 
-	result, err := svc.ListAttachedRolePolicies(ctx, input)
-	if err != nil {
-		var nse *types.NoSuchEntityException
-		var sfe *types.ServiceFailureException
-		if errors.As(err, &nse) {
-			log.Error().Msgf("iam exception NoSuchEntity %s", *nse.Message)
-		} else if errors.As(err, &sfe) {
-			log.Error().Msgf("iam exception ServiceFailure %s", *sfe.Message)
-		} else {
-			log.Error().Err(err)
+	result := &iam.ListAttachedRolePoliciesOutput{}
+
+	paginator := iam.NewListAttachedRolePoliciesPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, apiError(err, ident, "iam:ListAttachedRolePolicies", ident.Ref())
 		}
+
+		result.AttachedPolicies = append(result.AttachedPolicies, page.AttachedPolicies...)
+	}
+
+	return result, nil
+}
+
+func GetUser(ctx context.Context, ident IAM) (*iam.GetUserOutput, error) {
+	svc, err := NewIAMClient(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := svc.GetUser(ctx, &iam.GetUserInput{
+		UserName: aws.String(ident.Name),
+	})
+	if err != nil {
+		return nil, apiError(err, ident, "iam:GetUser", ident.Ref())
+	}
+
+	return result, nil
+}
+
+func GetRole(ctx context.Context, ident IAM) (*iam.GetRoleOutput, error) {
+	svc, err := NewIAMClient(ctx, ident)
+	if err != nil {
 		return nil, err
 	}
 
+	result, err := svc.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(ident.Name),
+	})
+	if err != nil {
+		return nil, apiError(err, ident, "iam:GetRole", ident.Ref())
+	}
+
 	return result, nil
 }
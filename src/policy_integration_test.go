@@ -30,8 +30,8 @@ func TestGetAttachedGroupPolicies(t *testing.T) {
 		want    *iam.ListAttachedGroupPoliciesOutput
 		wantErr bool
 	}{
-		{"group", args{IAM{"idgroup", "680235478471", "", nil}}, result, false}, // TODO: Add test cases.
-		{"nogroup", args{IAM{"mygroup", "680235478471", "", nil}}, nil, true},
+		{"group", args{IAM{"idgroup", "680235478471", "", nil, nil, nil}}, result, false}, // TODO: Add test cases.
+		{"nogroup", args{IAM{"mygroup", "680235478471", "", nil, nil, nil}}, nil, true},
 	}
 
 	for _, tt := range tests {
@@ -70,8 +70,8 @@ func TestGetAttachedRolePolicies(t *testing.T) {
 		want    *iam.ListAttachedRolePoliciesOutput
 		wantErr bool
 	}{
-		{name: "role", args: args{IAM{"assume_role", "680235478471", "", nil}}, want: result, wantErr: false},
-		{name: "bogus", args: args{IAM{"notexist", "680235478471", "", nil}}, want: nil, wantErr: true},
+		{name: "role", args: args{IAM{"assume_role", "680235478471", "", nil, nil, nil}}, want: result, wantErr: false},
+		{name: "bogus", args: args{IAM{"notexist", "680235478471", "", nil, nil, nil}}, want: nil, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -103,7 +103,7 @@ func TestGetAttachedUserPolicies(t *testing.T) {
 		wantErr bool
 	}{
 		{"pass", args{IAM{
-			"identity", "680235478471", "", nil,
+			"identity", "680235478471", "", nil, nil, nil,
 		}}, &iam.ListAttachedUserPoliciesOutput{
 			AttachedPolicies: []types.AttachedPolicy{{
 				PolicyName: aws.String("test-policy"),
@@ -141,7 +141,7 @@ func TestGetGroupPolicies(t *testing.T) {
 		want    *iam.ListGroupPoliciesOutput
 		wantErr bool
 	}{
-		{"Pass", args{IAM{"idgroup", "680235478471", "", nil}}, &iam.ListGroupPoliciesOutput{
+		{"Pass", args{IAM{"idgroup", "680235478471", "", nil, nil, nil}}, &iam.ListGroupPoliciesOutput{
 			PolicyNames: []string{"my_developer_policy"},
 			IsTruncated: false},
 			false},
@@ -176,7 +176,7 @@ func TestGetGroupPolicy(t *testing.T) {
 		wantErr bool
 	}{
 		{"Pass",
-			args{"my_developer_policy", IAM{"idgroup", "680235478471", "", nil}}, aws.String("{\"Version\":\"2012-10-17\",\"Statement\":[{\"Action\":[\"ec2:Describe*\"],\"Effect\":\"Allow\",\"Resource\":\"*\"}]}"), false},
+			args{"my_developer_policy", IAM{"idgroup", "680235478471", "", nil, nil, nil}}, aws.String("{\"Version\":\"2012-10-17\",\"Statement\":[{\"Action\":[\"ec2:Describe*\"],\"Effect\":\"Allow\",\"Resource\":\"*\"}]}"), false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -206,7 +206,7 @@ func TestGetPolicy(t *testing.T) {
 	}{
 		{"Pass",
 			args{"arn:aws:iam::680235478471:policy/assume-test-policy",
-				IAM{"pass", "680235478471", "", nil}},
+				IAM{"pass", "680235478471", "", nil, nil, nil}},
 			aws.String("{\"Statement\":[{\"Action\":\"s3:*\",\"Effect\":\"Allow\",\"Resource\":\"*\"}],\"Version\":\"2012-10-17\"}"),
 			false},
 	}
@@ -234,7 +234,7 @@ func TestGetRolePolicies(t *testing.T) {
 		want    *iam.ListRolePoliciesOutput
 		wantErr bool
 	}{
-		{"pass", args{IAM{"assume_role", "680235478471", "", nil}},
+		{"pass", args{IAM{"assume_role", "680235478471", "", nil, nil, nil}},
 			&iam.ListRolePoliciesOutput{
 				PolicyNames: []string{"test_policy"},
 				IsTruncated: false},
@@ -271,7 +271,7 @@ func TestGetRolePolicy(t *testing.T) {
 	}{
 		{"Pass",
 			args{"test_policy",
-				IAM{"assume_role", "680235478471", "", nil}},
+				IAM{"assume_role", "680235478471", "", nil, nil, nil}},
 			aws.String("{\"Version\":\"2012-10-17\",\"Statement\":[{\"Action\":[\"ec2:Describe*\"],\"Effect\":\"Allow\",\"Resource\":\"*\"}]}"), false},
 	}
 	for _, tt := range tests {
@@ -298,7 +298,7 @@ func TestGetUserPolicies(t *testing.T) {
 		want    *iam.ListUserPoliciesOutput
 		wantErr bool
 	}{
-		{"Pass", args{IAM{"identity", "680235478471", "", nil}}, &iam.ListUserPoliciesOutput{
+		{"Pass", args{IAM{"identity", "680235478471", "", nil, nil, nil}}, &iam.ListUserPoliciesOutput{
 			PolicyNames: []string{"test"},
 			IsTruncated: false},
 			false},
@@ -334,7 +334,7 @@ func TestGetUserPolicy(t *testing.T) {
 		want    *string
 		wantErr bool
 	}{
-		{name: "Pass", args: args{policy: "test", user: IAM{"identity", "680235478471", "", nil}}, want: &want, wantErr: false},
+		{name: "Pass", args: args{policy: "test", user: IAM{"identity", "680235478471", "", nil, nil, nil}}, want: &want, wantErr: false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -361,7 +361,7 @@ func TestGetAttachedUserPolicies1(t *testing.T) {
 		wantErr bool
 	}{
 		{"pass", args{
-			IAM{"identity", "680235478471", "", nil}},
+			IAM{"identity", "680235478471", "", nil, nil, nil}},
 			&iam.ListAttachedUserPoliciesOutput{
 				IsTruncated: false,
 				AttachedPolicies: []types.AttachedPolicy{{
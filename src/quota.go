@@ -0,0 +1,315 @@
+package Identity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IAM quotas, as documented in the IAM and AWS STS quotas page. Policy sizes are in characters,
+// not counting white space; the attachment and group quotas are the defaults.
+const (
+	ManagedPolicyQuota  = 6144
+	UserInlineQuota     = 2048
+	GroupInlineQuota    = 5120
+	RoleInlineQuota     = 10240
+	TrustPolicyQuota    = 2048
+	AttachedPolicyQuota = 10
+	UserGroupQuota      = 10
+)
+
+// nearQuota is the share of a quota at which a principal is warned that it is close.
+const nearQuota = 0.9
+
+// Quota statuses.
+const (
+	QuotaOK   = "ok"
+	QuotaNear = "near"
+	QuotaOver = "over"
+)
+
+// QuotaUsage is how much of one quota a policy or principal uses.
+type QuotaUsage struct {
+	Subject string `json:"Subject"`
+	Quota   string `json:"Quota"`
+	Used    int    `json:"Used"`
+	Limit   int    `json:"Limit"`
+	Status  string `json:"Status"`
+}
+
+// SizeSuggestion is a way to make a policy that is close to or over a quota smaller.
+type SizeSuggestion struct {
+	Policy     string `json:"Policy"`
+	Statements []int  `json:"Statements"`
+	Change     string `json:"Change"`
+	Saving     int    `json:"Saving"`
+}
+
+// QuotaReport lists the quotas an identity uses and, for those close to or over, how to save space.
+type QuotaReport struct {
+	Usage       []QuotaUsage     `json:"Usage"`
+	Suggestions []SizeSuggestion `json:"Suggestions"`
+}
+
+// Exceeded reports whether any quota is over its limit.
+func (r QuotaReport) Exceeded() bool {
+	for _, usage := range r.Usage {
+		if usage.Status == QuotaOver {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PolicySize returns the size of a policy as IAM counts it: the characters of its JSON, not counting white space.
+func PolicySize(policy Policy) int {
+	return documentSize(policy)
+}
+
+func documentSize(document interface{}) int {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(document); err != nil {
+		return 0
+	}
+
+	size := 0
+
+	for _, r := range buffer.String() {
+		if !unicode.IsSpace(r) {
+			size++
+		}
+	}
+
+	return size
+}
+
+// CheckQuotas measures an identity's policies against the IAM quotas: each managed policy against the
+// managed policy size, the inline policies of each user, group or role against its aggregate size, the
+// managed policies attached to each principal, a user's groups, and a role's trust policy. AWS managed
+// policies count towards attachments but not sizes. Policies close to or over a quota get suggestions.
+func CheckQuotas(ident IAM) QuotaReport {
+	report := QuotaReport{Usage: []QuotaUsage{}, Suggestions: []SizeSuggestion{}}
+
+	inline := map[string][]Policy{}
+	attached := map[string]int{}
+	groups := map[string]bool{}
+	var owners []string
+
+	for _, policy := range ident.Policies {
+		owner := policyOwner(ident, policy)
+		if _, seen := inline[owner]; !seen {
+			inline[owner] = nil
+			owners = append(owners, owner)
+		}
+
+		if group := viaGroup(policy.Source); group != "" {
+			groups[group] = true
+		}
+
+		switch {
+		case policy.Source.Kind == InlineKind:
+			inline[owner] = append(inline[owner], policy)
+		case policy.Source.Kind == ManagedKind || strings.HasPrefix(policy.Source.Arn, awsManagedPrefix):
+			attached[owner]++
+
+			if !strings.HasPrefix(policy.Source.Arn, awsManagedPrefix) {
+				report.add(policy.Source.String(), "managed policy size", PolicySize(policy), ManagedPolicyQuota, policy)
+			}
+		default:
+			report.add(policy.Source.String(), "managed policy size", PolicySize(policy), ManagedPolicyQuota, policy)
+		}
+	}
+
+	for _, owner := range owners {
+		limit := inlineQuota(owner)
+
+		if policies := inline[owner]; len(policies) > 0 && limit > 0 {
+			size := 0
+			for _, policy := range policies {
+				size += PolicySize(policy)
+			}
+
+			report.add(owner, "inline policy size", size, limit, policies...)
+		}
+
+		if attached[owner] > 0 && owner != "" {
+			report.add(owner, "attached managed policies", attached[owner], AttachedPolicyQuota)
+		}
+	}
+
+	if ident.IamType == UserType && len(groups) > 0 {
+		report.add(ident.Ref(), "groups", len(groups), UserGroupQuota)
+	}
+
+	if ident.IamType == RoleType && len(ident.Trust) > 0 {
+		var trust interface{}
+		if err := json.Unmarshal(ident.Trust, &trust); err == nil {
+			report.add(ident.Ref(), "trust policy size", documentSize(trust), TrustPolicyQuota)
+		}
+	}
+
+	if ident.Boundary != nil {
+		report.add(ident.Boundary.Source.String(), "managed policy size", PolicySize(*ident.Boundary), ManagedPolicyQuota, *ident.Boundary)
+	}
+
+	return report
+}
+
+// add records a quota's usage and, when it is close or over, suggestions for the policies that count towards it.
+func (r *QuotaReport) add(subject string, quota string, used int, limit int, policies ...Policy) {
+	usage := QuotaUsage{Subject: subject, Quota: quota, Used: used, Limit: limit, Status: QuotaOK}
+
+	switch {
+	case used > limit:
+		usage.Status = QuotaOver
+	case float64(used) >= nearQuota*float64(limit):
+		usage.Status = QuotaNear
+	}
+
+	r.Usage = append(r.Usage, usage)
+
+	if usage.Status != QuotaOK {
+		for _, policy := range policies {
+			r.Suggestions = append(r.Suggestions, SuggestSavings(policy)...)
+		}
+	}
+}
+
+// policyOwner names the principal a policy is attached to: the last step of its attachment path, or the identity.
+func policyOwner(ident IAM, policy Policy) string {
+	if len(policy.Source.Via) > 0 {
+		return policy.Source.Via[len(policy.Source.Via)-1]
+	}
+
+	if ident.IamType != "" {
+		return ident.Ref()
+	}
+
+	return ""
+}
+
+func inlineQuota(owner string) int {
+	switch {
+	case strings.HasPrefix(owner, UserType+"/"):
+		return UserInlineQuota
+	case strings.HasPrefix(owner, GroupType+"/"):
+		return GroupInlineQuota
+	case strings.HasPrefix(owner, RoleType+"/"):
+		return RoleInlineQuota
+	}
+
+	return 0
+}
+
+// SuggestSavings proposes ways to shrink a policy without changing what it allows: merging statements
+// that differ only in their actions, and replacing actions that share a prefix, such as sqs:ListQueues and
+// sqs:ListQueueTags, with a wildcard such as sqs:ListQueue* where the wildcard matches nothing else. Only
+// services whose catalogue is complete, listed in data/complete.json, get wildcard suggestions.
+func SuggestSavings(policy Policy) []SizeSuggestion {
+	var suggestions []SizeSuggestion
+
+	name := policy.Source.String()
+	groups := map[string][]int{}
+	var keys []string
+
+	for index, statement := range policy.Statements {
+		if statement.NotAction != nil {
+			continue
+		}
+
+		key := statementKey(Statement{
+			Effect: statement.Effect, Principal: statement.Principal, NotPrincipal: statement.NotPrincipal,
+			Resource: uniqueSorted(statement.Resource), NotResource: uniqueSorted(statement.NotResource),
+			Condition: normalizeCondition(statement.Condition),
+		})
+
+		if _, seen := groups[key]; !seen {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], index)
+	}
+
+	for _, key := range keys {
+		indexes := groups[key]
+		if len(indexes) < 2 {
+			continue
+		}
+
+		separate := Policy{Version: policy.Version}
+		merged := Statement{}
+
+		for _, index := range indexes {
+			statement := policy.Statements[index]
+			separate.Statements = append(separate.Statements, statement)
+
+			merged.Effect, merged.Resource, merged.NotResource, merged.Condition = statement.Effect, statement.Resource, statement.NotResource, statement.Condition
+			merged.Principal, merged.NotPrincipal = statement.Principal, statement.NotPrincipal
+			merged.Action = append(merged.Action, statement.Action...)
+		}
+
+		merged.Action = uniqueSorted(merged.Action)
+
+		suggestions = append(suggestions, SizeSuggestion{
+			Policy:     name,
+			Statements: indexes,
+			Change:     "merge statements with the same effect, resources and conditions",
+			Saving:     PolicySize(separate) - PolicySize(Policy{Version: policy.Version, Statements: []Statement{merged}}),
+		})
+	}
+
+	for index, statement := range policy.Statements {
+		for _, wildcard := range savingWildcards(statement.Action) {
+			covered := ExpandAction(wildcard)
+			saving := -utf8.RuneCountInString(wildcard) - 3
+
+			for _, action := range statement.Action {
+				if MatchAction(wildcard, action) {
+					saving += utf8.RuneCountInString(action) + 3
+				}
+			}
+
+			suggestions = append(suggestions, SizeSuggestion{
+				Policy:     name,
+				Statements: []int{index},
+				Change:     fmt.Sprintf("replace the %d actions matching %s with the wildcard", len(covered), wildcard),
+				Saving:     saving,
+			})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Saving > suggestions[j].Saving
+	})
+
+	return suggestions
+}
+
+// savingWildcards returns the prefix wildcards, such as sqs:List*, that match two or more of the listed
+// actions and no other action in the catalogue. Only services whose catalogue is complete are considered,
+// as a wildcard for any other service could match actions the catalogue does not list.
+func savingWildcards(actions []string) []string {
+	byService := map[string][]string{}
+
+	for _, action := range actions {
+		if service, _ := SplitAction(action); completeService(service) {
+			byService[service] = append(byService[service], action)
+		}
+	}
+
+	var wildcards []string
+	for _, service := range sortedKeys(byService) {
+		wildcards = append(wildcards, prefixWildcards(service, byService[service])...)
+	}
+
+	return wildcards
+}
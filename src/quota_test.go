@@ -0,0 +1,123 @@
+package Identity
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicySize(t *testing.T) {
+	policy, err := Parse(`{
+		"Version": "2012-10-17",
+		"Statement": [{"Sid": "", "Effect": "Allow", "Action": ["s3:GetObject"], "Resource": ["arn:aws:s3:::my bucket/*"]}]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := json.Marshal(policy)
+	want := len(strings.ReplaceAll(string(raw), " ", ""))
+
+	if got := PolicySize(policy); got != want {
+		t.Errorf("PolicySize() = %d, want %d", got, want)
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	var actions []string
+	for i := 0; i < 120; i++ {
+		actions = append(actions, "sqs:SendMessage")
+	}
+
+	big := Policy{
+		Version:    "2012-10-17",
+		Statements: []Statement{{Effect: "Allow", Action: actions, Resource: []string{"*"}}},
+		Source:     Source{Name: "big", Kind: InlineKind, Via: []string{"user/alice"}},
+	}
+
+	small := Policy{
+		Version:    "2012-10-17",
+		Statements: []Statement{{Effect: "Allow", Action: []string{"s3:GetObject"}, Resource: []string{"*"}}},
+		Source:     Source{Name: "ReadOnly", Arn: "arn:aws:iam::aws:policy/ReadOnlyAccess", Kind: ManagedKind, Via: []string{"user/alice", "group/dev"}},
+	}
+
+	ident := IAM{Name: "alice", IamType: UserType, Policies: []Policy{big, small}}
+	report := CheckQuotas(ident)
+
+	want := []QuotaUsage{
+		{Subject: "user/alice", Quota: "inline policy size", Used: PolicySize(big), Limit: UserInlineQuota, Status: QuotaOver},
+		{Subject: "group/dev", Quota: "attached managed policies", Used: 1, Limit: AttachedPolicyQuota, Status: QuotaOK},
+		{Subject: "user/alice", Quota: "groups", Used: 1, Limit: UserGroupQuota, Status: QuotaOK},
+	}
+
+	if !reflect.DeepEqual(report.Usage, want) {
+		t.Errorf("CheckQuotas() usage = %+v, want %+v", report.Usage, want)
+	}
+
+	if !report.Exceeded() {
+		t.Error("Exceeded() = false, want true")
+	}
+}
+
+func TestSuggestSavings(t *testing.T) {
+	policy := Policy{
+		Version: "2012-10-17",
+		Statements: []Statement{
+			{Effect: "Allow", Action: []string{"sqs:ListQueues", "sqs:ListQueueTags", "sqs:ListDeadLetterSourceQueues", "sqs:ListMessageMoveTasks"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"sqs:GetQueueUrl"}, Resource: []string{"*"}},
+			// the catalogue lists only two of iam's Add actions, so no iam:Add* is suggested
+			{Effect: "Allow", Action: []string{"iam:AddRoleToInstanceProfile", "iam:AddUserToGroup"}, Resource: []string{"arn:aws:iam::123456789012:role/app"}},
+			{Effect: "Allow", Action: []string{"sqs:SendMessage"}, Resource: []string{"arn:aws:sqs:eu-west-2:123456789012:jobs"}},
+		},
+		Source: Source{Name: "queues"},
+	}
+
+	got := SuggestSavings(policy)
+
+	var changes []string
+	for _, suggestion := range got {
+		changes = append(changes, suggestion.Change)
+
+		if suggestion.Saving <= 0 {
+			t.Errorf("SuggestSavings() %q saves %d, want a saving", suggestion.Change, suggestion.Saving)
+		}
+	}
+
+	want := []string{
+		"replace the 4 actions matching sqs:List* with the wildcard",
+		"merge statements with the same effect, resources and conditions",
+	}
+
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("SuggestSavings() = %v, want %v", changes, want)
+	}
+}
+
+func TestSuggestSavingsPrefix(t *testing.T) {
+	completeServices["s3"] = true
+	t.Cleanup(func() { delete(completeServices, "s3") })
+
+	policy := Policy{
+		Version: "2012-10-17",
+		Statements: []Statement{{Effect: "Allow", Action: []string{
+			"s3:GetObject", "s3:GetObjectAcl", "s3:GetObjectAttributes", "s3:GetObjectLegalHold", "s3:GetObjectRetention",
+			"s3:GetObjectTagging", "s3:GetObjectTorrent", "s3:GetObjectVersion", "s3:GetObjectVersionAcl",
+			"s3:GetObjectVersionAttributes", "s3:GetObjectVersionTagging", "sqs:ListQueueTags", "sqs:ListQueues",
+		}, Resource: []string{"*"}}},
+	}
+
+	var changes []string
+	for _, suggestion := range SuggestSavings(policy) {
+		changes = append(changes, suggestion.Change)
+	}
+
+	want := []string{
+		"replace the 11 actions matching s3:GetObject* with the wildcard",
+		"replace the 2 actions matching sqs:ListQueue* with the wildcard",
+	}
+
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("SuggestSavings() = %v, want %v", changes, want)
+	}
+}
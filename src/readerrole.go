@@ -0,0 +1,272 @@
+package Identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Feature is a part of the tool that calls AWS with the reader role.
+type Feature string
+
+const (
+	// IdentityFeature reads an identity, its groups, policies, permissions boundary and trust policy.
+	IdentityFeature Feature = "identity"
+	// UnusedFeature reads service last accessed data.
+	UnusedFeature Feature = "unused"
+	// HistoryFeature reads the versions of managed policies.
+	HistoryFeature Feature = "history"
+)
+
+// Formats the reader role is written in, besides JSONFormat.
+const (
+	TerraformFormat      = "terraform"
+	CloudFormationFormat = "cloudformation"
+)
+
+// ReaderPolicyName is the name of the reader role's permission policy.
+const ReaderPolicyName = "identity-minimum"
+
+// featureActions declares the IAM calls each feature makes with the reader role. Every method of
+// IAMClient must appear here. The caller's own credentials only need sts:AssumeRole on the role;
+// sts:GetCallerIdentity needs no permission.
+var featureActions = map[Feature][]string{
+	IdentityFeature: {
+		"iam:GetGroupPolicy",
+		"iam:GetPolicy",
+		"iam:GetPolicyVersion",
+		"iam:GetRole",
+		"iam:GetRolePolicy",
+		"iam:GetUser",
+		"iam:GetUserPolicy",
+		"iam:ListAttachedGroupPolicies",
+		"iam:ListAttachedRolePolicies",
+		"iam:ListAttachedUserPolicies",
+		"iam:ListGroupPolicies",
+		"iam:ListGroupsForUser",
+		"iam:ListRolePolicies",
+		"iam:ListUserPolicies",
+	},
+	UnusedFeature: {
+		"iam:GenerateServiceLastAccessedDetails",
+		"iam:GetServiceLastAccessedDetails",
+	},
+	HistoryFeature: {
+		"iam:GetPolicyVersion",
+		"iam:ListPolicyVersions",
+	},
+}
+
+// ReaderRole is the role the tool assumes: its permission policy and the trust policy that lets the caller assume it.
+type ReaderRole struct {
+	RoleName    string `json:"RoleName"`
+	PolicyName  string `json:"PolicyName"`
+	Policy      Policy `json:"Policy"`
+	TrustPolicy Policy `json:"TrustPolicy"`
+}
+
+// Features returns the features that call AWS, sorted.
+func Features() []Feature {
+	features := make([]Feature, 0, len(featureActions))
+	for feature := range featureActions {
+		features = append(features, feature)
+	}
+
+	sort.Slice(features, func(i, j int) bool { return features[i] < features[j] })
+
+	return features
+}
+
+// ReaderActions returns the sorted actions the reader role needs for the features, or for all of them when none are given.
+func ReaderActions(features ...Feature) ([]string, error) {
+	if len(features) == 0 {
+		features = Features()
+	}
+
+	var actions []string
+
+	for _, feature := range features {
+		declared, ok := featureActions[feature]
+		if !ok {
+			return nil, fmt.Errorf("unknown feature %s", feature)
+		}
+
+		actions = append(actions, declared...)
+	}
+
+	return uniqueSorted(actions), nil
+}
+
+// ReaderPolicy returns the least-privilege permission policy of the reader role for the features.
+func ReaderPolicy(features ...Feature) (Policy, error) {
+	actions, err := ReaderActions(features...)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return Policy{
+		Version:    currentVersion,
+		Statements: []Statement{{Sid: "IdentityReader", Effect: Allow, Action: actions, Resource: []string{"*"}}},
+		Source:     Source{Name: ReaderPolicyName, Kind: ManagedKind},
+	}, nil
+}
+
+// TrustPolicy returns a trust policy that lets callerArn assume the reader role. The ARN of an assumed-role
+// session, as GetCallerIdentity reports it, is trusted as the role it came from.
+func TrustPolicy(callerArn string) (Policy, error) {
+	parts := strings.SplitN(callerArn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[4] == "" {
+		return Policy{}, fmt.Errorf("invalid caller ARN %q", callerArn)
+	}
+
+	principal := callerArn
+
+	if parts[2] == "sts" {
+		session := strings.Split(parts[5], "/")
+		if len(session) < 2 || session[0] != "assumed-role" {
+			return Policy{}, fmt.Errorf("invalid caller ARN %q", callerArn)
+		}
+
+		principal = fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], session[1])
+	} else if parts[2] != "iam" {
+		return Policy{}, fmt.Errorf("caller ARN %q is not an IAM principal", callerArn)
+	}
+
+	return Policy{
+		Version: currentVersion,
+		Statements: []Statement{{
+			Effect:    Allow,
+			Principal: Principal{"AWS": {principal}},
+			Action:    []string{"sts:AssumeRole"},
+		}},
+	}, nil
+}
+
+// CallerArn returns the ARN of the caller of the current AWS profile, to trust in the reader role.
+func CallerArn(ctx context.Context) (string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	result, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %w", err)
+	}
+
+	return aws.ToString(result.Arn), nil
+}
+
+// NewReaderRole returns the reader role called roleName for the features, trusting callerArn.
+func NewReaderRole(roleName string, callerArn string, features ...Feature) (ReaderRole, error) {
+	policy, err := ReaderPolicy(features...)
+	if err != nil {
+		return ReaderRole{}, err
+	}
+
+	trust, err := TrustPolicy(callerArn)
+	if err != nil {
+		return ReaderRole{}, err
+	}
+
+	return ReaderRole{RoleName: roleName, PolicyName: ReaderPolicyName, Policy: policy, TrustPolicy: trust}, nil
+}
+
+// Render writes the reader role as JSON, Terraform/OpenTofu or a CloudFormation template.
+func (r ReaderRole) Render(format string) (string, error) {
+	switch format {
+	case JSONFormat:
+		return marshalIndent(r)
+	case TerraformFormat:
+		return r.terraform(), nil
+	case CloudFormationFormat:
+		return r.cloudFormation()
+	default:
+		return "", fmt.Errorf("unknown format %s", format)
+	}
+}
+
+func (r ReaderRole) terraform() string {
+	names := map[string]bool{}
+	role := terraformName(r.RoleName, names)
+	policy := terraformName(r.PolicyName, names)
+	trust := terraformName(r.RoleName+"_trust", names)
+
+	resource := &hclBlock{header: fmt.Sprintf("resource %q %q", "aws_iam_role", role)}
+	resource.attribute("name", hclString(r.RoleName))
+	resource.attribute("assume_role_policy", "data.aws_iam_policy_document."+trust+".json")
+
+	managed := &hclBlock{header: fmt.Sprintf("resource %q %q", "aws_iam_policy", policy)}
+	managed.attribute("name", hclString(r.PolicyName))
+	managed.attribute("policy", "data.aws_iam_policy_document."+policy+".json")
+
+	blocks := []*hclBlock{
+		policyDocument(trust, r.TrustPolicy),
+		resource,
+		policyDocument(policy, r.Policy),
+		managed,
+		attachmentBlock(RoleType, policy, "aws_iam_role."+role+".name", "aws_iam_policy."+policy+".arn"),
+	}
+
+	var builder strings.Builder
+
+	for i, block := range blocks {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+
+		block.render(&builder, "")
+	}
+
+	return builder.String()
+}
+
+type cloudFormationResource struct {
+	Type       string      `json:"Type"`
+	Properties interface{} `json:"Properties"`
+}
+
+func (r ReaderRole) cloudFormation() (string, error) {
+	template := struct {
+		Version     string                            `json:"AWSTemplateFormatVersion"`
+		Description string                            `json:"Description"`
+		Resources   map[string]cloudFormationResource `json:"Resources"`
+	}{
+		Version:     "2010-09-09",
+		Description: fmt.Sprintf("Role %s that identity assumes to read IAM", r.RoleName),
+		Resources: map[string]cloudFormationResource{
+			"ReaderPolicy": {Type: "AWS::IAM::ManagedPolicy", Properties: struct {
+				ManagedPolicyName string `json:"ManagedPolicyName"`
+				PolicyDocument    Policy `json:"PolicyDocument"`
+			}{r.PolicyName, r.Policy}},
+			"ReaderRole": {Type: "AWS::IAM::Role", Properties: struct {
+				RoleName                 string              `json:"RoleName"`
+				AssumeRolePolicyDocument Policy              `json:"AssumeRolePolicyDocument"`
+				ManagedPolicyArns        []map[string]string `json:"ManagedPolicyArns"`
+			}{r.RoleName, r.TrustPolicy, []map[string]string{{"Ref": "ReaderPolicy"}}}},
+		},
+	}
+
+	return marshalIndent(template)
+}
+
+// marshalIndent writes indented JSON without escaping HTML, as policies write themselves.
+func marshalIndent(v interface{}) (string, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
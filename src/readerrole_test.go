@@ -0,0 +1,199 @@
+package Identity
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestReaderActionsCoverClient fails when a call is added to IAMClient without declaring its action.
+func TestReaderActionsCoverClient(t *testing.T) {
+	client := reflect.TypeOf((*IAMClient)(nil)).Elem()
+
+	var want []string
+	for i := 0; i < client.NumMethod(); i++ {
+		want = append(want, "iam:"+client.Method(i).Name)
+	}
+
+	sort.Strings(want)
+
+	got, err := ReaderActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReaderActions() = %v, want the IAMClient calls %v", got, want)
+	}
+}
+
+// TestReaderActionsMatchTerraform fails when the role in src/role drifts from the declared actions.
+func TestReaderActionsMatchTerraform(t *testing.T) {
+	policies, err := LoadPolicies("role")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := ReaderActions()
+
+	for _, policy := range policies {
+		if policy.Source.Name != "data.aws_iam_policy_document.policy" {
+			continue
+		}
+
+		got := uniqueSorted(policy.Statements[0].Action)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("role/aws_iam_policy.tf actions = %v, want %v", got, want)
+		}
+
+		return
+	}
+
+	t.Errorf("role/aws_iam_policy.tf has no reader policy document")
+}
+
+func TestReaderActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		features []Feature
+		want     []string
+		wantErr  bool
+	}{
+		{"unused", []Feature{UnusedFeature}, []string{"iam:GenerateServiceLastAccessedDetails", "iam:GetServiceLastAccessedDetails"}, false},
+		{"history", []Feature{HistoryFeature}, []string{"iam:GetPolicyVersion", "iam:ListPolicyVersions"}, false},
+		{"unknown", []Feature{"nope"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReaderActions(tt.features...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReaderActions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReaderActions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		want    string
+		wantErr bool
+	}{
+		{"user", "arn:aws:iam::680235478471:user/basic", "arn:aws:iam::680235478471:user/basic", false},
+		{"assumed_role", "arn:aws:sts::680235478471:assumed-role/admin/session", "arn:aws:iam::680235478471:role/admin", false},
+		{"partition", "arn:aws-us-gov:sts::680235478471:assumed-role/admin/session", "arn:aws-us-gov:iam::680235478471:role/admin", false},
+		{"federated", "arn:aws:sts::680235478471:federated-user/bob", "", true},
+		{"not_iam", "arn:aws:s3:::bucket", "", true},
+		{"not_arn", "basic", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TrustPolicy(tt.caller)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TrustPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if principal := got.Statements[0].Principal["AWS"]; !reflect.DeepEqual(principal, []string{tt.want}) {
+				t.Errorf("TrustPolicy() principal = %v, want %s", principal, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderRole_Render(t *testing.T) {
+	role, err := NewReaderRole("identity", "arn:aws:iam::680235478471:user/basic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions, _ := ReaderActions()
+
+	tests := []struct {
+		format string
+		file   string
+		want   []string
+	}{
+		{TerraformFormat, "main.tf", []string{`resource "aws_iam_role" "identity"`, `type        = "AWS"`, `identifiers = ["arn:aws:iam::680235478471:user/basic"]`}},
+		{CloudFormationFormat, "template.json", []string{`"Type": "AWS::IAM::Role"`, `"AWS": "arn:aws:iam::680235478471:user/basic"`, `"Ref": "ReaderPolicy"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := role.Render(tt.format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Render() = %s, want it to contain %s", got, want)
+				}
+			}
+
+			// The output must load back as the reader policy; the trust policy names a principal and is skipped
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(got), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			policies, err := LoadPolicies(path)
+			if err != nil {
+				t.Fatalf("LoadPolicies() error = %v", err)
+			}
+
+			if len(policies) != 1 || !reflect.DeepEqual(policies[0].Statements[0].Action, actions) {
+				t.Errorf("LoadPolicies() = %+v, want the reader policy", policies)
+			}
+		})
+	}
+
+	t.Run(JSONFormat, func(t *testing.T) {
+		got, err := role.Render(JSONFormat)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+
+		var again ReaderRole
+		if err := json.Unmarshal([]byte(got), &again); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+
+		role.Policy.Source = Source{}
+		if !reflect.DeepEqual(again, role) {
+			t.Errorf("Render() read back = %+v, want %+v", again, role)
+		}
+	})
+
+	if _, err := role.Render("yaml"); err == nil {
+		t.Errorf("Render(yaml) error = nil, want an unknown format")
+	}
+}
+
+func TestCallerArn(t *testing.T) {
+	useFakeAWS(t, "account.json")
+
+	got, err := CallerArn(context.Background())
+	if err != nil {
+		t.Fatalf("CallerArn() error = %v", err)
+	}
+
+	if want := "arn:aws:iam::680235478471:user/basic"; got != want {
+		t.Errorf("CallerArn() = %s, want %s", got, want)
+	}
+}
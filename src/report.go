@@ -0,0 +1,173 @@
+package Identity
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed data/report.html
+var reportTemplate string
+
+var reportPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join":      strings.Join,
+	"condition": formatReportCondition,
+}).Parse(reportTemplate))
+
+// accessLevels orders the access levels in the report.
+var accessLevels = []string{ListLevel, ReadLevel, WriteLevel, PermissionsLevel, TaggingLevel, UnknownLevel}
+
+// Report is everything the HTML report shows about an identity.
+type Report struct {
+	Identity      IAM
+	Arn           string
+	Generated     time.Time
+	Policies      []reportPolicy
+	Services      []reportService
+	Findings      []Finding
+	Allowed       int
+	Denied        int
+	FindingCounts map[string]int
+}
+
+type reportPolicy struct {
+	Name       string
+	Kind       string
+	Path       string
+	Arn        string
+	Location   string
+	Statements []reportStatement
+}
+
+type reportStatement struct {
+	Index     int
+	Line      int
+	Statement Statement
+}
+
+type reportService struct {
+	Service string
+	Levels  []reportLevel
+}
+
+type reportLevel struct {
+	Level       string
+	Permissions []Permission
+}
+
+// NewReport gathers an identity's policies, effective permissions and lint and escalation findings.
+func NewReport(ident IAM, generated time.Time) Report {
+	report := Report{Identity: ident, Generated: generated.UTC(), FindingCounts: map[string]int{}}
+
+	if ident.IamType != "" {
+		report.Arn = FormatArn(ident)
+	}
+
+	policies := ident.Policies
+	if ident.Boundary != nil {
+		policies = append(append([]Policy{}, policies...), *ident.Boundary)
+	}
+
+	for _, policy := range policies {
+		report.Policies = append(report.Policies, newReportPolicy(policy))
+	}
+
+	matrix := Effective(ident)
+
+	for _, service := range matrix.Services() {
+		byLevel := map[string][]Permission{}
+
+		for _, permission := range matrix[service] {
+			byLevel[permission.AccessLevel] = append(byLevel[permission.AccessLevel], permission)
+
+			if permission.Effect == Allow {
+				report.Allowed++
+			} else {
+				report.Denied++
+			}
+		}
+
+		entry := reportService{Service: service}
+
+		for _, level := range accessLevels {
+			if len(byLevel[level]) > 0 {
+				entry.Levels = append(entry.Levels, reportLevel{Level: level, Permissions: byLevel[level]})
+			}
+		}
+
+		report.Services = append(report.Services, entry)
+	}
+
+	report.Findings = append(Lint(ident), Escalations(ident)...)
+	SortFindings(report.Findings)
+
+	for _, finding := range report.Findings {
+		report.FindingCounts[finding.Level]++
+	}
+
+	return report
+}
+
+func newReportPolicy(policy Policy) reportPolicy {
+	entry := reportPolicy{
+		Name: policy.Source.Name,
+		Kind: policy.Source.Kind,
+		Path: policy.Source.String(),
+		Arn:  policy.Source.Arn,
+	}
+
+	if policy.Source.File != "" {
+		entry.Location = policy.Source.File + ":" + strconv.Itoa(policy.Source.Line)
+	}
+
+	for index, statement := range policy.Statements {
+		line := 0
+		if index < len(policy.Source.Lines) {
+			line = policy.Source.Lines[index]
+		}
+
+		entry.Statements = append(entry.Statements, reportStatement{Index: index, Line: line, Statement: statement})
+	}
+
+	return entry
+}
+
+// Kinds counts the report's policies by kind, in name order.
+func (r Report) Kinds() []string {
+	counts := map[string]int{}
+	for _, policy := range r.Policies {
+		counts[policy.Kind]++
+	}
+
+	var kinds []string
+	for kind, count := range counts {
+		kinds = append(kinds, strconv.Itoa(count)+" "+kind)
+	}
+
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+// WriteHTML writes the report as a single HTML page with its styles and search script inline.
+func (r Report) WriteHTML(w io.Writer) error {
+	return reportPage.Execute(w, r)
+}
+
+func formatReportCondition(condition Condition) string {
+	if len(condition) == 0 {
+		return ""
+	}
+
+	raw, err := json.Marshal(condition)
+	if err != nil {
+		return ""
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,132 @@
+package Identity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func reportIdentity(t *testing.T) IAM {
+	t.Helper()
+
+	policy, err := Parse(`{"Version":"2012-10-17","Statement":[
+		{"Sid":"Deploy","Effect":"Allow","Action":["iam:PassRole","ec2:RunInstances"],"Resource":"*"},
+		{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::logs/*","Condition":{"Bool":{"aws:SecureTransport":["true"]}}},
+		{"Effect":"Deny","Action":"s3:DeleteObject","Resource":"*"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy.Source = Source{Name: "<deploy>", Kind: InlineKind, Via: []string{"role/app"}, File: "main.tf", Line: 3, Lines: []int{5, 9, 14}}
+
+	boundary, err := Parse(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["iam:*","ec2:*","s3:*"],"Resource":"*"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boundary.Source = Source{Name: "limit", Arn: "arn:aws:iam::680235478471:policy/limit", Kind: BoundaryKind, Via: []string{"role/app"}}
+
+	return IAM{Name: "app", Account: "680235478471", IamType: RoleType, Policies: []Policy{policy}, Boundary: &boundary}
+}
+
+func TestNewReport(t *testing.T) {
+	report := NewReport(reportIdentity(t), time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CEST", 7200)))
+
+	if report.Arn != "arn:aws:iam::680235478471:role/app" {
+		t.Errorf("Arn = %s", report.Arn)
+	}
+
+	if report.Generated.Location() != time.UTC || report.Generated.Hour() != 10 {
+		t.Errorf("Generated = %s, want 10:00 UTC", report.Generated)
+	}
+
+	if len(report.Policies) != 2 || report.Policies[1].Kind != BoundaryKind {
+		t.Fatalf("Policies = %+v, want the policy and the boundary", report.Policies)
+	}
+
+	if statement := report.Policies[0].Statements[1]; statement.Line != 9 || statement.Statement.Action[0] != "s3:GetObject" {
+		t.Errorf("Statements[1] = %+v, want s3:GetObject on line 9", statement)
+	}
+
+	if report.Policies[0].Location != "main.tf:3" {
+		t.Errorf("Location = %s, want main.tf:3", report.Policies[0].Location)
+	}
+
+	if report.Allowed != 3 || report.Denied != 1 {
+		t.Errorf("Allowed, Denied = %d, %d, want 3, 1", report.Allowed, report.Denied)
+	}
+
+	var services, levels []string
+	for _, service := range report.Services {
+		services = append(services, service.Service)
+
+		for _, level := range service.Levels {
+			levels = append(levels, service.Service+"/"+level.Level)
+		}
+	}
+
+	if strings.Join(services, ",") != "ec2,iam,s3" {
+		t.Errorf("Services = %v, want ec2, iam and s3", services)
+	}
+
+	if strings.Join(levels, ",") != "ec2/Write,iam/Write,s3/Read,s3/Write" {
+		t.Errorf("Levels = %v, want them in access level order", levels)
+	}
+
+	if len(report.Findings) == 0 {
+		t.Errorf("Findings = none, want the iam:PassRole escalation")
+	}
+
+	var counted int
+	for _, count := range report.FindingCounts {
+		counted += count
+	}
+
+	if counted != len(report.Findings) {
+		t.Errorf("FindingCounts = %v, want %d findings", report.FindingCounts, len(report.Findings))
+	}
+
+	if kinds := report.Kinds(); strings.Join(kinds, ",") != "1 boundary,1 inline" {
+		t.Errorf("Kinds() = %v", kinds)
+	}
+}
+
+func TestReport_WriteHTML(t *testing.T) {
+	var buffer bytes.Buffer
+
+	if err := NewReport(reportIdentity(t), time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)).WriteHTML(&buffer); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+
+	page := buffer.String()
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"arn:aws:iam::680235478471:role/app",
+		"2026-10-19 10:00:00 UTC",
+		"&lt;deploy&gt;",
+		"role/app &gt; &lt;deploy&gt;",
+		"<code>main.tf:3</code>",
+		"iam:PassRole ec2:RunInstances",
+		"{&#34;Bool&#34;:{&#34;aws:SecureTransport&#34;:[&#34;true&#34;]}}",
+		`<td class="Deny">Deny</td>`,
+		"<td>Read</td><td><code>s3:GetObject</code></td>",
+		`id="search"`,
+		"<script>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("WriteHTML() does not contain %q", want)
+		}
+	}
+
+	if strings.Contains(page, "<deploy>") {
+		t.Errorf("WriteHTML() does not escape policy names")
+	}
+
+	for _, external := range []string{"<link", `src="`, "http://", "https://"} {
+		if strings.Contains(page, external) {
+			t.Errorf("WriteHTML() refers to an external asset: %q", external)
+		}
+	}
+}
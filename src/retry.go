@@ -0,0 +1,271 @@
+package Identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// RetryPolicy controls how every IAM and STS call the tool makes is retried and paced.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per call, including the first.
+	MaxAttempts int
+	// Mode is aws.RetryModeStandard, or aws.RetryModeAdaptive to also slow down client side when throttled.
+	Mode aws.RetryMode
+	// BaseBackoff is the delay before the first retry, doubled for each retry after it; no delay is longer than MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Jitter spreads each delay at random between zero and its full length, so that clients throttled
+	// together do not retry together.
+	Jitter bool
+	// RequestsPerSecond caps the attempts made by all clients together; zero for no cap.
+	RequestsPerSecond float64
+}
+
+// DefaultRetryPolicy returns the policy used unless SetRetryPolicy is called.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 8,
+		Mode:        aws.RetryModeStandard,
+		BaseBackoff: time.Second,
+		MaxBackoff:  20 * time.Second,
+		Jitter:      true,
+	}
+}
+
+var (
+	retryMutex  sync.RWMutex
+	retryPolicy = DefaultRetryPolicy()
+	limiter     *rateLimiter
+)
+
+// SetRetryPolicy sets the policy for the clients built after it is called.
+func SetRetryPolicy(policy RetryPolicy) error {
+	if policy.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, not %d", policy.MaxAttempts)
+	}
+
+	if policy.Mode != aws.RetryModeStandard && policy.Mode != aws.RetryModeAdaptive {
+		return fmt.Errorf("unknown retry mode %s", policy.Mode)
+	}
+
+	if policy.BaseBackoff <= 0 || policy.MaxBackoff <= 0 {
+		return fmt.Errorf("backoff and max backoff must be positive")
+	}
+
+	if policy.RequestsPerSecond < 0 {
+		return fmt.Errorf("requests per second must not be negative")
+	}
+
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+
+	retryPolicy = policy
+	limiter = nil
+
+	if policy.RequestsPerSecond > 0 {
+		limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / policy.RequestsPerSecond)}
+	}
+
+	return nil
+}
+
+// loadConfig loads the config of the current profile, with the retry policy and rate limit applied to
+// the clients built from it.
+func loadConfig(ctx context.Context) (aws.Config, error) {
+	retryMutex.RLock()
+	policy, shared := retryPolicy, limiter
+	retryMutex.RUnlock()
+
+	return config.LoadDefaultConfig(ctx,
+		config.WithSharedConfigProfile(GetAWSProfile()),
+		config.WithRetryer(policy.retryer),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			func(stack *middleware.Stack) error {
+				// Calls are counted before the retry middleware, and each attempt after it
+				if err := stack.Finalize.Add(countCalls, middleware.Before); err != nil {
+					return err
+				}
+
+				return stack.Finalize.Add(attemptMiddleware(shared), middleware.After)
+			},
+		}),
+	)
+}
+
+func (p RetryPolicy) retryer() aws.Retryer {
+	standard := func(o *retry.StandardOptions) {
+		o.MaxAttempts = p.MaxAttempts
+		o.MaxBackoff = p.MaxBackoff
+		o.Backoff = backoff{base: p.BaseBackoff, max: p.MaxBackoff, jitter: p.Jitter}
+		// The default retry quota gives up on every call once enough have been throttled, which is
+		// what happens in large accounts; the backoff and the rate limit pace the retries instead.
+		o.RateLimiter = ratelimit.None
+	}
+
+	if p.Mode == aws.RetryModeAdaptive {
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standard)
+		})
+	}
+
+	return retry.NewStandard(standard)
+}
+
+// backoff doubles the delay for each retry up to a maximum, optionally with full jitter.
+type backoff struct {
+	base   time.Duration
+	max    time.Duration
+	jitter bool
+}
+
+func (b backoff) BackoffDelay(attempt int, _ error) (time.Duration, error) {
+	delay := b.max
+	if attempt < 32 {
+		delay = min(b.max, b.base<<(attempt-1))
+	}
+
+	if b.jitter {
+		delay = time.Duration(rand.Float64() * float64(delay))
+	}
+
+	return delay, nil
+}
+
+// rateLimiter spaces attempts at least interval apart, across every client that shares it.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mutex.Lock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+
+	l.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// CallStats counts the calls made to an AWS operation, the attempts they took and how many of those were throttled.
+type CallStats struct {
+	Operation string
+	Calls     int
+	Attempts  int
+	Throttled int
+}
+
+// Retries is the number of attempts beyond the first of each call.
+func (s CallStats) Retries() int {
+	return s.Attempts - s.Calls
+}
+
+var (
+	statsMutex sync.Mutex
+	callStats  = map[string]*CallStats{}
+)
+
+// CallStatistics returns the calls made since the last ResetCallStatistics, by operation.
+func CallStatistics() []CallStats {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	stats := make([]CallStats, 0, len(callStats))
+	for _, operation := range callStats {
+		stats = append(stats, *operation)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Operation < stats[j].Operation })
+
+	return stats
+}
+
+// ResetCallStatistics forgets the calls counted so far.
+func ResetCallStatistics() {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	callStats = map[string]*CallStats{}
+}
+
+func recordCall(ctx context.Context, record func(*CallStats)) {
+	operation := awsmiddleware.GetServiceID(ctx) + "." + awsmiddleware.GetOperationName(ctx)
+
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	stats, ok := callStats[operation]
+	if !ok {
+		stats = &CallStats{Operation: operation}
+		callStats[operation] = stats
+	}
+
+	record(stats)
+}
+
+var countCalls = middleware.FinalizeMiddlewareFunc("IdentityCallCount", func(
+	ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	recordCall(ctx, func(stats *CallStats) { stats.Calls++ })
+
+	return next.HandleFinalize(ctx, in)
+})
+
+func attemptMiddleware(shared *rateLimiter) middleware.FinalizeMiddleware {
+	return middleware.FinalizeMiddlewareFunc("IdentityAttempt", func(
+		ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+	) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if shared != nil {
+			if err := shared.wait(ctx); err != nil {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+			}
+		}
+
+		out, metadata, err := next.HandleFinalize(ctx, in)
+
+		var api smithy.APIError
+		throttled := errors.As(err, &api) && isThrottle(api.ErrorCode())
+
+		recordCall(ctx, func(stats *CallStats) {
+			stats.Attempts++
+
+			if throttled {
+				stats.Throttled++
+			}
+		})
+
+		return out, metadata, err
+	})
+}
@@ -0,0 +1,144 @@
+package Identity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestBackoff_BackoffDelay(t *testing.T) {
+	steady := backoff{base: time.Second, max: 20 * time.Second}
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 16 * time.Second, 6: 20 * time.Second, 64: 20 * time.Second} {
+		if got, _ := steady.BackoffDelay(attempt, nil); got != want {
+			t.Errorf("BackoffDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+
+	if got, _ := (backoff{base: time.Minute, max: time.Second}).BackoffDelay(1, nil); got != time.Second {
+		t.Errorf("BackoffDelay(1) with base over max = %s, want 1s", got)
+	}
+
+	jittered := backoff{base: time.Second, max: 20 * time.Second, jitter: true}
+
+	for i := 0; i < 100; i++ {
+		if got, _ := jittered.BackoffDelay(3, nil); got < 0 || got >= 4*time.Second {
+			t.Fatalf("BackoffDelay(3) with jitter = %s, want within [0s, 4s)", got)
+		}
+	}
+}
+
+func TestSetRetryPolicy(t *testing.T) {
+	t.Cleanup(func() { _ = SetRetryPolicy(DefaultRetryPolicy()) })
+
+	tests := []struct {
+		name    string
+		change  func(*RetryPolicy)
+		wantErr bool
+	}{
+		{"default", func(*RetryPolicy) {}, false},
+		{"adaptive", func(p *RetryPolicy) { p.Mode = aws.RetryModeAdaptive }, false},
+		{"rate_limited", func(p *RetryPolicy) { p.RequestsPerSecond = 5 }, false},
+		{"no_attempts", func(p *RetryPolicy) { p.MaxAttempts = 0 }, true},
+		{"unknown_mode", func(p *RetryPolicy) { p.Mode = "eager" }, true},
+		{"backoff_over_max", func(p *RetryPolicy) { p.BaseBackoff = time.Minute }, false},
+		{"no_max_backoff", func(p *RetryPolicy) { p.MaxBackoff = 0 }, true},
+		{"negative_rate", func(p *RetryPolicy) { p.RequestsPerSecond = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultRetryPolicy()
+			tt.change(&policy)
+
+			if err := SetRetryPolicy(policy); (err != nil) != tt.wantErr {
+				t.Errorf("SetRetryPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{interval: 10 * time.Millisecond}
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 waits at 100 per second took %s, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	limiter.next = time.Now().Add(time.Hour)
+	if err := limiter.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() with a cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRetryThrottling(t *testing.T) {
+	t.Cleanup(func() { _ = SetRetryPolicy(DefaultRetryPolicy()) })
+
+	tests := []struct {
+		name      string
+		mode      aws.RetryMode
+		throttle  int
+		attempts  int
+		throttled bool
+	}{
+		{"standard_recovers", aws.RetryModeStandard, 2, 3, false},
+		{"standard_gives_up", aws.RetryModeStandard, 10, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeAWS(t, "account.json")
+			fake.throttle["ListUserPolicies"] = tt.throttle
+
+			err := SetRetryPolicy(RetryPolicy{
+				MaxAttempts:       4,
+				Mode:              tt.mode,
+				BaseBackoff:       time.Millisecond,
+				MaxBackoff:        2 * time.Millisecond,
+				Jitter:            true,
+				RequestsPerSecond: 1000,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ResetCallStatistics()
+
+			_, err = GetUserPolicies(context.Background(), IAM{Name: "basic", IamType: UserType, Account: "680235478471"})
+			if errors.Is(err, ErrThrottled) != tt.throttled {
+				t.Fatalf("GetUserPolicies() error = %v, want throttled %v", err, tt.throttled)
+			}
+
+			want := CallStats{Operation: "IAM.ListUserPolicies", Calls: 1, Attempts: tt.attempts, Throttled: min(tt.throttle, tt.attempts)}
+
+			for _, stats := range CallStatistics() {
+				if stats.Operation == want.Operation {
+					if stats != want {
+						t.Errorf("CallStatistics() = %+v, want %+v", stats, want)
+					}
+
+					if stats.Retries() != tt.attempts-1 {
+						t.Errorf("Retries() = %d, want %d", stats.Retries(), tt.attempts-1)
+					}
+
+					return
+				}
+			}
+
+			t.Errorf("CallStatistics() = %+v, want %s counted", CallStatistics(), want.Operation)
+		})
+	}
+}
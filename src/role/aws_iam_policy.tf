@@ -10,10 +10,15 @@ data "aws_iam_policy_document" "policy" {
       "iam:ListAttachedGroupPolicies",
       "iam:GetPolicy",
       "iam:GetPolicyVersion",
+      "iam:ListPolicyVersions",
       "iam:GetUserPolicy",
       "iam:GetRolePolicy",
       "iam:GetGroupPolicy",
-      "iam:ListGroupsForUser"
+      "iam:ListGroupsForUser",
+      "iam:GetUser",
+      "iam:GetRole",
+      "iam:GenerateServiceLastAccessedDetails",
+      "iam:GetServiceLastAccessedDetails"
     ]
     resources = ["*"]
   }
@@ -0,0 +1,413 @@
+package Identity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// awsManagedPrefix is the ARN prefix of policies that AWS owns; they are referenced, never redefined.
+const awsManagedPrefix = "arn:aws:iam::aws:policy/"
+
+// hclBlock is a Terraform block whose attributes are rendered aligned, as terraform fmt does.
+type hclBlock struct {
+	header     string
+	attributes [][2]string
+	blocks     []*hclBlock
+}
+
+func (b *hclBlock) attribute(name string, value string) {
+	b.attributes = append(b.attributes, [2]string{name, value})
+}
+
+func (b *hclBlock) block(header string) *hclBlock {
+	child := &hclBlock{header: header}
+	b.blocks = append(b.blocks, child)
+
+	return child
+}
+
+func (b *hclBlock) render(builder *strings.Builder, indent string) {
+	builder.WriteString(indent + b.header + " {\n")
+
+	inner := indent + "  "
+
+	for _, line := range alignAssignments(b.attributes) {
+		builder.WriteString(inner + indentLines(line, inner) + "\n")
+	}
+
+	for i, child := range b.blocks {
+		if i > 0 || len(b.attributes) > 0 {
+			builder.WriteString("\n")
+		}
+
+		child.render(builder, inner)
+	}
+
+	builder.WriteString(indent + "}\n")
+}
+
+// ExportTerraform renders an identity, its groups and its policies as Terraform/OpenTofu, with import
+// blocks so that an identity created by hand can be brought under code. Inline and customer managed
+// policies become aws_iam_policy_document data sources; AWS managed policies are attached by ARN.
+func ExportTerraform(ident IAM) (string, error) {
+	names := map[string]bool{}
+	var blocks []*hclBlock
+
+	resourceType := "aws_iam_" + ident.IamType
+	principal := terraformName(ident.Name, names)
+	principalRef := resourceType + "." + principal + ".name"
+
+	resource := &hclBlock{header: fmt.Sprintf("resource %q %q", resourceType, principal)}
+	resource.attribute("name", hclString(ident.Name))
+
+	if ident.IamType == RoleType {
+		trust, err := hclJSON(ident.Trust)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert trust policy: %w", err)
+		}
+
+		resource.attribute("assume_role_policy", "jsonencode("+trust+")")
+	}
+
+	if ident.Boundary != nil && ident.Boundary.Source.Arn != "" {
+		resource.attribute("permissions_boundary", hclString(ident.Boundary.Source.Arn))
+	}
+
+	blocks = append(blocks, resource, importBlock(resourceType+"."+principal, ident.Name))
+
+	groups := map[string]string{}
+	managed := map[string]string{}
+	var groupNames []string
+
+	for _, policy := range ident.Policies {
+		ownerType, ownerName, ownerRef := ident.IamType, ident.Name, principalRef
+
+		if group := viaGroup(policy.Source); group != "" && ident.IamType == UserType {
+			if _, ok := groups[group]; !ok {
+				groups[group] = terraformName(group, names)
+				groupNames = append(groupNames, group)
+
+				block := &hclBlock{header: fmt.Sprintf("resource %q %q", "aws_iam_group", groups[group])}
+				block.attribute("name", hclString(group))
+				blocks = append(blocks, block, importBlock("aws_iam_group."+groups[group], group))
+			}
+
+			ownerType, ownerName, ownerRef = GroupType, group, "aws_iam_group."+groups[group]+".name"
+		}
+
+		blocks = append(blocks, exportPolicy(policy, ownerType, ownerName, ownerRef, names, managed)...)
+	}
+
+	if len(groupNames) > 0 {
+		name := terraformName(ident.Name+"_groups", names)
+
+		var refs []string
+		for _, group := range groupNames {
+			refs = append(refs, "aws_iam_group."+groups[group]+".name")
+		}
+
+		membership := &hclBlock{header: fmt.Sprintf("resource %q %q", "aws_iam_user_group_membership", name)}
+		membership.attribute("user", principalRef)
+		membership.attribute("groups", "["+strings.Join(refs, ", ")+"]")
+		blocks = append(blocks, membership,
+			importBlock("aws_iam_user_group_membership."+name, ident.Name+"/"+strings.Join(groupNames, "/")))
+	}
+
+	var builder strings.Builder
+
+	for i, block := range blocks {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+
+		block.render(&builder, "")
+	}
+
+	return builder.String(), nil
+}
+
+// exportPolicy renders one policy for the user, group or role that owns it. A customer managed policy
+// reached more than once, say directly and through a group, is declared once and recorded in managed
+// by ARN; later attachments reference that resource.
+func exportPolicy(policy Policy, ownerType string, ownerName string, ownerRef string, names map[string]bool, managed map[string]string) []*hclBlock {
+	policyName := policy.Source.Name
+	if policyName == "" {
+		policyName = "policy"
+	}
+
+	if strings.HasPrefix(policy.Source.Arn, awsManagedPrefix) {
+		name := terraformName(ownerName+"_"+policyName, names)
+		attachment := attachmentBlock(ownerType, name, ownerRef, hclString(policy.Source.Arn))
+
+		return []*hclBlock{attachment, importBlock(attachment.address(), ownerName+"/"+policy.Source.Arn)}
+	}
+
+	if existing, ok := managed[policy.Source.Arn]; ok && policy.Source.Kind == ManagedKind {
+		name := terraformName(ownerName+"_"+policyName, names)
+		attachment := attachmentBlock(ownerType, name, ownerRef, "aws_iam_policy."+existing+".arn")
+
+		return []*hclBlock{attachment, importBlock(attachment.address(), ownerName+"/"+policy.Source.Arn)}
+	}
+
+	name := terraformName(policyName, names)
+	document := policyDocument(name, policy)
+	documentRef := "data.aws_iam_policy_document." + name + ".json"
+
+	if policy.Source.Kind == ManagedKind {
+		if policy.Source.Arn != "" {
+			managed[policy.Source.Arn] = name
+		}
+
+		resource := &hclBlock{header: fmt.Sprintf("resource %q %q", "aws_iam_policy", name)}
+		resource.attribute("name", hclString(policyName))
+		resource.attribute("policy", documentRef)
+
+		attachment := attachmentBlock(ownerType, name, ownerRef, "aws_iam_policy."+name+".arn")
+
+		return []*hclBlock{
+			document, resource, importBlock("aws_iam_policy."+name, policy.Source.Arn),
+			attachment, importBlock(attachment.address(), ownerName+"/"+policy.Source.Arn),
+		}
+	}
+
+	resourceType := "aws_iam_" + ownerType + "_policy"
+	inline := &hclBlock{header: fmt.Sprintf("resource %q %q", resourceType, name)}
+	inline.attribute("name", hclString(policyName))
+	inline.attribute(ownerType, ownerRef)
+	inline.attribute("policy", documentRef)
+
+	return []*hclBlock{document, inline, importBlock(resourceType+"."+name, ownerName+":"+policyName)}
+}
+
+func attachmentBlock(ownerType string, name string, ownerRef string, arn string) *hclBlock {
+	attachment := &hclBlock{header: fmt.Sprintf("resource %q %q", "aws_iam_"+ownerType+"_policy_attachment", name)}
+	attachment.attribute(ownerType, ownerRef)
+	attachment.attribute("policy_arn", arn)
+
+	return attachment
+}
+
+func policyDocument(name string, policy Policy) *hclBlock {
+	document := &hclBlock{header: fmt.Sprintf("data %q %q", "aws_iam_policy_document", name)}
+
+	for _, statement := range policy.Statements {
+		block := document.block("statement")
+
+		if statement.Sid != "" {
+			block.attribute("sid", hclString(statement.Sid))
+		}
+
+		block.attribute("effect", hclString(statement.Effect))
+
+		for _, list := range []struct {
+			name   string
+			values []string
+		}{{"actions", statement.Action}, {"not_actions", statement.NotAction}, {"resources", statement.Resource}, {"not_resources", statement.NotResource}} {
+			if len(list.values) > 0 {
+				block.attribute(list.name, hclList(list.values))
+			}
+		}
+
+		for _, principal := range []struct {
+			name       string
+			principals Principal
+		}{{"principals", statement.Principal}, {"not_principals", statement.NotPrincipal}} {
+			for _, kind := range sortedKeys(principal.principals) {
+				principals := block.block(principal.name)
+				principals.attribute("type", hclString(kind))
+				principals.attribute("identifiers", hclList(principal.principals[kind]))
+			}
+		}
+
+		for _, operator := range sortedKeys(statement.Condition) {
+			for _, key := range sortedKeys(statement.Condition[operator]) {
+				condition := block.block("condition")
+				condition.attribute("test", hclString(operator))
+				condition.attribute("variable", hclString(key))
+				condition.attribute("values", hclList(statement.Condition[operator][key]))
+			}
+		}
+	}
+
+	return document
+}
+
+func importBlock(to string, id string) *hclBlock {
+	block := &hclBlock{header: "import"}
+	block.attribute("to", to)
+	block.attribute("id", hclString(id))
+
+	return block
+}
+
+// address returns the resource address of a resource block.
+func (b *hclBlock) address() string {
+	parts := strings.Split(b.header, `"`)
+
+	return parts[1] + "." + parts[3]
+}
+
+// viaGroup returns the group a policy was inherited through, if any.
+func viaGroup(source Source) string {
+	for _, step := range source.Via {
+		if name, found := strings.CutPrefix(step, GroupType+"/"); found {
+			return name
+		}
+	}
+
+	return ""
+}
+
+var invalidTerraformName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// terraformName turns a name into a unique Terraform identifier.
+func terraformName(name string, used map[string]bool) string {
+	base := invalidTerraformName.ReplaceAllString(name, "_")
+	if base == "" || (base[0] >= '0' && base[0] <= '9') || base[0] == '-' {
+		base = "_" + base
+	}
+
+	candidate := base
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	used[candidate] = true
+
+	return candidate
+}
+
+// hclString quotes a string for HCL, escaping template sequences such as ${aws:username}.
+func hclString(value string) string {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	escaped := strings.ReplaceAll(strings.TrimSuffix(buffer.String(), "\n"), "${", "$${")
+
+	return strings.ReplaceAll(escaped, "%{", "%%{")
+}
+
+func hclList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, hclString(value))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// hclJSON converts a JSON document into the equivalent HCL expression, for use inside jsonencode.
+func hclJSON(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "{}", nil
+	}
+
+	var value interface{}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+
+	return hclValue(value, ""), nil
+}
+
+var bareKey = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+func hclValue(value interface{}, indent string) string {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if len(typed) == 0 {
+			return "{}"
+		}
+
+		pairs := make([][2]string, 0, len(typed))
+
+		for _, key := range sortedKeys(typed) {
+			name := key
+			if !bareKey.MatchString(key) {
+				name = hclString(key)
+			}
+
+			pairs = append(pairs, [2]string{name, hclValue(typed[key], indent+"  ")})
+		}
+
+		var builder strings.Builder
+		builder.WriteString("{\n")
+
+		for _, line := range alignAssignments(pairs) {
+			builder.WriteString(indent + "  " + line + "\n")
+		}
+
+		builder.WriteString(indent + "}")
+
+		return builder.String()
+	case []interface{}:
+		items := make([]string, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, hclValue(item, indent))
+		}
+
+		return "[" + strings.Join(items, ", ") + "]"
+	case string:
+		return hclString(typed)
+	case nil:
+		return "null"
+	default:
+		encoded, _ := json.Marshal(typed)
+
+		return string(encoded)
+	}
+}
+
+// alignAssignments renders name = value pairs the way terraform fmt does: runs of single-line values
+// have their equals signs aligned, and a multi-line value ends the run without being aligned itself.
+func alignAssignments(pairs [][2]string) []string {
+	lines := make([]string, 0, len(pairs))
+
+	for start := 0; start < len(pairs); {
+		if strings.Contains(pairs[start][1], "\n") {
+			lines = append(lines, pairs[start][0]+" = "+pairs[start][1])
+			start++
+
+			continue
+		}
+
+		end := start
+		width := 0
+
+		for end < len(pairs) && !strings.Contains(pairs[end][1], "\n") {
+			width = max(width, len(pairs[end][0]))
+			end++
+		}
+
+		for _, pair := range pairs[start:end] {
+			lines = append(lines, fmt.Sprintf("%-*s = %s", width, pair[0], pair[1]))
+		}
+
+		start = end
+	}
+
+	return lines
+}
+
+func indentLines(value string, indent string) string {
+	return strings.ReplaceAll(value, "\n", "\n"+indent)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
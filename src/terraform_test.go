@@ -0,0 +1,308 @@
+package Identity
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExportTerraform(t *testing.T) {
+	statements := []Statement{{
+		Sid:       "Logs",
+		Effect:    "Allow",
+		Action:    []string{"s3:GetObject"},
+		Resource:  []string{"arn:aws:s3:::logs/${aws:username}/*"},
+		Condition: Condition{"Bool": {"aws:SecureTransport": {"true"}}},
+	}}
+
+	tests := []struct {
+		name  string
+		ident IAM
+		want  string
+	}{
+		{
+			name: "role",
+			ident: IAM{
+				Name:    "deploy",
+				Account: "123456789012",
+				IamType: RoleType,
+				Trust:   json.RawMessage(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"Service":"ec2.amazonaws.com"}}]}`),
+				Policies: []Policy{
+					{Version: "2012-10-17", Statements: statements, Source: Source{Name: "logs", Kind: InlineKind, Via: []string{"role/deploy"}}},
+					{Version: "2012-10-17", Source: Source{Name: "ReadOnlyAccess", Arn: "arn:aws:iam::aws:policy/ReadOnlyAccess", Kind: ManagedKind, Via: []string{"role/deploy"}}},
+				},
+			},
+			want: `resource "aws_iam_role" "deploy" {
+  name = "deploy"
+  assume_role_policy = jsonencode({
+    Statement = [{
+      Action = "sts:AssumeRole"
+      Effect = "Allow"
+      Principal = {
+        Service = "ec2.amazonaws.com"
+      }
+    }]
+    Version = "2012-10-17"
+  })
+}
+
+import {
+  to = aws_iam_role.deploy
+  id = "deploy"
+}
+
+data "aws_iam_policy_document" "logs" {
+  statement {
+    sid       = "Logs"
+    effect    = "Allow"
+    actions   = ["s3:GetObject"]
+    resources = ["arn:aws:s3:::logs/$${aws:username}/*"]
+
+    condition {
+      test     = "Bool"
+      variable = "aws:SecureTransport"
+      values   = ["true"]
+    }
+  }
+}
+
+resource "aws_iam_role_policy" "logs" {
+  name   = "logs"
+  role   = aws_iam_role.deploy.name
+  policy = data.aws_iam_policy_document.logs.json
+}
+
+import {
+  to = aws_iam_role_policy.logs
+  id = "deploy:logs"
+}
+
+resource "aws_iam_role_policy_attachment" "deploy_ReadOnlyAccess" {
+  role       = aws_iam_role.deploy.name
+  policy_arn = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+}
+
+import {
+  to = aws_iam_role_policy_attachment.deploy_ReadOnlyAccess
+  id = "deploy/arn:aws:iam::aws:policy/ReadOnlyAccess"
+}
+`,
+		},
+		{
+			name: "user_in_group",
+			ident: IAM{
+				Name:    "basic",
+				Account: "123456789012",
+				IamType: UserType,
+				Policies: []Policy{
+					{Version: "2012-10-17", Statements: statements, Source: Source{Name: "shared", Arn: "arn:aws:iam::123456789012:policy/shared", Kind: ManagedKind, Via: []string{"user/basic", "group/devs"}}},
+				},
+			},
+			want: `resource "aws_iam_user" "basic" {
+  name = "basic"
+}
+
+import {
+  to = aws_iam_user.basic
+  id = "basic"
+}
+
+resource "aws_iam_group" "devs" {
+  name = "devs"
+}
+
+import {
+  to = aws_iam_group.devs
+  id = "devs"
+}
+
+data "aws_iam_policy_document" "shared" {
+  statement {
+    sid       = "Logs"
+    effect    = "Allow"
+    actions   = ["s3:GetObject"]
+    resources = ["arn:aws:s3:::logs/$${aws:username}/*"]
+
+    condition {
+      test     = "Bool"
+      variable = "aws:SecureTransport"
+      values   = ["true"]
+    }
+  }
+}
+
+resource "aws_iam_policy" "shared" {
+  name   = "shared"
+  policy = data.aws_iam_policy_document.shared.json
+}
+
+import {
+  to = aws_iam_policy.shared
+  id = "arn:aws:iam::123456789012:policy/shared"
+}
+
+resource "aws_iam_group_policy_attachment" "shared" {
+  group      = aws_iam_group.devs.name
+  policy_arn = aws_iam_policy.shared.arn
+}
+
+import {
+  to = aws_iam_group_policy_attachment.shared
+  id = "devs/arn:aws:iam::123456789012:policy/shared"
+}
+
+resource "aws_iam_user_group_membership" "basic_groups" {
+  user   = aws_iam_user.basic.name
+  groups = [aws_iam_group.devs.name]
+}
+
+import {
+  to = aws_iam_user_group_membership.basic_groups
+  id = "basic/devs"
+}
+`,
+		},
+		{
+			name: "shared_policy",
+			ident: IAM{
+				Name:    "basic",
+				Account: "123456789012",
+				IamType: UserType,
+				Policies: []Policy{
+					{Version: "2012-10-17", Statements: statements, Source: Source{Name: "shared", Arn: "arn:aws:iam::123456789012:policy/shared", Kind: ManagedKind, Via: []string{"user/basic"}}},
+					{Version: "2012-10-17", Statements: statements, Source: Source{Name: "shared", Arn: "arn:aws:iam::123456789012:policy/shared", Kind: ManagedKind, Via: []string{"user/basic", "group/devs"}}},
+				},
+			},
+			want: `resource "aws_iam_user" "basic" {
+  name = "basic"
+}
+
+import {
+  to = aws_iam_user.basic
+  id = "basic"
+}
+
+data "aws_iam_policy_document" "shared" {
+  statement {
+    sid       = "Logs"
+    effect    = "Allow"
+    actions   = ["s3:GetObject"]
+    resources = ["arn:aws:s3:::logs/$${aws:username}/*"]
+
+    condition {
+      test     = "Bool"
+      variable = "aws:SecureTransport"
+      values   = ["true"]
+    }
+  }
+}
+
+resource "aws_iam_policy" "shared" {
+  name   = "shared"
+  policy = data.aws_iam_policy_document.shared.json
+}
+
+import {
+  to = aws_iam_policy.shared
+  id = "arn:aws:iam::123456789012:policy/shared"
+}
+
+resource "aws_iam_user_policy_attachment" "shared" {
+  user       = aws_iam_user.basic.name
+  policy_arn = aws_iam_policy.shared.arn
+}
+
+import {
+  to = aws_iam_user_policy_attachment.shared
+  id = "basic/arn:aws:iam::123456789012:policy/shared"
+}
+
+resource "aws_iam_group" "devs" {
+  name = "devs"
+}
+
+import {
+  to = aws_iam_group.devs
+  id = "devs"
+}
+
+resource "aws_iam_group_policy_attachment" "devs_shared" {
+  group      = aws_iam_group.devs.name
+  policy_arn = aws_iam_policy.shared.arn
+}
+
+import {
+  to = aws_iam_group_policy_attachment.devs_shared
+  id = "devs/arn:aws:iam::123456789012:policy/shared"
+}
+
+resource "aws_iam_user_group_membership" "basic_groups" {
+  user   = aws_iam_user.basic.name
+  groups = [aws_iam_group.devs.name]
+}
+
+import {
+  to = aws_iam_user_group_membership.basic_groups
+  id = "basic/devs"
+}
+`,
+		},
+		{
+			name: "not_fields",
+			ident: IAM{
+				Name:    "basic",
+				Account: "123456789012",
+				IamType: UserType,
+				Policies: []Policy{{Version: "2012-10-17", Statements: []Statement{{
+					Effect:       "Deny",
+					NotPrincipal: Principal{"AWS": {"arn:aws:iam::123456789012:root"}},
+					NotAction:    []string{"iam:*"},
+					NotResource:  []string{"arn:aws:s3:::logs/*"},
+				}}, Source: Source{Name: "guard", Kind: InlineKind, Via: []string{"user/basic"}}}},
+			},
+			want: `resource "aws_iam_user" "basic" {
+  name = "basic"
+}
+
+import {
+  to = aws_iam_user.basic
+  id = "basic"
+}
+
+data "aws_iam_policy_document" "guard" {
+  statement {
+    effect        = "Deny"
+    not_actions   = ["iam:*"]
+    not_resources = ["arn:aws:s3:::logs/*"]
+
+    not_principals {
+      type        = "AWS"
+      identifiers = ["arn:aws:iam::123456789012:root"]
+    }
+  }
+}
+
+resource "aws_iam_user_policy" "guard" {
+  name   = "guard"
+  user   = aws_iam_user.basic.name
+  policy = data.aws_iam_policy_document.guard.json
+}
+
+import {
+  to = aws_iam_user_policy.guard
+  id = "basic:guard"
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExportTerraform(tt.ident)
+			if err != nil {
+				t.Fatalf("ExportTerraform() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExportTerraform() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}